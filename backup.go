@@ -0,0 +1,353 @@
+package bingo
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BACKUP_TIME_LAYOUT     = "20060102T150405.000000000Z"
+	BACKUP_EXTENSION       = ".db"
+	BACKUP_GZIP_EXTENSION  = ".gz"
+	BACKUP_CHECKSUM_SUFFIX = ".sha256"
+)
+
+var ErrChecksumMismatch = fmt.Errorf("backup checksum mismatch")
+
+// IsErrChecksumMismatch returns true if the error is an ErrChecksumMismatch error.
+func IsErrChecksumMismatch(err error) bool {
+	return errors.Is(err, ErrChecksumMismatch)
+}
+
+// BackupOptions configures BackupTo.
+// Compress writes the backup gzip compressed.
+// Checksum writes a sha256sum compatible manifest next to the backup, it is verified by Restore.
+type BackupOptions struct {
+	Compress bool
+	Checksum bool
+}
+
+func Compress(opts *BackupOptions) {
+	opts.Compress = true
+}
+
+func Checksum(opts *BackupOptions) {
+	opts.Checksum = true
+}
+
+// Backup writes a consistent copy of the database to w while other transactions keep running.
+func (d *Driver) Backup(w io.Writer) (int64, error) {
+	var n int64
+	err := d.view(func(tx *bbolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// BackupTo writes a backup of the database to path. The backup is written to a temporary file
+// that is renamed to path once complete, so path never contains a partial backup.
+func (d *Driver) BackupTo(path string, opts ...func(options *BackupOptions)) error {
+	opt := &BackupOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	var sum hash.Hash
+	err := writeAtomic(path, func(f io.Writer) error {
+		if opt.Checksum {
+			sum = sha256.New()
+			f = io.MultiWriter(f, sum)
+		}
+		if !opt.Compress {
+			_, err := d.Backup(f)
+			return err
+		}
+		gz := gzip.NewWriter(f)
+		if _, err := d.Backup(gz); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+	if err != nil || sum == nil {
+		return err
+	}
+	manifest := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum.Sum(nil)), filepath.Base(path))
+	return writeAtomic(path+BACKUP_CHECKSUM_SUFFIX, func(f io.Writer) error {
+		_, err := io.WriteString(f, manifest)
+		return err
+	})
+}
+
+// writeAtomic writes a file through a synced temporary file in the same directory and renames it to path.
+func writeAtomic(path string, write func(f io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Restore replaces the database file at path with the backup at src. The backup may be compressed,
+// and if a checksum manifest exists next to it the backup is verified before anything is replaced.
+// The database at path must not be open, Restore fails if another process holds it.
+func Restore(path, src string) error {
+	if err := verifyChecksum(src); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	br := bufio.NewReader(in)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 100 * time.Millisecond})
+		if err != nil {
+			return fmt.Errorf("cannot restore over %s: %w", path, err)
+		}
+		db.Close()
+	}
+
+	tmp := path + ".restore"
+	err = writeAtomic(tmp, func(f io.Writer) error {
+		_, err := io.Copy(f, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := checkFile(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("invalid backup %s: %w", src, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func verifyChecksum(src string) error {
+	manifest, err := os.ReadFile(src + BACKUP_CHECKSUM_SUFFIX)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(manifest))
+	if len(fields) == 0 {
+		return fmt.Errorf("invalid checksum manifest for %s", src)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(sum.Sum(nil)) != fields[0] {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, src)
+	}
+	return nil
+}
+
+// checkFile opens a database file and runs the bbolt consistency check on it.
+func checkFile(path string) error {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bbolt.Tx) error {
+		// the channel is drained so the checking goroutine and its transaction finish
+		var errs []error
+		for err := range tx.Check() {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// BackupFile is a backup written by a BackupSchedule.
+type BackupFile struct {
+	Path string
+	Time time.Time
+}
+
+// BackupSchedule configures rotating backups, see Driver.ScheduleBackups.
+// Dir is the directory backups are written to, named <Prefix>-<time>.db.
+// Prefix defaults to "bingo".
+// Retain is the number of backups kept, older ones are removed. Zero keeps every backup.
+// OnError, if set, is called with errors of scheduled backups.
+type BackupSchedule struct {
+	Dir      string
+	Prefix   string
+	Interval time.Duration
+	Retain   int
+	Compress bool
+	Checksum bool
+	OnError  func(err error)
+}
+
+func (s BackupSchedule) prefix() string {
+	if s.Prefix == "" {
+		return "bingo"
+	}
+	return s.Prefix
+}
+
+// ScheduleBackups writes a backup every Interval until the returned stop function is called.
+func (d *Driver) ScheduleBackups(schedule BackupSchedule) (stop func(), err error) {
+	if schedule.Interval <= 0 {
+		return nil, fmt.Errorf("backup interval must be positive")
+	}
+	if err := os.MkdirAll(schedule.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(schedule.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if d.IsClosed() {
+					return
+				}
+				if _, err := d.RotateBackup(schedule); err != nil && schedule.OnError != nil {
+					schedule.OnError(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}, nil
+}
+
+// RotateBackup writes a backup following the schedule and removes the backups exceeding its retention.
+func (d *Driver) RotateBackup(schedule BackupSchedule) (*BackupFile, error) {
+	now := time.Now().UTC()
+	path := filepath.Join(schedule.Dir, schedule.prefix()+"-"+now.Format(BACKUP_TIME_LAYOUT)+BACKUP_EXTENSION)
+	if schedule.Compress {
+		path += BACKUP_GZIP_EXTENSION
+	}
+	err := d.BackupTo(path, func(options *BackupOptions) {
+		options.Compress = schedule.Compress
+		options.Checksum = schedule.Checksum
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.Retain > 0 {
+		backups, err := ListBackups(schedule.Dir, schedule.prefix())
+		if err != nil {
+			return nil, err
+		}
+		for len(backups) > schedule.Retain {
+			os.Remove(backups[0].Path)
+			os.Remove(backups[0].Path + BACKUP_CHECKSUM_SUFFIX)
+			backups = backups[1:]
+		}
+	}
+	return &BackupFile{Path: path, Time: now}, nil
+}
+
+// ListBackups returns the backups written to dir by a schedule with the prefix, oldest first.
+func ListBackups(dir, prefix string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []BackupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix+"-") {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix+"-")
+		stamp = strings.TrimSuffix(stamp, BACKUP_GZIP_EXTENSION)
+		if !strings.HasSuffix(stamp, BACKUP_EXTENSION) {
+			continue
+		}
+		t, err := time.Parse(BACKUP_TIME_LAYOUT, strings.TrimSuffix(stamp, BACKUP_EXTENSION))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupFile{Path: filepath.Join(dir, name), Time: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.Before(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// RestoreAt restores the database at path to the latest backup in dir taken at or before the given time.
+func RestoreAt(path, dir, prefix string, at time.Time) (*BackupFile, error) {
+	backups, err := ListBackups(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		if !backups[i].Time.After(at) {
+			return &backups[i], Restore(path, backups[i].Path)
+		}
+	}
+	return nil, fmt.Errorf("no backup in %s taken before %s", dir, at.Format(time.RFC3339))
+}
@@ -0,0 +1,164 @@
+package bingo_test
+
+import (
+	"bytes"
+	"github.com/nokusukun/bingo"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	config := bingo.DriverConfiguration{
+		Filename:       filepath.Join(dir, "testbackup.db"),
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer driver.Close()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "test")
+	if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "before"}, Name: "before"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if n, err := driver.Backup(&buf); err != nil || n == 0 || int64(buf.Len()) != n {
+		t.Fatalf("Failed to backup: %v (%d bytes)", err, n)
+	}
+
+	plain := filepath.Join(dir, "plain.db")
+	compressed := filepath.Join(dir, "compressed.db.gz")
+	if err := driver.BackupTo(plain); err != nil {
+		t.Fatalf("Failed to backup to file: %v", err)
+	}
+	if err := driver.BackupTo(compressed, bingo.Compress, bingo.Checksum); err != nil {
+		t.Fatalf("Failed to backup compressed: %v", err)
+	}
+	if _, err := os.Stat(compressed + ".sha256"); err != nil {
+		t.Fatalf("Expected a checksum manifest: %v", err)
+	}
+
+	if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "after"}, Name: "after"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	if err := bingo.Restore(config.Filename, plain); err == nil {
+		t.Fatalf("Expected restoring over an open database to fail")
+	}
+
+	for _, src := range []string{plain, compressed} {
+		target := filepath.Join(dir, "restored.db")
+		if err := bingo.Restore(target, src); err != nil {
+			t.Fatalf("Failed to restore %s: %v", src, err)
+		}
+		restored, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: target})
+		if err != nil {
+			t.Fatalf("Failed to open restored database: %v", err)
+		}
+		rc := bingo.CollectionFrom[TestDocument](restored, "test")
+		if _, err := rc.FindByKey("before"); err != nil {
+			t.Fatalf("Expected document in restored database: %v", err)
+		}
+		if _, err := rc.FindByKey("after"); err == nil {
+			t.Fatalf("Expected document written after the backup to be missing")
+		}
+		restored.Close()
+		os.Remove(target)
+	}
+
+	data, _ := os.ReadFile(compressed)
+	data[len(data)/2] ^= 0xff
+	_ = os.WriteFile(compressed, data, 0600)
+	if err := bingo.Restore(filepath.Join(dir, "corrupt.db"), compressed); err == nil || !bingo.IsErrChecksumMismatch(err) {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	driver, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: filepath.Join(dir, "testrotate.db")})
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer driver.Close()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "test")
+	schedule := bingo.BackupSchedule{Dir: filepath.Join(dir, "backups"), Retain: 2, Interval: time.Hour, Checksum: true}
+	if err := os.MkdirAll(schedule.Dir, 0700); err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+
+	var times []time.Time
+	for _, name := range []string{"one", "two", "three"} {
+		if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: name}, Name: name}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+		backup, err := driver.RotateBackup(schedule)
+		if err != nil {
+			t.Fatalf("Failed to rotate backup: %v", err)
+		}
+		times = append(times, backup.Time)
+	}
+
+	backups, err := bingo.ListBackups(schedule.Dir, "bingo")
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("Expected 2 retained backups, got %v %v", backups, err)
+	}
+
+	target := filepath.Join(dir, "pit.db")
+	if _, err := bingo.RestoreAt(target, schedule.Dir, "bingo", times[0]); err == nil {
+		t.Fatalf("Expected the first backup to be rotated out")
+	}
+	backup, err := bingo.RestoreAt(target, schedule.Dir, "bingo", times[1].Add(time.Nanosecond))
+	if err != nil || !backup.Time.Equal(times[1]) {
+		t.Fatalf("Expected the second backup to be restored, got %v %v", backup, err)
+	}
+	restored, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: target})
+	if err != nil {
+		t.Fatalf("Failed to open restored database: %v", err)
+	}
+	defer restored.Close()
+	rc := bingo.CollectionFrom[TestDocument](restored, "test")
+	if _, err := rc.FindByKey("two"); err != nil {
+		t.Fatalf("Expected two in the restored database: %v", err)
+	}
+	if _, err := rc.FindByKey("three"); err == nil {
+		t.Fatalf("Expected three to be missing from the restored database")
+	}
+
+	stop, err := driver.ScheduleBackups(bingo.BackupSchedule{Dir: filepath.Join(dir, "scheduled"), Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to schedule backups: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	if scheduled, _ := bingo.ListBackups(filepath.Join(dir, "scheduled"), "bingo"); len(scheduled) == 0 {
+		t.Fatalf("Expected scheduled backups to be written")
+	}
+}
+
+func TestScheduledBackupsStopOnClose(t *testing.T) {
+	dir := t.TempDir()
+	driver, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: filepath.Join(dir, "testschedule.db")})
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	stop, err := driver.ScheduleBackups(bingo.BackupSchedule{Dir: filepath.Join(dir, "scheduled"), Interval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to schedule backups: %v", err)
+	}
+	defer stop()
+	time.Sleep(10 * time.Millisecond)
+	if err := driver.Close(); err != nil {
+		t.Fatalf("Failed to close driver: %v", err)
+	}
+	if !driver.IsClosed() {
+		t.Fatalf("Expected the driver to be closed")
+	}
+	time.Sleep(10 * time.Millisecond)
+}
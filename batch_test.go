@@ -0,0 +1,114 @@
+package bingo_test
+
+import (
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBatchedWrites(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testbatch.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testbatch.db")
+	}()
+
+	var hooks int32
+	coll := bingo.CollectionFrom[TestDocument](driver, "batch").AfterInsert(func(doc *TestDocument) error {
+		atomic.AddInt32(&hooks, 1)
+		return nil
+	}).Batched()
+
+	// Every key is inserted twice concurrently, exactly one of each pair must fail
+	var wg sync.WaitGroup
+	var inserted, exists, other int32
+	for i := 0; i < 100; i++ {
+		for j := 0; j < 2; j++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := coll.Insert(TestDocument{Document: bingo.Document{ID: fmt.Sprintf("doc-%d", i)}, Name: "batched"})
+				switch {
+				case err == nil:
+					atomic.AddInt32(&inserted, 1)
+				case bingo.IsErrDocumentExists(err):
+					atomic.AddInt32(&exists, 1)
+				default:
+					atomic.AddInt32(&other, 1)
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+	if inserted != 100 || exists != 100 || other != 0 {
+		t.Fatalf("Expected 100 inserts and 100 conflicts, got %d, %d and %d other errors", inserted, exists, other)
+	}
+	if hooks != 100 {
+		t.Fatalf("Expected AfterInsert to run once per insert, ran %d times", hooks)
+	}
+
+	if _, err := coll.Insert(TestDocument{Name: ""}); err == nil {
+		t.Fatalf("Expected a validation error")
+	}
+	if id, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "doc-1"}, Name: "again"}, bingo.IgnoreErrors); err != nil || id != nil {
+		t.Fatalf("Expected the conflict to be ignored, got %s %v", id, err)
+	}
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc := TestDocument{Document: bingo.Document{ID: fmt.Sprintf("doc-%d", i)}, Name: "updated"}
+			var err error
+			if i%2 == 0 {
+				err = coll.UpdateOne(doc)
+			} else {
+				err = coll.DeleteOne(doc)
+			}
+			if err != nil {
+				atomic.AddInt32(&other, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if other != 0 {
+		t.Fatalf("Expected batched updates and deletes to succeed, %d failed", other)
+	}
+	docs, err := coll.Find(func(doc TestDocument) bool {
+		return doc.Name == "updated"
+	})
+	if err != nil || len(docs) != 50 {
+		t.Fatalf("Expected 50 updated documents, got %d %v", len(docs), err)
+	}
+
+	plain := bingo.CollectionFrom[TestDocument](driver, "plain")
+	id, err := plain.Insert(TestDocument{Name: "generated"}, bingo.Batch)
+	if err != nil || len(id) == 0 {
+		t.Fatalf("Expected a per call batched insert with a generated key, got %s %v", id, err)
+	}
+	if doc, err := plain.FindByKey(string(id)); err != nil || doc.ID != string(id) {
+		t.Fatalf("Expected the generated key to be stored, got %+v %v", doc, err)
+	}
+
+	// IgnoreErrors skips the errors of the document, not those of the database
+	if id, err := plain.Insert(TestDocument{Document: bingo.Document{ID: string(id)}, Name: "again"}, bingo.Batch, bingo.IgnoreErrors); err != nil || id != nil {
+		t.Fatalf("Expected an existing document to be skipped, got %s %v", id, err)
+	}
+	if _, err := plain.Insert(TestDocument{Name: ""}, bingo.Batch, bingo.IgnoreErrors); err != nil {
+		t.Fatalf("Expected an invalid document to be skipped, got %v", err)
+	}
+	driver.Close()
+	if _, err := plain.Insert(TestDocument{Name: "closed"}, bingo.Batch, bingo.IgnoreErrors); !bingo.IsErrDriverClosed(err) {
+		t.Fatalf("Expected a closed driver to fail the insert, got %v", err)
+	}
+}
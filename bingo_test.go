@@ -256,6 +256,28 @@ func TestUpdateOne(t *testing.T) {
 	if result.Name != "Pineapple" {
 		t.Fatalf("Unexpected query result: %v", result)
 	}
+
+	key := string(result.Key())
+	err = coll.UpdateByKeyFunc(key, func(doc *TestDocument) error {
+		doc.Name += " Juice"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update document by key: %v", err)
+	}
+	if result, _ = coll.FindByKey(key); result.Name != "Pineapple Juice" {
+		t.Fatalf("Expected the document to be updated in place, got %v", result)
+	}
+	err = coll.UpdateByKeyFunc(key, func(doc *TestDocument) error {
+		doc.ID = "other"
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("Expected changing the key to fail")
+	}
+	if err := coll.UpdateByKeyFunc("missing", func(*TestDocument) error { return nil }); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected a missing document not to be found, got %v", err)
+	}
 }
 
 func TestDeleteOne(t *testing.T) {
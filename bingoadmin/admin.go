@@ -0,0 +1,496 @@
+// Package bingoadmin serves a web interface to browse and edit the collections of a bingo database.
+//
+//	admin := bingoadmin.NewHandler(driver, bingoadmin.Configuration{ReadOnly: true})
+//	http.Handle("/admin/", http.StripPrefix("/admin", admin))
+//
+// Documents are shown and edited in their stored JSON representation. Collections without a Go type are edited as
+// dynamic collections and validated against the JSON Schema of the collection, see Driver.JSONSchema. Typed collections
+// are read and edited through the collection registered with Register, so their hooks, validation, indexes and
+// references apply, and are read-only until they are registered.
+//
+//	bingoadmin.Register(admin, users)
+package bingoadmin
+
+import (
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//go:embed static
+var static embed.FS
+
+var errStop = errors.New("stop")
+
+// Configuration configures the admin handler.
+type Configuration struct {
+	// Title is shown in the header of the interface, defaults to "bingo".
+	Title string
+	// ReadOnly disables editing and deleting documents.
+	ReadOnly bool
+	// PageSize is the number of documents shown per page, defaults to 25.
+	PageSize int
+}
+
+// Handler is an http.Handler serving the admin interface and the API it uses.
+type Handler struct {
+	driver   *bingo.Driver
+	config   Configuration
+	readOnly int32
+	assets   http.Handler
+
+	mu    sync.RWMutex
+	typed map[string]editor
+}
+
+// editor reads and writes the documents of a registered typed collection, through its codec, expiry and hooks.
+type editor interface {
+	get(key string) ([]byte, error)
+	list(filter bingo.Filter, skip, limit int) (entries []documentEntry, scanned int, err error)
+	replace(key string, body []byte) ([]byte, error)
+	delete(key string) error
+}
+
+type typedEditor[T bingo.DocumentSpec] struct {
+	coll *bingo.Collection[T]
+}
+
+func (e *typedEditor[T]) get(key string) ([]byte, error) {
+	doc, err := e.coll.FindByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return bingo.Marshaller.Marshal(doc)
+}
+
+func (e *typedEditor[T]) list(filter bingo.Filter, skip, limit int) ([]documentEntry, int, error) {
+	qr := e.coll.Query(bingo.Query[T]{Filter: bingo.Where[T](filter), Skip: skip, Count: limit})
+	if qr.Error != nil {
+		return nil, 0, qr.Error
+	}
+	var entries []documentEntry
+	for i, item := range qr.Items {
+		data, err := bingo.Marshaller.Marshal(item)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, documentEntry{Key: string(qr.Keys[i]), Document: data})
+	}
+	return entries, qr.Next, nil
+}
+
+// replace replaces an existing document through the update lifecycle and returns it as stored.
+// The document is looked up and written in a single transaction, so a document deleted meanwhile is not recreated.
+func (e *typedEditor[T]) replace(key string, body []byte) ([]byte, error) {
+	var doc T
+	if err := bingo.Unmarshaller.Unmarshal(body, &doc); err != nil {
+		return nil, &apiError{http.StatusBadRequest, fmt.Errorf("invalid document: %v", err)}
+	}
+	if len(doc.Key()) == 0 {
+		_ = bingo.SetKey(&doc, []byte(key))
+	}
+	if k := string(doc.Key()); k != key {
+		return nil, &apiError{http.StatusBadRequest, fmt.Errorf("the document key cannot be changed from %q to %q", key, k)}
+	}
+	var stored *T
+	err := e.coll.UpdateByKeyFunc(key, func(current *T) error {
+		*current = doc
+		stored = current
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bingo.Marshaller.Marshal(*stored)
+}
+
+func (e *typedEditor[T]) delete(key string) error {
+	return e.coll.DeleteByKey(key)
+}
+
+// Register makes a typed collection editable, its documents are read and written through it.
+func Register[T bingo.DocumentSpec](h *Handler, coll *bingo.Collection[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.typed[coll.Name] = &typedEditor[T]{coll: coll}
+}
+
+// NewHandler creates an admin handler for the driver.
+func NewHandler(driver *bingo.Driver, config Configuration) *Handler {
+	if config.Title == "" {
+		config.Title = "bingo"
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = 25
+	}
+	sub, _ := fs.Sub(static, "static")
+	h := &Handler{driver: driver, config: config, assets: http.FileServer(http.FS(sub)), typed: map[string]editor{}}
+	h.SetReadOnly(config.ReadOnly)
+	return h
+}
+
+// SetReadOnly switches the read-only mode while the handler is serving.
+func (h *Handler) SetReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+	atomic.StoreInt32(&h.readOnly, v)
+}
+
+// ReadOnly returns true if editing and deleting documents is disabled.
+func (h *Handler) ReadOnly() bool {
+	return atomic.LoadInt32(&h.readOnly) == 1
+}
+
+type collectionInfo struct {
+	Name   string     `json:"name"`
+	Keys   int        `json:"keys"`
+	Bytes  int        `json:"bytes"`
+	Pages  int        `json:"pages"`
+	Depth  int        `json:"depth"`
+	Fields [][]string `json:"fields,omitempty"`
+	Schema any        `json:"schema,omitempty"`
+	// ReadOnly is set for typed collections that are not registered, see Register.
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path != "api" && !strings.HasPrefix(path, "api/") {
+		h.assets.ServeHTTP(w, r)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimPrefix(path, "api"), "/"), "/", 4)
+	for i, p := range parts {
+		parts[i], _ = url.PathUnescape(p)
+	}
+	var result any
+	var err error
+	switch {
+	case parts[0] == "info" && r.Method == http.MethodGet:
+		result = map[string]any{"title": h.config.Title, "readOnly": h.ReadOnly(), "pageSize": h.config.PageSize}
+	case parts[0] == "collections" && len(parts) == 1 && r.Method == http.MethodGet:
+		result, err = h.collections()
+	case parts[0] == "collections" && len(parts) == 2 && r.Method == http.MethodGet:
+		result, err = h.collection(parts[1])
+	case parts[0] == "collections" && len(parts) == 3 && parts[2] == "documents" && r.Method == http.MethodGet:
+		result, err = h.documents(parts[1], r.URL.Query())
+	case parts[0] == "collections" && len(parts) == 4 && parts[2] == "documents":
+		result, err = h.document(w, r, parts[1], parts[3])
+	default:
+		err = &apiError{http.StatusNotFound, fmt.Errorf("%s %s not found", r.Method, r.URL.Path)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		status := http.StatusInternalServerError
+		var ae *apiError
+		switch {
+		case errors.As(err, &ae):
+			status = ae.status
+		case bingo.IsErrDocumentNotFound(err):
+			status = http.StatusNotFound
+		case bingo.IsErrDocumentExists(err), bingo.IsErrReferenced(err):
+			status = http.StatusConflict
+		case bingo.IsErrValidation(err):
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return
+	}
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// collectionNames returns the collections registered in the metadata, ignoring internal ones.
+func (h *Handler) collectionNames() ([]string, error) {
+	names, err := h.driver.GetCollections()
+	if err != nil && !bingo.IsErrDocumentNotFound(err) {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (h *Handler) stats(info *collectionInfo) error {
+	return h.driver.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(info.Name))
+		if b == nil {
+			return nil
+		}
+		s := b.Stats()
+		info.Keys = s.KeyN
+		info.Bytes = s.LeafInuse + s.BranchInuse + s.InlineBucketInuse
+		info.Pages = s.LeafPageN + s.BranchPageN + s.LeafOverflowN + s.BranchOverflowN
+		info.Depth = s.Depth
+		return nil
+	})
+}
+
+func (h *Handler) collections() (any, error) {
+	names, err := h.collectionNames()
+	if err != nil {
+		return nil, err
+	}
+	infos := []collectionInfo{}
+	for _, name := range names {
+		info := collectionInfo{Name: name}
+		if err := h.stats(&info); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (h *Handler) open(name string) (*bingo.DynamicCollection, error) {
+	names, err := h.collectionNames()
+	if err != nil {
+		return nil, err
+	}
+	i := sort.SearchStrings(names, name)
+	if i == len(names) || names[i] != name {
+		return nil, &apiError{http.StatusNotFound, fmt.Errorf("collection %s not found", name)}
+	}
+	coll := h.driver.Dynamic(name)
+	if schema, err := h.driver.JSONSchema(name); err == nil {
+		coll.Schema = schema
+	}
+	return coll, nil
+}
+
+func (h *Handler) collection(name string) (any, error) {
+	coll, err := h.open(name)
+	if err != nil {
+		return nil, err
+	}
+	info := collectionInfo{Name: name}
+	if err := h.stats(&info); err != nil {
+		return nil, err
+	}
+	info.Fields, _ = h.driver.FieldsOf(name)
+	if coll.Schema != nil {
+		info.Schema = coll.Schema
+	}
+	_, editable, err := h.editor(name)
+	if err != nil {
+		return nil, err
+	}
+	info.ReadOnly = !editable
+	return info, nil
+}
+
+// editor returns the editor of a typed collection, nil for collections edited as dynamic collections.
+// Typed collections that are not registered are not editable.
+func (h *Handler) editor(name string) (editor, bool, error) {
+	h.mu.RLock()
+	e, ok := h.typed[name]
+	h.mu.RUnlock()
+	if ok {
+		return e, true, nil
+	}
+	// typed collections record their fields when they are opened
+	if _, err := h.driver.FieldsOf(name); err == nil {
+		return nil, false, nil
+	} else if !bingo.IsErrDocumentNotFound(err) {
+		return nil, false, err
+	}
+	return nil, true, nil
+}
+
+type documentEntry struct {
+	Key      string          `json:"key"`
+	Document json.RawMessage `json:"document"`
+}
+
+// documents returns a page of documents matching the filter parameter, continuing from the cursor parameter.
+func (h *Handler) documents(name string, params url.Values) (any, error) {
+	coll, err := h.open(name)
+	if err != nil {
+		return nil, err
+	}
+	filter := bingo.Filter{}
+	if f := strings.TrimSpace(params.Get("filter")); f != "" {
+		if filter, err = bingo.ParseFilter([]byte(f)); err != nil {
+			return nil, &apiError{http.StatusBadRequest, err}
+		}
+	}
+	limit := h.config.PageSize
+	if l := params.Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			return nil, &apiError{http.StatusBadRequest, fmt.Errorf("invalid limit %q", l)}
+		}
+	}
+	skip := 0
+	if c := params.Get("cursor"); c != "" {
+		data, err := base64.RawURLEncoding.DecodeString(c)
+		if err == nil {
+			skip, err = strconv.Atoi(string(data))
+		}
+		if err != nil || skip < 0 {
+			return nil, &apiError{http.StatusBadRequest, fmt.Errorf("invalid cursor")}
+		}
+	}
+
+	typed, _, err := h.editor(name)
+	if err != nil {
+		return nil, err
+	}
+	var entries []documentEntry
+	var scanned int
+	if typed != nil {
+		entries, scanned, err = typed.list(filter, skip, limit)
+	} else {
+		entries, scanned, err = listDynamic(coll, filter, skip, limit)
+	}
+	if err != nil && !bingo.IsErrCollectionNotFound(err) {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []documentEntry{}
+	}
+	cursor := ""
+	if len(entries) >= limit {
+		cursor = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(scanned)))
+	}
+	return map[string]any{"documents": entries, "cursor": cursor}, nil
+}
+
+// listDynamic returns the documents of a dynamic collection matching the filter after skipping the first ones scanned,
+// along with the number of documents scanned.
+func listDynamic(coll *bingo.DynamicCollection, filter bingo.Filter, skip, limit int) ([]documentEntry, int, error) {
+	var entries []documentEntry
+	scanned := 0
+	err := coll.Iter(func(key []byte, doc map[string]any) error {
+		scanned += 1
+		if scanned <= skip || !filter.Match(doc) {
+			return nil
+		}
+		data, err := bingo.Marshaller.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, documentEntry{Key: string(key), Document: data})
+		if len(entries) >= limit {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return entries, scanned, err
+}
+
+// document serves a single document. Deleting requires the confirm parameter to repeat the key.
+func (h *Handler) document(w http.ResponseWriter, r *http.Request, name string, key string) (any, error) {
+	coll, err := h.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if r.Method != http.MethodGet && h.ReadOnly() {
+		return nil, &apiError{http.StatusForbidden, fmt.Errorf("the admin is in read-only mode")}
+	}
+	typed, editable, err := h.editor(name)
+	if err != nil {
+		return nil, err
+	}
+	if r.Method != http.MethodGet && !editable {
+		return nil, &apiError{http.StatusForbidden, fmt.Errorf("%s is a typed collection, register it with bingoadmin.Register to edit it", name)}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var raw []byte
+		if typed != nil {
+			raw, err = typed.get(key)
+		} else {
+			raw, err = coll.FindRawByKey(key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return documentEntry{Key: key, Document: json.RawMessage(raw)}, nil
+	case http.MethodPut:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			return nil, &apiError{http.StatusRequestEntityTooLarge, err}
+		}
+		if typed != nil {
+			data, err := typed.replace(key, body)
+			if err != nil {
+				return nil, err
+			}
+			return documentEntry{Key: key, Document: data}, nil
+		}
+		var doc map[string]any
+		if err := bingo.Unmarshaller.Unmarshal(body, &doc); err != nil || doc == nil {
+			return nil, &apiError{http.StatusBadRequest, fmt.Errorf("invalid document: the body must be a JSON object")}
+		}
+		if k := string(coll.Key(doc)); k == "" {
+			bingo.SetPath(doc, coll.KeyPath, key)
+		} else if k != key {
+			return nil, &apiError{http.StatusBadRequest, fmt.Errorf("the document key cannot be changed from %q to %q", key, k)}
+		}
+		if err := coll.Validate(doc); err != nil {
+			return nil, &apiError{http.StatusBadRequest, err}
+		}
+		// the document is looked up and replaced in a single transaction
+		var stored map[string]any
+		err = coll.UpdateByKeyFunc(key, func(current map[string]any) error {
+			for k := range current {
+				delete(current, k)
+			}
+			for k, v := range doc {
+				current[k] = v
+			}
+			stored = current
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		data, err := bingo.Marshaller.Marshal(stored)
+		if err != nil {
+			return nil, err
+		}
+		return documentEntry{Key: key, Document: data}, nil
+	case http.MethodDelete:
+		if r.URL.Query().Get("confirm") != key {
+			return nil, &apiError{http.StatusBadRequest, fmt.Errorf("deleting requires confirm=%s", key)}
+		}
+		if typed != nil {
+			return nil, typed.delete(key)
+		}
+		return nil, coll.DeleteByKey(key)
+	}
+	return nil, &apiError{http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)}
+}
@@ -0,0 +1,161 @@
+package bingoadmin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"github.com/nokusukun/bingo/bingoadmin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+type Fruit struct {
+	bingo.Document
+	Name  string `json:"name" validate:"required"`
+	Stock int    `json:"stock" validate:"gte=0"`
+}
+
+func request(t *testing.T, h http.Handler, method, path, body string) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	result := map[string]any{}
+	if strings.HasPrefix(path, "/api/") && w.Body.Len() > 0 {
+		var v any
+		if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+			t.Fatalf("Invalid response %s: %v", w.Body.String(), err)
+		}
+		if m, ok := v.(map[string]any); ok {
+			result = m
+		} else {
+			result["list"] = v
+		}
+	}
+	return w.Code, result
+}
+
+func TestAdmin(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testadmin.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testadmin.db")
+	}()
+
+	fruits := bingo.CollectionFrom[Fruit](driver, "fruits")
+	for _, name := range []string{"Apple", "Banana", "Cherry"} {
+		if _, err := fruits.Insert(Fruit{Document: bingo.Document{ID: strings.ToLower(name)}, Name: name, Stock: 3}); err != nil {
+			t.Fatalf("Failed to insert %s: %v", name, err)
+		}
+	}
+
+	h := bingoadmin.NewHandler(driver, bingoadmin.Configuration{ReadOnly: true, PageSize: 2})
+
+	if code, _ := request(t, h, "GET", "/", ""); code != http.StatusOK {
+		t.Fatalf("Expected the index page, got %d", code)
+	}
+	if code, _ := request(t, h, "GET", "/app.js", ""); code != http.StatusOK {
+		t.Fatalf("Expected the script, got %d", code)
+	}
+
+	code, result := request(t, h, "GET", "/api/collections", "")
+	list, _ := result["list"].([]any)
+	if code != http.StatusOK || len(list) != 1 || list[0].(map[string]any)["keys"].(float64) != 3 {
+		t.Fatalf("Expected fruits with 3 keys, got %d %v", code, result)
+	}
+	code, result = request(t, h, "GET", "/api/collections/fruits", "")
+	if code != http.StatusOK || len(result["fields"].([]any)) == 0 || result["schema"] == nil {
+		t.Fatalf("Expected fields and schema of fruits, got %d %v", code, result)
+	}
+
+	code, result = request(t, h, "GET", "/api/collections/fruits/documents", "")
+	if code != http.StatusOK || len(result["documents"].([]any)) != 2 || result["cursor"] == "" {
+		t.Fatalf("Expected a first page of 2, got %d %v", code, result)
+	}
+	code, result = request(t, h, "GET", "/api/collections/fruits/documents?cursor="+result["cursor"].(string), "")
+	if code != http.StatusOK || len(result["documents"].([]any)) != 1 || result["cursor"] != "" {
+		t.Fatalf("Expected a last page of 1, got %d %v", code, result)
+	}
+	code, result = request(t, h, "GET", `/api/collections/fruits/documents?filter={"Name":"Banana"}`, "")
+	if code != http.StatusOK || len(result["documents"].([]any)) != 1 {
+		t.Fatalf("Expected 1 banana, got %d %v", code, result)
+	}
+	if code, _ = request(t, h, "GET", `/api/collections/fruits/documents?filter={"Name":{"$bad":1}}`, ""); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid filter, got %d", code)
+	}
+
+	if code, _ = request(t, h, "PUT", "/api/collections/fruits/documents/apple", `{"Name": "Green Apple"}`); code != http.StatusForbidden {
+		t.Fatalf("Expected 403 in read-only mode, got %d", code)
+	}
+
+	h.SetReadOnly(false)
+	if code, _ = request(t, h, "PUT", "/api/collections/fruits/documents/apple", `{"Name": "Green Apple"}`); code != http.StatusForbidden {
+		t.Fatalf("Expected 403 editing an unregistered typed collection, got %d", code)
+	}
+	if code, result = request(t, h, "GET", "/api/collections/fruits", ""); code != http.StatusOK || result["readOnly"] != true {
+		t.Fatalf("Expected an unregistered typed collection to be read-only, got %d %v", code, result)
+	}
+	bingoadmin.Register(h, fruits)
+	if code, result = request(t, h, "GET", "/api/collections/fruits", ""); code != http.StatusOK || result["readOnly"] != nil {
+		t.Fatalf("Expected a registered typed collection to be editable, got %d %v", code, result)
+	}
+	code, result = request(t, h, "PUT", "/api/collections/fruits/documents/apple", `{"Name": "Green Apple", "Stock": 5}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the document to be saved, got %d %v", code, result)
+	}
+	apple, err := fruits.FindByKey("apple")
+	if err != nil || apple.Name != "Green Apple" || apple.Stock != 5 {
+		t.Fatalf("Expected the edit to be stored, got %+v %v", apple, err)
+	}
+	if code, result = request(t, h, "PUT", "/api/collections/fruits/documents/apple", `{"Stock": -1}`); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid document, got %d %v", code, result)
+	}
+	if code, _ = request(t, h, "PUT", "/api/collections/fruits/documents/apple", `{"_id": "pear", "Name": "Pear"}`); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 changing the key, got %d", code)
+	}
+	if code, _ = request(t, h, "PUT", "/api/collections/fruits/documents/pear", `{"Name": "Pear"}`); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 editing a missing document, got %d", code)
+	}
+
+	if code, _ = request(t, h, "DELETE", "/api/collections/fruits/documents/banana", ""); code != http.StatusBadRequest {
+		t.Fatalf("Expected deleting without confirmation to fail, got %d", code)
+	}
+	if code, _ = request(t, h, "DELETE", "/api/collections/fruits/documents/banana?confirm=banana", ""); code != http.StatusNoContent {
+		t.Fatalf("Expected the document to be deleted, got %d", code)
+	}
+	if _, err := fruits.FindByKey("banana"); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected banana to be deleted, got %v", err)
+	}
+
+	// registered collections are read through their collection, so their AfterFind hooks apply
+	fruits.Use(bingo.Hook[Fruit]{Name: "shout", Event: bingo.HookAfterFind, Func: func(doc *Fruit) error {
+		doc.Name = strings.ToUpper(doc.Name)
+		return nil
+	}})
+	code, result = request(t, h, "GET", "/api/collections/fruits/documents/apple", "")
+	if code != http.StatusOK || !strings.Contains(fmt.Sprint(result["document"]), "GREEN APPLE") {
+		t.Fatalf("Expected the document to be read through the collection, got %d %v", code, result)
+	}
+	code, result = request(t, h, "GET", "/api/collections/fruits/documents?limit=1", "")
+	documents, _ := result["documents"].([]any)
+	if code != http.StatusOK || len(documents) != 1 || result["cursor"] == "" || !strings.Contains(fmt.Sprint(documents[0]), "CHERRY") {
+		t.Fatalf("Expected a page read through the collection, got %d %v", code, result)
+	}
+	code, result = request(t, h, "GET", "/api/collections/fruits/documents?cursor="+result["cursor"].(string), "")
+	documents, _ = result["documents"].([]any)
+	if code != http.StatusOK || len(documents) != 1 || !strings.Contains(fmt.Sprint(documents[0]), "GREEN APPLE") {
+		t.Fatalf("Expected the next page read through the collection, got %d %v", code, result)
+	}
+	if code, _ = request(t, h, "GET", "/api/collections/vegetables", ""); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown collection, got %d", code)
+	}
+}
@@ -0,0 +1,165 @@
+"use strict";
+
+const state = { info: null, collection: null, collectionReadOnly: false, cursor: "", next: "", key: null };
+const $ = (id) => document.getElementById(id);
+
+async function api(path, options) {
+  const res = await fetch("api/" + path, options);
+  if (res.status === 204) {
+    return null;
+  }
+  const body = await res.json();
+  if (!res.ok) {
+    throw new Error(body.error || res.statusText);
+  }
+  return body;
+}
+
+function formatBytes(n) {
+  if (n < 1024) return n + " B";
+  if (n < 1024 * 1024) return (n / 1024).toFixed(1) + " KB";
+  return (n / 1024 / 1024).toFixed(1) + " MB";
+}
+
+async function loadCollections() {
+  const list = $("collections");
+  list.innerHTML = "";
+  for (const c of await api("collections")) {
+    const li = document.createElement("li");
+    li.innerHTML = "<span></span><small></small>";
+    li.firstChild.textContent = c.name;
+    li.lastChild.textContent = c.keys;
+    li.classList.toggle("active", c.name === state.collection);
+    li.onclick = () => openCollection(c.name);
+    list.appendChild(li);
+  }
+}
+
+async function openCollection(name) {
+  state.collection = name;
+  state.cursor = "";
+  $("filter").value = "";
+  const info = await api("collections/" + encodeURIComponent(name));
+  state.collectionReadOnly = !!info.readOnly;
+  $("collection").hidden = false;
+  $("collection-name").textContent = name;
+  $("stats").textContent = `${info.keys} documents, ${formatBytes(info.bytes)} in ${info.pages} pages, depth ${info.depth}`;
+  const fields = $("fields");
+  fields.innerHTML = "";
+  for (const aliases of info.fields || []) {
+    const li = document.createElement("li");
+    li.textContent = aliases.join(" / ");
+    fields.appendChild(li);
+  }
+  await loadCollections();
+  await loadDocuments();
+}
+
+async function loadDocuments() {
+  $("filter-error").textContent = "";
+  const params = new URLSearchParams({ limit: state.info.pageSize });
+  if ($("filter").value.trim()) params.set("filter", $("filter").value);
+  if (state.cursor) params.set("cursor", state.cursor);
+  let page;
+  try {
+    page = await api(`collections/${encodeURIComponent(state.collection)}/documents?${params}`);
+  } catch (e) {
+    $("filter-error").textContent = e.message;
+    return;
+  }
+  const body = $("documents");
+  body.innerHTML = "";
+  for (const entry of page.documents) {
+    const tr = document.createElement("tr");
+    tr.innerHTML = '<td class="key"></td><td class="doc"></td><td><button type="button"></button></td>';
+    tr.children[0].textContent = entry.key;
+    tr.children[1].textContent = JSON.stringify(entry.document);
+    const button = tr.querySelector("button");
+    button.textContent = readOnly() ? "View" : "Edit";
+    button.onclick = () => openEditor(entry.key, entry.document);
+    body.appendChild(tr);
+  }
+  state.next = page.cursor;
+  $("next").disabled = !page.cursor;
+  $("first").disabled = !state.cursor;
+}
+
+// readOnly returns true if the documents of the open collection cannot be edited.
+function readOnly() {
+  return state.info.readOnly || state.collectionReadOnly;
+}
+
+function openEditor(key, doc) {
+  state.key = key;
+  $("editor-title").textContent = key;
+  $("editor-text").value = JSON.stringify(doc, null, 2);
+  $("editor-text").readOnly = readOnly();
+  $("editor-error").textContent = "";
+  $("save").hidden = readOnly();
+  $("delete").hidden = readOnly();
+  $("editor").showModal();
+}
+
+function documentPath() {
+  return `collections/${encodeURIComponent(state.collection)}/documents/${encodeURIComponent(state.key)}`;
+}
+
+async function save() {
+  let doc;
+  try {
+    doc = JSON.parse($("editor-text").value);
+  } catch (e) {
+    $("editor-error").textContent = "Invalid JSON: " + e.message;
+    return;
+  }
+  try {
+    await api(documentPath(), { method: "PUT", body: JSON.stringify(doc) });
+  } catch (e) {
+    $("editor-error").textContent = e.message;
+    return;
+  }
+  $("editor").close();
+  await loadDocuments();
+}
+
+async function remove() {
+  if (!confirm(`Delete ${state.key} from ${state.collection}? This cannot be undone.`)) {
+    return;
+  }
+  try {
+    await api(`${documentPath()}?confirm=${encodeURIComponent(state.key)}`, { method: "DELETE" });
+  } catch (e) {
+    $("editor-error").textContent = e.message;
+    return;
+  }
+  $("editor").close();
+  await openCollection(state.collection);
+}
+
+async function main() {
+  state.info = await api("info");
+  document.title = state.info.title;
+  $("title").textContent = state.info.title;
+  $("mode").textContent = state.info.readOnly ? "read-only" : "read-write";
+  $("mode").classList.toggle("readonly", state.info.readOnly);
+  $("filter-form").onsubmit = (e) => {
+    e.preventDefault();
+    state.cursor = "";
+    loadDocuments();
+  };
+  $("next").onclick = () => {
+    state.cursor = state.next;
+    loadDocuments();
+  };
+  $("first").onclick = () => {
+    state.cursor = "";
+    loadDocuments();
+  };
+  $("save").onclick = save;
+  $("delete").onclick = remove;
+  await loadCollections();
+}
+
+main().catch((e) => {
+  document.body.textContent = e.message;
+});
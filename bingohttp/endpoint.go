@@ -0,0 +1,254 @@
+package bingohttp
+
+import (
+	"fmt"
+	"github.com/nokusukun/bingo"
+)
+
+// endpoint adapts a typed or dynamic collection to the operations exposed over HTTP.
+// Documents are exchanged in their stored JSON representation.
+type endpoint interface {
+	get(key string) ([]byte, error)
+	query(filter bingo.Filter, skip, limit int) (items [][]byte, next int, err error)
+	insert(body []byte) ([]byte, error)
+	replace(key string, body []byte) error
+	patch(key string, body []byte) error
+	delete(key string) error
+}
+
+type typedEndpoint[T bingo.DocumentSpec] struct {
+	coll *bingo.Collection[T]
+}
+
+func (e *typedEndpoint[T]) decode(body []byte) (T, error) {
+	var doc T
+	if err := bingo.Unmarshaller.Unmarshal(body, &doc); err != nil {
+		return doc, badRequest(fmt.Errorf("invalid document: %w", err))
+	}
+	return doc, nil
+}
+
+func (e *typedEndpoint[T]) get(key string) ([]byte, error) {
+	doc, err := e.coll.FindByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return bingo.Marshaller.Marshal(doc)
+}
+
+func (e *typedEndpoint[T]) query(filter bingo.Filter, skip, limit int) ([][]byte, int, error) {
+	qr := e.coll.Query(bingo.Query[T]{
+		Filter: bingo.Where[T](filter),
+		Skip:   skip,
+		Count:  limit,
+	})
+	if qr.Error != nil {
+		return nil, 0, qr.Error
+	}
+	var items [][]byte
+	for _, item := range qr.Items {
+		data, err := bingo.Marshaller.Marshal(item)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, data)
+	}
+	return items, qr.Next, nil
+}
+
+func (e *typedEndpoint[T]) insert(body []byte) ([]byte, error) {
+	doc, err := e.decode(body)
+	if err != nil {
+		return nil, err
+	}
+	return e.coll.Insert(doc)
+}
+
+func (e *typedEndpoint[T]) replace(key string, body []byte) error {
+	doc, err := e.decode(body)
+	if err != nil {
+		return err
+	}
+	if len(doc.Key()) == 0 {
+		_ = bingo.SetKey(&doc, []byte(key))
+	}
+	if string(doc.Key()) != key {
+		return badRequest(fmt.Errorf("document key %q does not match %q", doc.Key(), key))
+	}
+	return replace(func() error {
+		return e.coll.UpdateByKeyFunc(key, func(stored *T) error {
+			*stored = doc
+			return nil
+		})
+	}, func() error {
+		_, err := e.coll.Insert(doc)
+		return err
+	})
+}
+
+func (e *typedEndpoint[T]) patch(key string, body []byte) error {
+	// the document is read, merged and written in a single transaction, so concurrent patches are not lost
+	return e.coll.UpdateByKeyFunc(key, func(doc *T) error {
+		current, err := bingo.Marshaller.Marshal(*doc)
+		if err != nil {
+			return err
+		}
+		merged, err := mergePatch(current, body)
+		if err != nil {
+			return err
+		}
+		patched, err := e.decode(merged)
+		if err != nil {
+			return err
+		}
+		if string(patched.Key()) != key {
+			return badRequest(fmt.Errorf("the document key cannot be changed"))
+		}
+		*doc = patched
+		return nil
+	})
+}
+
+func (e *typedEndpoint[T]) delete(key string) error {
+	return e.coll.DeleteByKey(key)
+}
+
+type dynamicEndpoint struct {
+	coll *bingo.DynamicCollection
+}
+
+func (e *dynamicEndpoint) decode(body []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := bingo.Unmarshaller.Unmarshal(body, &doc); err != nil || doc == nil {
+		return nil, badRequest(fmt.Errorf("invalid document: %v", err))
+	}
+	return doc, nil
+}
+
+func (e *dynamicEndpoint) get(key string) ([]byte, error) {
+	return e.coll.FindRawByKey(key)
+}
+
+func (e *dynamicEndpoint) query(filter bingo.Filter, skip, limit int) ([][]byte, int, error) {
+	var items [][]byte
+	last := 0
+	err := e.coll.Iter(func(key []byte, doc map[string]any) error {
+		last += 1
+		if last <= skip {
+			return nil
+		}
+		if !filter.Match(doc) {
+			return nil
+		}
+		data, err := bingo.Marshaller.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		items = append(items, data)
+		if limit > 0 && len(items) >= limit {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, 0, err
+	}
+	return items, last, nil
+}
+
+func (e *dynamicEndpoint) insert(body []byte) ([]byte, error) {
+	doc, err := e.decode(body)
+	if err != nil {
+		return nil, err
+	}
+	return e.coll.Insert(doc)
+}
+
+func (e *dynamicEndpoint) replace(key string, body []byte) error {
+	doc, err := e.decode(body)
+	if err != nil {
+		return err
+	}
+	if k := e.coll.Key(doc); len(k) == 0 {
+		bingo.SetPath(doc, e.coll.KeyPath, key)
+	} else if string(k) != key {
+		return badRequest(fmt.Errorf("document key %q does not match %q", k, key))
+	}
+	return replace(func() error {
+		return e.coll.UpdateByKeyFunc(key, func(stored map[string]any) error {
+			for k := range stored {
+				delete(stored, k)
+			}
+			for k, v := range doc {
+				stored[k] = v
+			}
+			return nil
+		})
+	}, func() error {
+		_, err := e.coll.Insert(doc)
+		return err
+	})
+}
+
+func (e *dynamicEndpoint) patch(key string, body []byte) error {
+	var p map[string]any
+	if err := bingo.Unmarshaller.Unmarshal(body, &p); err != nil || p == nil {
+		return badRequest(fmt.Errorf("invalid patch: %v", err))
+	}
+	return e.coll.UpdateByKeyFunc(key, func(doc map[string]any) error {
+		mergeObjects(doc, p)
+		if string(e.coll.Key(doc)) != key {
+			return badRequest(fmt.Errorf("the document key cannot be changed"))
+		}
+		return nil
+	})
+}
+
+func (e *dynamicEndpoint) delete(key string) error {
+	return e.coll.DeleteByKey(key)
+}
+
+// replace updates a stored document through the update lifecycle, so it keeps its createdAt fields, or inserts it
+// when there is none. A document created or deleted concurrently is retried, so concurrent replaces of a key succeed.
+func replace(update, insert func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := update()
+		if !bingo.IsErrDocumentNotFound(err) && !bingo.IsErrCollectionNotFound(err) {
+			return err
+		}
+		if err = insert(); !bingo.IsErrDocumentExists(err) || attempt == 2 {
+			return err
+		}
+	}
+}
+
+// mergePatch applies a JSON merge patch (RFC 7396) to a document.
+func mergePatch(document, patch []byte) ([]byte, error) {
+	var doc, p map[string]any
+	if err := bingo.Unmarshaller.Unmarshal(document, &doc); err != nil {
+		return nil, err
+	}
+	if err := bingo.Unmarshaller.Unmarshal(patch, &p); err != nil || p == nil {
+		return nil, badRequest(fmt.Errorf("invalid patch: %v", err))
+	}
+	return bingo.Marshaller.Marshal(mergeObjects(doc, p))
+}
+
+func mergeObjects(doc, patch map[string]any) map[string]any {
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		if sub, ok := v.(map[string]any); ok {
+			current, _ := doc[k].(map[string]any)
+			doc[k] = mergeObjects(current, sub)
+			continue
+		}
+		doc[k] = v
+	}
+	return doc
+}
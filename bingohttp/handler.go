@@ -0,0 +1,405 @@
+// Package bingohttp exposes bingo collections as a JSON REST API.
+//
+//	h := bingohttp.NewHandler()
+//	bingohttp.Register(h, users, bingohttp.Allow(bingohttp.Get, bingohttp.Query, bingohttp.Insert))
+//	http.Handle("/api/", http.StripPrefix("/api", h))
+//
+// Routes, relative to where the handler is mounted:
+//
+//	GET    /                       list the registered collections
+//	GET    /{collection}           query with URL parameters (?name=john&limit=10&cursor=...)
+//	POST   /{collection}/_query    query with a JSON filter body
+//	POST   /{collection}           insert a document
+//	GET    /{collection}/{key}     get a document
+//	PUT    /{collection}/{key}     replace (or create) a document
+//	PATCH  /{collection}/{key}     apply a JSON merge patch to a document
+//	DELETE /{collection}/{key}     delete a document
+package bingohttp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/nokusukun/bingo"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Operation is an operation that can be allowed on a registered collection.
+type Operation string
+
+const (
+	Get     Operation = "get"
+	Query   Operation = "query"
+	Insert  Operation = "insert"
+	Replace Operation = "replace"
+	Patch   Operation = "patch"
+	Delete  Operation = "delete"
+)
+
+// AllOperations contains every operation, see AllowAll.
+var AllOperations = []Operation{Get, Query, Insert, Replace, Patch, Delete}
+
+var errStop = errors.New("stop")
+
+type options struct {
+	name     string
+	allow    map[Operation]bool
+	pageSize int
+	maxPage  int
+}
+
+// Option configures a registered collection.
+type Option func(opts *options)
+
+// Allow sets the operations allowed on the collection. Collections only allow Get and Query by default.
+func Allow(ops ...Operation) Option {
+	return func(opts *options) {
+		opts.allow = map[Operation]bool{}
+		for _, op := range ops {
+			opts.allow[op] = true
+		}
+	}
+}
+
+// AllowAll allows every operation on the collection.
+func AllowAll() Option {
+	return Allow(AllOperations...)
+}
+
+// Name sets the path segment the collection is exposed under, it defaults to the collection name.
+func Name(name string) Option {
+	return func(opts *options) {
+		opts.name = name
+	}
+}
+
+// PageSize sets the default and maximum number of documents returned by a query.
+func PageSize(size, max int) Option {
+	return func(opts *options) {
+		opts.pageSize = size
+		opts.maxPage = max
+	}
+}
+
+type registration struct {
+	endpoint endpoint
+	options  options
+}
+
+// Handler is an http.Handler serving the registered collections.
+type Handler struct {
+	// MaxBodyBytes limits the size of request bodies, defaults to 1MB.
+	MaxBodyBytes int64
+
+	mu          sync.RWMutex
+	collections map[string]*registration
+}
+
+// NewHandler creates a handler without any collection registered.
+func NewHandler() *Handler {
+	return &Handler{
+		MaxBodyBytes: 1 << 20,
+		collections:  map[string]*registration{},
+	}
+}
+
+func (h *Handler) register(name string, e endpoint, opts []Option) {
+	o := options{
+		name:     name,
+		allow:    map[Operation]bool{Get: true, Query: true},
+		pageSize: 50,
+		maxPage:  1000,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.collections[o.name] = &registration{endpoint: e, options: o}
+}
+
+// Register exposes a typed collection through the handler.
+func Register[T bingo.DocumentSpec](h *Handler, coll *bingo.Collection[T], opts ...Option) {
+	h.register(coll.Name, &typedEndpoint[T]{coll: coll}, opts)
+}
+
+// RegisterDynamic exposes a dynamic collection through the handler.
+func (h *Handler) RegisterDynamic(coll *bingo.DynamicCollection, opts ...Option) {
+	h.register(coll.Name, &dynamicEndpoint{coll: coll}, opts)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"collections": h.names()})
+		return
+	}
+
+	name, key, _ := strings.Cut(path, "/")
+	name, _ = url.PathUnescape(name)
+	key, _ = url.PathUnescape(key)
+	h.mu.RLock()
+	reg, ok := h.collections[name]
+	h.mu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("collection %s not found", name))
+		return
+	}
+
+	var op Operation
+	switch {
+	case key == "" && r.Method == http.MethodGet, key == "_query" && r.Method == http.MethodPost:
+		op = Query
+	case key == "" && r.Method == http.MethodPost:
+		op = Insert
+	case key != "" && r.Method == http.MethodGet:
+		op = Get
+	case key != "" && r.Method == http.MethodPut:
+		op = Replace
+	case key != "" && r.Method == http.MethodPatch:
+		op = Patch
+	case key != "" && r.Method == http.MethodDelete:
+		op = Delete
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if !reg.options.allow[op] {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not allowed on %s", op, name))
+		return
+	}
+
+	var body []byte
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, h.MaxBodyBytes))
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+	}
+
+	e := reg.endpoint
+	switch op {
+	case Query:
+		h.query(w, r, reg, body)
+	case Get:
+		doc, err := e.get(key)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"result": json.RawMessage(doc)})
+	case Insert:
+		id, err := e.insert(body)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+		h.writeDocument(w, http.StatusCreated, e, string(id))
+	case Replace:
+		if err := e.replace(key, body); err != nil {
+			writeFailure(w, err)
+			return
+		}
+		h.writeDocument(w, http.StatusOK, e, key)
+	case Patch:
+		if err := e.patch(key, body); err != nil {
+			writeFailure(w, err)
+			return
+		}
+		h.writeDocument(w, http.StatusOK, e, key)
+	case Delete:
+		if err := e.delete(key); err != nil {
+			writeFailure(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) names() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := make([]string, 0, len(h.collections))
+	for name := range h.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (h *Handler) writeDocument(w http.ResponseWriter, status int, e endpoint, key string) {
+	doc, err := e.get(key)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, status, map[string]any{"key": key, "result": json.RawMessage(doc)})
+}
+
+// query serves a page of documents. The filter is read from the request body, or built from the URL parameters
+// where every parameter except limit, cursor and filter is an equality condition.
+func (h *Handler) query(w http.ResponseWriter, r *http.Request, reg *registration, body []byte) {
+	params := r.URL.Query()
+	var filter bingo.Filter
+	var err error
+	switch {
+	case len(strings.TrimSpace(string(body))) > 0:
+		filter, err = bingo.ParseFilter(body)
+	case params.Get("filter") != "":
+		filter, err = bingo.ParseFilter([]byte(params.Get("filter")))
+	default:
+		filter = bingo.Filter{}
+		for k, values := range params {
+			if k == "limit" || k == "cursor" {
+				continue
+			}
+			filter[k] = paramValue(values[0])
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := reg.options.pageSize
+	if l := params.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", l))
+			return
+		}
+	}
+	if limit > reg.options.maxPage {
+		limit = reg.options.maxPage
+	}
+	skip, err := decodeCursor(params.Get("cursor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	items, next, err := reg.endpoint.query(filter, skip, limit)
+	if err != nil && !isMissingCollection(err) {
+		writeFailure(w, err)
+		return
+	}
+	result := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	cursor := ""
+	if len(items) == limit {
+		cursor = encodeCursor(next)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"result": result,
+		"count":  len(result),
+		"next":   next,
+		"cursor": cursor,
+	})
+}
+
+// paramValue interprets URL parameter values as JSON scalars where possible, so ?age=3 matches numbers.
+func paramValue(s string) any {
+	var v any
+	if err := bingo.Unmarshaller.Unmarshal([]byte(s), &v); err == nil {
+		switch v.(type) {
+		case float64, bool, nil:
+			return v
+		}
+	}
+	return s
+}
+
+func encodeCursor(next int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(next)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	skip, err := strconv.Atoi(string(data))
+	if err != nil || skip < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return skip, nil
+}
+
+// isMissingCollection returns true for errors caused by querying a collection nothing was written to yet.
+func isMissingCollection(err error) bool {
+	return bingo.IsErrCollectionNotFound(err)
+}
+
+type badRequestError struct {
+	err error
+}
+
+func (e *badRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *badRequestError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(err error) error {
+	return &badRequestError{err: err}
+}
+
+// statusOf maps bingo errors to HTTP status codes.
+func statusOf(err error) int {
+	var br *badRequestError
+	var ve validator.ValidationErrors
+	switch {
+	case errors.As(err, &br), errors.As(err, &ve), bingo.IsErrValidation(err), bingo.IsErrSchemaViolation(err):
+		return http.StatusBadRequest
+	case bingo.IsErrDocumentNotFound(err), bingo.IsErrCollectionNotFound(err):
+		return http.StatusNotFound
+	case bingo.IsErrDocumentExists(err), bingo.IsErrReferenced(err):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func writeFailure(w http.ResponseWriter, err error) {
+	writeError(w, statusOf(err), err)
+}
+
+// writeError writes an error response, validation errors list the message of every failing field.
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := map[string]any{"error": err.Error()}
+	var verr *bingo.ValidationError
+	if errors.As(err, &verr) && len(verr.Fields) > 0 {
+		fields := map[string]string{}
+		for _, f := range verr.Fields {
+			fields[f.Namespace] = f.Error()
+		}
+		body["fields"] = fields
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
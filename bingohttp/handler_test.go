@@ -0,0 +1,204 @@
+package bingohttp_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"github.com/nokusukun/bingo/bingohttp"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Person struct {
+	bingo.Document
+	Name string `json:"name" validate:"required"`
+	Age  int    `json:"age"`
+	// Created is kept by PUT, which replaces stored documents through the update lifecycle
+	Created time.Time `json:"created" bingo:"createdAt"`
+}
+
+type response struct {
+	Key    string            `json:"key"`
+	Result json.RawMessage   `json:"result"`
+	Count  int               `json:"count"`
+	Cursor string            `json:"cursor"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields"`
+}
+
+func request(t *testing.T, h http.Handler, method, path, body string) (int, response) {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var resp response
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Invalid response %s: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestHandler(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testhttp.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testhttp.db")
+	}()
+
+	people := bingo.CollectionFrom[Person](driver, "people")
+	readonly := bingo.CollectionFrom[Person](driver, "readonly")
+	h := bingohttp.NewHandler()
+	bingohttp.Register(h, people, bingohttp.AllowAll(), bingohttp.PageSize(2, 10))
+	bingohttp.Register(h, readonly)
+	h.RegisterDynamic(driver.Dynamic("notes"), bingohttp.AllowAll())
+
+	code, resp := request(t, h, "POST", "/people", `{"_id": "john", "Name": "John", "Age": 30}`)
+	if code != http.StatusCreated || resp.Key != "john" {
+		t.Fatalf("Expected 201 with key john, got %d %+v", code, resp)
+	}
+	if code, _ = request(t, h, "POST", "/people", `{"_id": "john", "Name": "John"}`); code != http.StatusConflict {
+		t.Fatalf("Expected 409 for an existing document, got %d", code)
+	}
+	if code, resp = request(t, h, "POST", "/people", `{"Age": 3}`); code != http.StatusBadRequest || resp.Fields["Person.Name"] != "Name is required" {
+		t.Fatalf("Expected 400 for an invalid document, got %d %+v", code, resp.Fields)
+	}
+	for _, name := range []string{"Jane", "Jack", "Jill"} {
+		if code, resp = request(t, h, "POST", "/people", `{"Name": "`+name+`", "Age": 20}`); code != http.StatusCreated {
+			t.Fatalf("Failed to insert %s: %d %s", name, code, resp.Error)
+		}
+	}
+
+	code, resp = request(t, h, "GET", "/people/john", "")
+	if code != http.StatusOK || !strings.Contains(string(resp.Result), `"John"`) {
+		t.Fatalf("Expected john, got %d %s", code, resp.Result)
+	}
+	if code, _ = request(t, h, "GET", "/people/nobody", ""); code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %d", code)
+	}
+
+	// Pages of 2 over the 3 documents with Age 20
+	code, resp = request(t, h, "GET", "/people?Age=20", "")
+	if code != http.StatusOK || resp.Count != 2 || resp.Cursor == "" {
+		t.Fatalf("Expected a first page of 2 with a cursor, got %d %+v", code, resp)
+	}
+	code, resp = request(t, h, "GET", "/people?Age=20&cursor="+resp.Cursor, "")
+	if code != http.StatusOK || resp.Count != 1 || resp.Cursor != "" {
+		t.Fatalf("Expected a last page of 1 without a cursor, got %d %+v", code, resp)
+	}
+	code, resp = request(t, h, "POST", "/people/_query?limit=10", `{"Age": {"$gte": 25}}`)
+	if code != http.StatusOK || resp.Count != 1 {
+		t.Fatalf("Expected 1 document older than 25, got %d %+v", code, resp)
+	}
+	if code, _ = request(t, h, "POST", "/people/_query", `{"Age": {"$bogus": 1}}`); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid filter, got %d", code)
+	}
+
+	code, resp = request(t, h, "PATCH", "/people/john", `{"Age": 31}`)
+	if code != http.StatusOK || !strings.Contains(string(resp.Result), `"Age":31`) || !strings.Contains(string(resp.Result), `"John"`) {
+		t.Fatalf("Expected patched document, got %d %s", code, resp.Result)
+	}
+	john, _ := people.FindByKey("john")
+	code, resp = request(t, h, "PUT", "/people/john", `{"Name": "Johnny"}`)
+	if code != http.StatusOK || !strings.Contains(string(resp.Result), `"Age":0`) {
+		t.Fatalf("Expected replaced document, got %d %s", code, resp.Result)
+	}
+	if johnny, _ := people.FindByKey("john"); johnny.Created.IsZero() || !johnny.Created.Equal(john.Created) {
+		t.Fatalf("Expected the replaced document to keep its creation time, got %v and %v", johnny.Created, john.Created)
+	}
+	if code, _ = request(t, h, "PUT", "/people/john", `{"_id": "other", "Name": "Johnny"}`); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for a mismatched key, got %d", code)
+	}
+	if code, _ = request(t, h, "PUT", "/people/jim", `{"Name": "Jim"}`); code != http.StatusOK {
+		t.Fatalf("Expected PUT to create jim, got %d", code)
+	}
+	// concurrent creations of the same key all succeed
+	codes := make(chan int, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("PUT", "/people/racer", strings.NewReader(`{"Name": "Racer"}`)))
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("Expected concurrent PUTs to succeed, got %d", code)
+		}
+	}
+	if code, _ = request(t, h, "DELETE", "/people/john", ""); code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", code)
+	}
+	if code, _ = request(t, h, "DELETE", "/people/john", ""); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 deleting twice, got %d", code)
+	}
+	if code, _ = request(t, h, "PATCH", "/people/john", `{"Age": 32}`); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 patching a deleted document, got %d", code)
+	}
+
+	// Read only collections
+	if code, resp = request(t, h, "GET", "/readonly", ""); code != http.StatusOK || resp.Count != 0 {
+		t.Fatalf("Expected an empty result from an empty collection, got %d %+v", code, resp)
+	}
+	if code, _ = request(t, h, "POST", "/readonly", `{"Name": "x"}`); code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405 inserting into a read only collection, got %d", code)
+	}
+	if code, _ = request(t, h, "GET", "/missing", ""); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for an unregistered collection, got %d", code)
+	}
+
+	// Dynamic collections
+	code, resp = request(t, h, "POST", "/notes", `{"text": "hello", "tags": ["a"]}`)
+	if code != http.StatusCreated || resp.Key == "" {
+		t.Fatalf("Expected a dynamic insert, got %d %+v", code, resp)
+	}
+	key := resp.Key
+	code, resp = request(t, h, "PATCH", "/notes/"+key, `{"tags": null, "done": true}`)
+	if code != http.StatusOK || strings.Contains(string(resp.Result), "tags") || !strings.Contains(string(resp.Result), `"done":true`) {
+		t.Fatalf("Expected patched dynamic document, got %d %s", code, resp.Result)
+	}
+	// concurrent patches of different fields are all kept
+	wg = sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("PATCH", "/notes/"+key, strings.NewReader(fmt.Sprintf(`{"f%d": %d}`, i, i))))
+		}(i)
+	}
+	wg.Wait()
+	code, resp = request(t, h, "GET", "/notes/"+key, "")
+	for i := 0; i < 8; i++ {
+		if !strings.Contains(string(resp.Result), fmt.Sprintf(`"f%d":%d`, i, i)) {
+			t.Fatalf("Expected every concurrent patch to be kept, got %d %s", code, resp.Result)
+		}
+	}
+	code, resp = request(t, h, "GET", "/notes?done=true", "")
+	if code != http.StatusOK || resp.Count != 1 {
+		t.Fatalf("Expected 1 done note, got %d %+v", code, resp)
+	}
+
+	code, _ = request(t, h, "GET", "/", "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected collection listing, got %d", code)
+	}
+}
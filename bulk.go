@@ -0,0 +1,213 @@
+package bingo
+
+import (
+	"fmt"
+	"go.etcd.io/bbolt"
+)
+
+// BulkOptions configures a BulkLoader.
+// BatchSize and BatchBytes bound a transaction, a batch is committed when either is reached.
+// They default to 1000 documents and 4MB.
+// Sequential sets the bucket fill percent to 100%, which packs pages densely when keys are inserted in ascending order.
+// The embedded InsertOptions select the conflict policy like they do for InsertMany.
+// OnProgress, if set, is called after every committed batch.
+type BulkOptions struct {
+	InsertOptions
+	BatchSize  int
+	BatchBytes int
+	Sequential bool
+	OnProgress func(progress BulkProgress)
+}
+
+// BulkProgress reports the state of a bulk load.
+type BulkProgress struct {
+	Loaded  int
+	Failed  int
+	Bytes   int64
+	Batches int
+}
+
+// BulkError is the error of a single document of a bulk load, Index is the position of the document in the input.
+type BulkError struct {
+	Index int
+	Key   []byte
+	Err   error
+}
+
+func (e BulkError) Error() string {
+	return fmt.Sprintf("document %d (%s): %v", e.Index, e.Key, e.Err)
+}
+
+func (e BulkError) Unwrap() error {
+	return e.Err
+}
+
+// BulkResult is the outcome of a bulk load.
+type BulkResult struct {
+	BulkProgress
+	Errors []BulkError
+}
+
+type bulkEntry[T DocumentSpec] struct {
+	index int
+	key   []byte
+	data  []byte
+	doc   T
+}
+
+// BulkLoader inserts large amounts of documents in batched transactions.
+// Documents run the validate and before phases when added, see Phase. The after-transaction phase runs in the
+// transaction of their batch and fails the whole batch, AfterInsert runs once their batch is committed.
+// Errors of single documents are collected in the result instead of aborting the load, a document failing to be stored
+// writes none of its index entries.
+type BulkLoader[T DocumentSpec] struct {
+	coll    *Collection[T]
+	opts    BulkOptions
+	pending []bulkEntry[T]
+	bytes   int
+	index   int
+	result  BulkResult
+}
+
+// BulkLoader creates a loader inserting into the collection.
+func (c *Collection[T]) BulkLoader(opts BulkOptions) *BulkLoader[T] {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = 4 << 20
+	}
+	return &BulkLoader[T]{coll: c, opts: opts}
+}
+
+func (l *BulkLoader[T]) fail(index int, key []byte, err error) {
+	l.result.Failed += 1
+	l.result.Errors = append(l.result.Errors, BulkError{Index: index, Key: key, Err: err})
+}
+
+// Add queues a document, committing the pending batch when it is full.
+// The returned error is only set when committing a batch fails.
+func (l *BulkLoader[T]) Add(doc T) error {
+	index := l.index
+	l.index += 1
+
+	c := l.coll
+	if c.options.ReadOnly {
+		l.fail(index, doc.Key(), ErrReadOnly)
+		return nil
+	}
+	if err := c.prepare(insertLifecycle, &doc); err != nil {
+		l.fail(index, doc.Key(), err)
+		return nil
+	}
+	key := doc.Key()
+	// documents without a key are keyed and encoded again once their batch is committed
+	data, err := c.encode(doc)
+	if err != nil {
+		l.fail(index, key, err)
+		return nil
+	}
+
+	l.pending = append(l.pending, bulkEntry[T]{index: index, key: key, data: data, doc: doc})
+	l.bytes += len(key) + len(data)
+	if len(l.pending) >= l.opts.BatchSize || l.bytes >= l.opts.BatchBytes {
+		return l.Flush()
+	}
+	return nil
+}
+
+// Flush commits the pending documents.
+func (l *BulkLoader[T]) Flush() error {
+	if len(l.pending) == 0 {
+		return nil
+	}
+	c := l.coll
+	var stored []bulkEntry[T]
+	var failed []BulkError
+	err := c.update(false, func(tx *bbolt.Tx) error {
+		stored, failed = stored[:0], failed[:0]
+		bucket, err := tx.CreateBucketIfNotExists(c.nameBytes)
+		if err != nil {
+			return err
+		}
+		if l.opts.Sequential {
+			bucket.FillPercent = 1.0
+		}
+		for _, entry := range l.pending {
+			if len(entry.key) == 0 {
+				if entry.key, err = c.getKey(bucket, &entry.doc); err != nil {
+					return err
+				}
+				if entry.data, err = c.encode(entry.doc); err != nil {
+					return err
+				}
+			}
+			if !l.opts.Upsert && bucket.Get(entry.key) != nil {
+				if !l.opts.IgnoreErrors {
+					failed = append(failed, BulkError{Index: entry.index, Key: entry.key, Err: documentExists(l.coll.Name, entry.key)})
+				}
+				continue
+			}
+			if err := c.put(tx, bucket, entry.key, entry.data, &entry.doc); err != nil {
+				failed = append(failed, BulkError{Index: entry.index, Key: entry.key, Err: err})
+				continue
+			}
+			// the document is stored already, so an AfterTx hook fails the whole batch
+			if err := c.written(insertLifecycle, &entry.doc); err != nil {
+				return err
+			}
+			stored = append(stored, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range failed {
+		l.fail(e.Index, e.Key, e.Err)
+	}
+	for _, entry := range stored {
+		l.result.Loaded += 1
+		l.result.Bytes += int64(len(entry.data))
+		if err := c.committed(insertLifecycle, &entry.doc); err != nil {
+			l.fail(entry.index, entry.key, err)
+		}
+	}
+	l.result.Batches += 1
+	l.pending = l.pending[:0]
+	l.bytes = 0
+	if l.opts.OnProgress != nil {
+		l.opts.OnProgress(l.result.BulkProgress)
+	}
+	return nil
+}
+
+// Close commits the pending documents and returns the result of the load.
+func (l *BulkLoader[T]) Close() (*BulkResult, error) {
+	err := l.Flush()
+	return &l.result, err
+}
+
+// LoadChan adds every document received from ch until it is closed.
+func (l *BulkLoader[T]) LoadChan(ch <-chan T) (*BulkResult, error) {
+	for doc := range ch {
+		if err := l.Add(doc); err != nil {
+			return &l.result, err
+		}
+	}
+	return l.Close()
+}
+
+// LoadSeq adds every document yielded by seq, it has the shape of a range-over-func iterator.
+func (l *BulkLoader[T]) LoadSeq(seq func(yield func(T) bool)) (*BulkResult, error) {
+	var err error
+	seq(func(doc T) bool {
+		err = l.Add(doc)
+		return err == nil
+	})
+	if err != nil {
+		return &l.result, err
+	}
+	return l.Close()
+}
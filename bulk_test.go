@@ -0,0 +1,117 @@
+package bingo_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBulkLoader(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testbulk.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testbulk.db")
+	}()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "bulk")
+	if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "doc-0005"}, Name: "existing"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	after := 0
+	coll.AfterInsert(func(doc *TestDocument) error {
+		after += 1
+		return nil
+	})
+
+	var progress []bingo.BulkProgress
+	loader := coll.BulkLoader(bingo.BulkOptions{
+		BatchSize:  10,
+		Sequential: true,
+		OnProgress: func(p bingo.BulkProgress) {
+			progress = append(progress, p)
+		},
+	})
+	result, err := loader.LoadSeq(func(yield func(TestDocument) bool) {
+		for i := 0; i < 100; i++ {
+			doc := TestDocument{Document: bingo.Document{ID: fmt.Sprintf("doc-%04d", i)}, Name: fmt.Sprintf("name %d", i)}
+			if i == 42 {
+				doc.Name = ""
+			}
+			if !yield(doc) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("Failed to bulk load: %v", err)
+	}
+	if result.Loaded != 98 || result.Failed != 2 || len(result.Errors) != 2 {
+		t.Fatalf("Expected 98 loaded and 2 failed, got %+v", result)
+	}
+	if result.Errors[0].Index != 5 || !bingo.IsErrDocumentExists(result.Errors[0]) || result.Errors[1].Index != 42 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if after != 98 {
+		t.Fatalf("Expected AfterInsert to run for every loaded document, ran %d times", after)
+	}
+	if len(progress) == 0 || progress[len(progress)-1].Loaded != 98 {
+		t.Fatalf("Expected progress reports, got %v", progress)
+	}
+
+	ch := make(chan TestDocument)
+	go func() {
+		for i := 0; i < 25; i++ {
+			ch <- TestDocument{Name: fmt.Sprintf("generated %d", i)}
+		}
+		ch <- TestDocument{Document: bingo.Document{ID: "doc-0001"}, Name: "replaced"}
+		close(ch)
+	}()
+	result, err = coll.BulkLoader(bingo.BulkOptions{InsertOptions: bingo.InsertOptions{Upsert: true}, BatchBytes: 512}).LoadChan(ch)
+	if err != nil || result.Loaded != 26 || result.Failed != 0 || result.Batches < 2 {
+		t.Fatalf("Expected 26 documents over several batches, got %+v %v", result, err)
+	}
+	if doc, err := coll.FindByKey("doc-0001"); err != nil || doc.Name != "replaced" {
+		t.Fatalf("Expected doc-0001 to be replaced, got %+v %v", doc, err)
+	}
+	count := 0
+	_, _ = coll.Find(func(doc TestDocument) bool {
+		count += 1
+		return false
+	})
+	if count != 124 {
+		t.Fatalf("Expected 124 documents, got %d", count)
+	}
+
+	// a document failing to store leaves none of its index entries behind
+	indexed, err := bingo.OpenCollection[TestDocument](driver, "bulkindexed", bingo.WithIndex("Name", "_id"))
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	loader = indexed.BulkLoader(bingo.BulkOptions{})
+	_ = loader.Add(TestDocument{Document: bingo.Document{ID: strings.Repeat("k", 20000)}, Name: "large"})
+	_ = loader.Add(TestDocument{Document: bingo.Document{ID: "small"}, Name: "small"})
+	result, err = loader.Close()
+	if err != nil || result.Loaded != 1 || result.Failed != 1 || !errors.Is(result.Errors[0], bbolt.ErrKeyTooLarge) {
+		t.Fatalf("Expected the large document to fail alone, got %+v %v", result, err)
+	}
+	err = driver.View(func(tx *bbolt.Tx) error {
+		if n := tx.Bucket([]byte("__index:bulkindexed:Name")).Stats().KeyN; n != 1 {
+			return fmt.Errorf("expected 1 Name index entry, got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected index entries: %v", err)
+	}
+}
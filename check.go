@@ -0,0 +1,375 @@
+package bingo
+
+import (
+	"bytes"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const QUARANTINE_COLLECTION_NAME = "__quarantine:"
+
+// IssueKind is the kind of problem found by Driver.Check.
+type IssueKind string
+
+const (
+	// IssueUndecodable is a stored value that does not unmarshal into the collection's type.
+	IssueUndecodable IssueKind = "undecodable"
+	// IssueKeyMismatch is a document stored under a key that differs from its Key().
+	IssueKeyMismatch IssueKind = "key-mismatch"
+	// IssueInvalid is a document failing the validation of the collection's type.
+	IssueInvalid IssueKind = "invalid"
+	// IssueOrphanedMetadata is a fields, schema or json schema entry of a collection that no longer exists.
+	IssueOrphanedMetadata IssueKind = "orphaned-metadata"
+	// IssueMissingBucket is a collection recorded in the metadata without a bucket, nothing was ever written to it.
+	IssueMissingBucket IssueKind = "missing-bucket"
+	// IssueUnrecordedBucket is a bucket that is not recorded as a collection in the metadata.
+	IssueUnrecordedBucket IssueKind = "unrecorded-bucket"
+	// IssueDanglingEntry is an index, reference index or expiry entry of a document that is not stored.
+	IssueDanglingEntry IssueKind = "dangling-entry"
+)
+
+// CheckIssue is a single problem found by Driver.Check.
+// Repaired is set when the problem was fixed by the repair mode.
+type CheckIssue struct {
+	Kind       IssueKind
+	Collection string
+	Key        []byte
+	Message    string
+	Repaired   bool
+
+	// bucket and entry locate a dangling entry for the repair mode
+	bucket []byte
+	entry  []byte
+}
+
+func (i CheckIssue) String() string {
+	s := fmt.Sprintf("%s: %s", i.Kind, i.Collection)
+	if i.Key != nil {
+		s += fmt.Sprintf(" %q", i.Key)
+	}
+	if i.Message != "" {
+		s += ": " + i.Message
+	}
+	if i.Repaired {
+		s += " (repaired)"
+	}
+	return s
+}
+
+// CheckReport is the result of Driver.Check.
+type CheckReport struct {
+	Collections int
+	Documents   int
+	Issues      []CheckIssue
+}
+
+// OK returns true if no issue was found.
+func (r *CheckReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// CheckOptions configures Driver.Check.
+// Repair moves undecodable documents and documents with mismatching keys to a __quarantine:<collection> bucket,
+// dropping their index entries and expiry. It removes orphaned metadata and dangling entries and records unrecorded
+// buckets as collections. Invalid documents are only reported.
+type CheckOptions struct {
+	Repair bool
+}
+
+func Repair(opts *CheckOptions) {
+	opts.Repair = true
+}
+
+// Check walks every collection and the metadata and reports inconsistencies.
+// Documents of collections opened with CollectionFrom are decoded into their type, keyed and validated,
+// documents of other collections are only checked to be JSON objects.
+func (d *Driver) Check(opts ...func(options *CheckOptions)) (*CheckReport, error) {
+	opt := &CheckOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	report := &CheckReport{}
+	recorded := map[string]bool{}
+	var orphaned []string
+	var buckets []string
+
+	err := d.view(func(tx *bbolt.Tx) error {
+		if meta := tx.Bucket([]byte(METADATA_COLLECTION_NAME)); meta != nil {
+			_ = meta.ForEach(func(k, v []byte) error {
+				var m Metadata
+				if Unmarshaller.Unmarshal(v, &m) == nil && strings.HasPrefix(m.K, "collection:") {
+					active, _ := m.V.(bool)
+					recorded[strings.TrimPrefix(m.K, "collection:")] = active
+				}
+				return nil
+			})
+		}
+		_ = tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			buckets = append(buckets, string(name))
+			return nil
+		})
+
+		for _, name := range buckets {
+			if strings.HasPrefix(name, "__") {
+				report.Issues = append(report.Issues, danglingEntries(tx, name)...)
+				continue
+			}
+			report.Collections += 1
+			if !recorded[name] {
+				report.Issues = append(report.Issues, CheckIssue{Kind: IssueUnrecordedBucket, Collection: name})
+			}
+			typ, _ := d.collectionType(name)
+			codec := d.codecOf(name)
+			err := tx.Bucket([]byte(name)).ForEach(func(k, v []byte) error {
+				report.Documents += 1
+				if issue := d.checkDocument(typ, codec, k, v); issue != nil {
+					issue.Collection = name
+					issue.Key = append([]byte{}, k...)
+					report.Issues = append(report.Issues, *issue)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for name, active := range recorded {
+			if active && tx.Bucket([]byte(name)) == nil {
+				report.Issues = append(report.Issues, CheckIssue{Kind: IssueMissingBucket, Collection: name})
+			}
+		}
+
+		if meta := tx.Bucket([]byte(METADATA_COLLECTION_NAME)); meta != nil {
+			_ = meta.ForEach(func(k, _ []byte) error {
+				for _, prefix := range []string{FIELDS_COLLECTION_NAME, SCHEMA_COLLECTION_NAME, JSONSCHEMA_COLLECTION_NAME, OPTIONS_COLLECTION_NAME} {
+					name := strings.TrimPrefix(string(k), prefix)
+					if name == string(k) {
+						continue
+					}
+					if !recorded[name] && tx.Bucket([]byte(name)) == nil {
+						orphaned = append(orphaned, string(k))
+						report.Issues = append(report.Issues, CheckIssue{Kind: IssueOrphanedMetadata, Collection: name, Key: append([]byte{}, k...)})
+					}
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].Collection != report.Issues[j].Collection {
+			return report.Issues[i].Collection < report.Issues[j].Collection
+		}
+		return bytes.Compare(report.Issues[i].Key, report.Issues[j].Key) < 0
+	})
+
+	if opt.Repair && !report.OK() {
+		if err := d.repair(report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// danglingEntries returns the entries of an index, reference index or expiry bucket whose document is not stored.
+// Other internal buckets have none.
+func danglingEntries(tx *bbolt.Tx, name string) []CheckIssue {
+	var collection string
+	var documentKey func(entry []byte) []byte
+	switch {
+	case strings.HasPrefix(name, TTL_COLLECTION_NAME):
+		collection = strings.TrimPrefix(name, TTL_COLLECTION_NAME)
+		documentKey = func(entry []byte) []byte { return entry }
+	case strings.HasPrefix(name, INDEX_COLLECTION_NAME), strings.HasPrefix(name, REFS_COLLECTION_NAME):
+		// the bucket is named after the collection and the field path, paths hold no colon
+		rest := strings.TrimPrefix(strings.TrimPrefix(name, INDEX_COLLECTION_NAME), REFS_COLLECTION_NAME)
+		i := strings.LastIndex(rest, ":")
+		if i < 0 {
+			return nil
+		}
+		collection = rest[:i]
+		documentKey = func(entry []byte) []byte {
+			_, key, _ := splitEntry(entry)
+			return key
+		}
+	default:
+		return nil
+	}
+
+	documents := tx.Bucket([]byte(collection))
+	var issues []CheckIssue
+	_ = tx.Bucket([]byte(name)).ForEach(func(k, _ []byte) error {
+		key := documentKey(k)
+		if documents != nil && key != nil && documents.Get(key) != nil {
+			return nil
+		}
+		issues = append(issues, CheckIssue{
+			Kind:       IssueDanglingEntry,
+			Collection: collection,
+			Key:        append([]byte{}, key...),
+			Message:    fmt.Sprintf("entry of a missing document in %s", name),
+			bucket:     []byte(name),
+			entry:      append([]byte{}, k...),
+		})
+		return nil
+	})
+	return issues
+}
+
+// checkDocument returns the issue of a stored document, or nil if it is consistent.
+func (d *Driver) checkDocument(typ reflect.Type, codec Codec, key, value []byte) *CheckIssue {
+	if typ == nil {
+		var doc map[string]any
+		if err := Unmarshaller.Unmarshal(value, &doc); err != nil || doc == nil {
+			return &CheckIssue{Kind: IssueUndecodable, Message: fmt.Sprintf("not a JSON object: %v", err)}
+		}
+		return nil
+	}
+
+	ptr := reflect.New(typ)
+	if err := codec.Unmarshal(value, ptr.Interface()); err != nil {
+		return &CheckIssue{Kind: IssueUndecodable, Message: err.Error()}
+	}
+	doc := ptr.Elem().Interface()
+	if spec, ok := doc.(DocumentSpec); ok {
+		if k := spec.Key(); !bytes.Equal(k, key) {
+			return &CheckIssue{Kind: IssueKeyMismatch, Message: fmt.Sprintf("document key is %q", k)}
+		}
+	}
+	if err := d.val.Struct(doc); err != nil {
+		return &CheckIssue{Kind: IssueInvalid, Message: err.Error()}
+	}
+	return nil
+}
+
+// repair fixes the issues of a report in a single transaction, marking the repaired ones.
+func (d *Driver) repair(report *CheckReport) error {
+	defer d.forgetReferrers()
+	return d.update(func(tx *bbolt.Tx) error {
+		for i := range report.Issues {
+			issue := &report.Issues[i]
+			switch issue.Kind {
+			case IssueUndecodable, IssueKeyMismatch:
+				bucket := tx.Bucket([]byte(issue.Collection))
+				// the collection or the document may be gone since they were checked
+				if bucket == nil || bucket.Get(issue.Key) == nil {
+					continue
+				}
+				options, err := storedOptionsTx(tx, issue.Collection)
+				if err != nil {
+					return err
+				}
+				if options == nil {
+					options = &CollectionOptions{}
+				}
+				if err := quarantine(tx, issue.Collection, options, d.codecOf(issue.Collection), bucket, issue.Key); err != nil {
+					return err
+				}
+			case IssueOrphanedMetadata:
+				meta := tx.Bucket([]byte(METADATA_COLLECTION_NAME))
+				if meta == nil {
+					continue
+				}
+				if err := meta.Delete(issue.Key); err != nil {
+					return err
+				}
+			case IssueDanglingEntry:
+				bucket := tx.Bucket(issue.bucket)
+				// the document may have been stored since it was checked
+				if documents := tx.Bucket([]byte(issue.Collection)); bucket == nil || (documents != nil && documents.Get(issue.Key) != nil) {
+					continue
+				}
+				if err := bucket.Delete(issue.entry); err != nil {
+					return err
+				}
+			case IssueUnrecordedBucket:
+				if err := writeMetadataTx(tx, "collection:"+issue.Collection, true); err != nil {
+					return err
+				}
+			default:
+				continue
+			}
+			issue.Repaired = true
+		}
+		return nil
+	})
+}
+
+// quarantine moves a stored document to the __quarantine:<collection> bucket, removing its index entries, reference
+// index entries and expiry. The entries are removed for the document decoded into a map, then the entries a corrupt
+// document no longer matches are found by its key.
+func quarantine(tx *bbolt.Tx, collection string, options *CollectionOptions, codec Codec, bucket *bbolt.Bucket, key []byte) error {
+	value := bucket.Get(key)
+	if value == nil {
+		return nil
+	}
+	quarantined, err := tx.CreateBucketIfNotExists([]byte(QUARANTINE_COLLECTION_NAME + collection))
+	if err != nil {
+		return err
+	}
+	if err := quarantined.Put(key, append([]byte{}, value...)); err != nil {
+		return err
+	}
+	var old any
+	if doc, err := decodeStored(codec, value); err == nil && doc != nil {
+		old = doc
+	}
+	if err := removeStored(tx, collection, options, bucket, key, old, false); err != nil {
+		return err
+	}
+	for _, field := range options.Indexes {
+		if err := deleteEntriesOf(tx.Bucket(indexBucket(collection, field)), key); err != nil {
+			return err
+		}
+	}
+	for _, ref := range options.References {
+		if err := deleteEntriesOf(tx.Bucket(refIndexBucket(collection, ref.Path)), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteEntriesOf deletes the entries of an index or reference index bucket pointing at a document key.
+func deleteEntriesOf(bucket *bbolt.Bucket, key []byte) error {
+	if bucket == nil {
+		return nil
+	}
+	var entries [][]byte
+	_ = bucket.ForEach(func(k, _ []byte) error {
+		if _, documentKey, ok := splitEntry(k); ok && bytes.Equal(documentKey, key) {
+			entries = append(entries, append([]byte{}, k...))
+		}
+		return nil
+	})
+	for _, entry := range entries {
+		if err := bucket.Delete(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Quarantined returns the raw values moved out of a collection by Check's repair mode, by key.
+func (d *Driver) Quarantined(name string) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	err := d.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(QUARANTINE_COLLECTION_NAME + name))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			result[string(k)] = append([]byte{}, v...)
+			return nil
+		})
+	})
+	return result, err
+}
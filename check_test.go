@@ -0,0 +1,165 @@
+package bingo_test
+
+import (
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testcheck.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testcheck.db")
+	}()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "checked")
+	if _, err := coll.InsertMany([]TestDocument{{Name: "one"}, {Name: "two"}}); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+	report, err := driver.Check()
+	if err != nil || !report.OK() || report.Documents != 2 || report.Collections != 1 {
+		t.Fatalf("Expected a clean report, got %+v %v", report, err)
+	}
+
+	dropped := bingo.CollectionFrom[TestDocument](driver, "dropped")
+	if _, err := dropped.Insert(TestDocument{Name: "gone"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	if err := dropped.Drop(); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	bingo.CollectionFrom[TestDocument](driver, "empty")
+
+	err = driver.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte("checked"))
+		if err := bucket.Put([]byte("broken"), []byte(`{"Name": 12`)); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("moved"), []byte(`{"_id": "elsewhere", "Name": "moved"}`)); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("invalid"), []byte(`{"_id": "invalid", "Name": ""}`)); err != nil {
+			return err
+		}
+		raw, err := tx.CreateBucket([]byte("raw"))
+		if err != nil {
+			return err
+		}
+		return raw.Put([]byte("a"), []byte(`[1, 2]`))
+	})
+	if err != nil {
+		t.Fatalf("Failed to corrupt database: %v", err)
+	}
+
+	report, err = driver.Check()
+	if err != nil {
+		t.Fatalf("Failed to check: %v", err)
+	}
+	kinds := map[bingo.IssueKind]int{}
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] += 1
+	}
+	expected := map[bingo.IssueKind]int{
+		bingo.IssueUndecodable:      2,
+		bingo.IssueKeyMismatch:      1,
+		bingo.IssueInvalid:          1,
+		bingo.IssueOrphanedMetadata: 3,
+		bingo.IssueMissingBucket:    1,
+		bingo.IssueUnrecordedBucket: 1,
+	}
+	for kind, n := range expected {
+		if kinds[kind] != n {
+			t.Fatalf("Expected %d %s issues, got %d: %v", n, kind, kinds[kind], report.Issues)
+		}
+	}
+
+	report, err = driver.Check(bingo.Repair)
+	if err != nil {
+		t.Fatalf("Failed to repair: %v", err)
+	}
+	quarantined, err := driver.Quarantined("checked")
+	if err != nil || len(quarantined) != 2 || quarantined["broken"] == nil || quarantined["moved"] == nil {
+		t.Fatalf("Expected 2 quarantined documents, got %v %v", quarantined, err)
+	}
+
+	report, err = driver.Check()
+	if err != nil {
+		t.Fatalf("Failed to check: %v", err)
+	}
+	for _, issue := range report.Issues {
+		if issue.Kind != bingo.IssueInvalid && issue.Kind != bingo.IssueMissingBucket {
+			t.Fatalf("Expected only invalid documents and empty collections after repairing, got %v", issue)
+		}
+	}
+	if _, err := coll.FindByKey("broken"); err == nil {
+		t.Fatalf("Expected the broken document to be moved out")
+	}
+}
+
+func TestCheckDanglingEntries(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testdangling.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testdangling.db")
+	}()
+
+	coll, err := bingo.OpenCollection[TestDocument](driver, "dangling", bingo.WithIndex("Name"), bingo.WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	if _, err := coll.InsertMany([]TestDocument{{Document: bingo.Document{ID: "kept"}, Name: "kept"}, {Document: bingo.Document{ID: "lost"}, Name: "lost"}}); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+	err = driver.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte("dangling")).Delete([]byte("lost")); err != nil {
+			return err
+		}
+		refs, err := tx.CreateBucketIfNotExists([]byte("__refs:dangling:Owner"))
+		if err != nil {
+			return err
+		}
+		return refs.Put([]byte("\"someone\"\x00gone"), nil)
+	})
+	if err != nil {
+		t.Fatalf("Failed to remove the document behind its entries: %v", err)
+	}
+
+	report, err := driver.Check(bingo.Repair)
+	if err != nil {
+		t.Fatalf("Failed to check: %v", err)
+	}
+	var dangling []string
+	for _, issue := range report.Issues {
+		if issue.Kind != bingo.IssueDanglingEntry || !issue.Repaired {
+			t.Fatalf("Expected only repaired dangling entries, got %v", issue)
+		}
+		dangling = append(dangling, string(issue.Key))
+	}
+	if strings.Join(dangling, ",") != "gone,lost,lost" {
+		t.Fatalf("Expected the index, expiry and reference entries of missing documents, got %v", report.Issues)
+	}
+	if report, err := driver.Check(); err != nil || !report.OK() {
+		t.Fatalf("Expected a clean report after repairing, got %v %v", report.Issues, err)
+	}
+	if docs, err := coll.FindByIndex("Name", "kept"); err != nil || len(docs) != 1 {
+		t.Fatalf("Expected the entries of stored documents to stay, got %v %v", docs, err)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+type context struct {
+	driver   *bingo.Driver
+	in       io.Reader
+	out      *printer
+	limit    int
+	skip     int
+	upsert   bool
+	writable bool
+}
+
+func open(filename string, readOnly bool) (*context, error) {
+	driver, err := bingo.NewDriver(bingo.DriverConfiguration{
+		Filename: filename,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", filename, err)
+	}
+	return &context{driver: driver}, nil
+}
+
+func (ctx *context) Close() error {
+	return ctx.driver.Close()
+}
+
+// printer writes documents and rows either as indented JSON and tables, or as JSON lines.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, format: format}
+}
+
+func (p *printer) value(v any) error {
+	var data []byte
+	var err error
+	if p.format == "jsonl" {
+		data, err = json.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.w, string(data))
+	return err
+}
+
+// table prints rows as aligned columns, or as one JSON object per row in jsonl mode.
+func (p *printer) table(columns []string, rows [][]any) error {
+	if p.format == "jsonl" {
+		for _, row := range rows {
+			obj := map[string]any{}
+			for i, column := range columns {
+				obj[column] = row[i]
+			}
+			if err := p.value(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	for i, column := range columns {
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, column)
+	}
+	fmt.Fprintln(tw)
+	for _, row := range rows {
+		for i, cell := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func parseFilter(s string) (bingo.Filter, error) {
+	return bingo.ParseFilter([]byte(s))
+}
+
+func runCollections(ctx *context, _ []string) error {
+	collections, err := ctx.driver.GetCollections()
+	if err != nil && !bingo.IsErrDocumentNotFound(err) {
+		return err
+	}
+	sort.Strings(collections)
+	var rows [][]any
+	for _, name := range collections {
+		rows = append(rows, []any{name})
+	}
+	return ctx.out.table([]string{"collection"}, rows)
+}
+
+func runCount(ctx *context, args []string) error {
+	filter, err := parseFilter(optionalArg(args, 1))
+	if err != nil {
+		return err
+	}
+	count, err := ctx.driver.Dynamic(args[0]).Count(filter)
+	if err != nil {
+		return err
+	}
+	if ctx.out.format == "jsonl" {
+		return ctx.out.value(map[string]any{"collection": args[0], "count": count})
+	}
+	_, err = fmt.Fprintln(ctx.out.w, count)
+	return err
+}
+
+func runGet(ctx *context, args []string) error {
+	doc, err := ctx.driver.Dynamic(args[0]).FindByKey(args[1])
+	if err != nil {
+		return err
+	}
+	return ctx.out.value(doc)
+}
+
+func runFind(ctx *context, args []string) error {
+	filter, err := parseFilter(optionalArg(args, 1))
+	if err != nil {
+		return err
+	}
+	docs, err := ctx.driver.Dynamic(args[0]).Find(filter, bingo.Skip(ctx.skip), bingo.Count(ctx.limit))
+	if err != nil && !bingo.IsErrDocumentNotFound(err) {
+		return err
+	}
+	for _, doc := range docs {
+		if err := ctx.out.value(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPut(ctx *context, args []string) error {
+	raw := []byte(optionalArg(args, 1))
+	if len(raw) == 0 {
+		var err error
+		raw, err = io.ReadAll(ctx.in)
+		if err != nil {
+			return err
+		}
+	}
+	var opts []func(options *bingo.InsertOptions)
+	if ctx.upsert {
+		opts = append(opts, bingo.Upsert)
+	}
+	key, err := ctx.driver.Dynamic(args[0]).InsertRaw(raw, opts...)
+	if err != nil {
+		return err
+	}
+	return ctx.out.value(map[string]any{"key": string(key)})
+}
+
+func runDelete(ctx *context, args []string) error {
+	return ctx.driver.Dynamic(args[0]).DeleteByKey(args[1])
+}
+
+func runDrop(ctx *context, args []string) error {
+	return ctx.driver.Dynamic(args[0]).Drop()
+}
+
+func runFields(ctx *context, args []string) error {
+	fields, err := ctx.driver.FieldsOf(args[0])
+	if err != nil {
+		return err
+	}
+	var rows [][]any
+	for _, names := range fields {
+		alias := ""
+		if len(names) > 1 {
+			alias = names[1]
+		}
+		rows = append(rows, []any{names[0], alias})
+	}
+	return ctx.out.table([]string{"field", "alias"}, rows)
+}
+
+func runStats(ctx *context, args []string) error {
+	only := optionalArg(args, 0)
+	stats, err := ctx.driver.Stats()
+	if err != nil {
+		return err
+	}
+	var rows [][]any
+	for _, s := range stats.Collections {
+		if only != "" && s.Name != only {
+			continue
+		}
+		rows = append(rows, []any{s.Name, s.Keys, s.Bytes, s.LeafPages + s.BranchPages + s.OverflowPages, s.Depth})
+	}
+	if only == "" && ctx.out.format != "jsonl" {
+		fmt.Fprintf(ctx.out.w, "size: %d bytes, free: %d pages (%d bytes)\n\n", stats.Size, stats.FreePages+stats.PendingPages, stats.FreeBytes)
+	}
+	return ctx.out.table([]string{"collection", "keys", "bytes", "pages", "depth"}, rows)
+}
+
+func runCheck(ctx *context, args []string) error {
+	var opts []func(options *bingo.CheckOptions)
+	if ctx.writable {
+		opts = append(opts, bingo.Repair)
+	}
+	report, err := ctx.driver.Check(opts...)
+	if err != nil {
+		return err
+	}
+	var rows [][]any
+	remaining := 0
+	for _, issue := range report.Issues {
+		if !issue.Repaired {
+			remaining += 1
+		}
+		rows = append(rows, []any{string(issue.Kind), issue.Collection, string(issue.Key), issue.Message, issue.Repaired})
+	}
+	if ctx.out.format != "jsonl" {
+		fmt.Fprintf(ctx.out.w, "checked %d documents in %d collections\n\n", report.Documents, report.Collections)
+	}
+	if err := ctx.out.table([]string{"issue", "collection", "key", "message", "repaired"}, rows); err != nil {
+		return err
+	}
+	if remaining > 0 {
+		return fmt.Errorf("%d issues found", remaining)
+	}
+	return nil
+}
+
+func runCompact(ctx *context, args []string) error {
+	return ctx.driver.Compact(args[0])
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fruit struct {
+	bingo.Document
+	Name  string `json:"name"`
+	Stock int    `json:"stock"`
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "test.db")
+	compacted := filepath.Join(dir, "compacted.db")
+
+	driver, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: db})
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	if _, err := bingo.CollectionFrom[fruit](driver, "fruits").Insert(fruit{Document: bingo.Document{ID: "apple"}, Name: "Apple", Stock: 3}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	driver.Close()
+
+	corrupt := func(t *testing.T) {
+		driver, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: db})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer driver.Close()
+		err = driver.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket([]byte("fruits")).Put([]byte("broken"), []byte("{not json"))
+		})
+		if err != nil {
+			t.Fatalf("Failed to corrupt database: %v", err)
+		}
+	}
+
+	// the commands share the database and run in order
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		before  func(t *testing.T)
+		want    []string
+		absent  []string
+		wantErr string
+	}{
+		{name: "collections", args: []string{"collections", db}, want: []string{"fruits"}},
+		{name: "put requires -w", args: []string{"put", db, "fruits", `{"_id": "pear"}`}, wantErr: "pass -w"},
+		{name: "put", args: []string{"put", "-w", db, "fruits", `{"_id": "pear", "name": "Pear", "stock": 0}`}, want: []string{`"key": "pear"`}},
+		{name: "put from stdin", args: []string{"put", "-w", "-o", "jsonl", db, "fruits"}, stdin: `{"_id": "plum", "name": "Plum", "stock": 7}`, want: []string{`{"key":"plum"}`}},
+		{name: "put existing", args: []string{"put", "-w", db, "fruits", `{"_id": "apple"}`}, wantErr: "already exists"},
+		{name: "put upsert", args: []string{"put", "-w", "-upsert", db, "fruits", `{"_id": "apple", "name": "Green Apple", "stock": 5}`}, want: []string{`"key": "apple"`}},
+		{name: "count", args: []string{"count", db, "fruits"}, want: []string{"3\n"}},
+		{name: "count with filter", args: []string{"count", "-o", "jsonl", db, "fruits", `{"stock": {"$gt": 1}}`}, want: []string{`{"collection":"fruits","count":2}`}},
+		{name: "get", args: []string{"get", db, "fruits", "apple"}, want: []string{`"name": "Green Apple"`}},
+		{name: "get missing", args: []string{"get", db, "fruits", "kiwi"}, wantErr: "not found"},
+		{name: "get usage", args: []string{"get", db, "fruits"}, wantErr: "usage: bingo get"},
+		{name: "find with filter", args: []string{"find", "-o", "jsonl", db, "fruits", `{"name": "Pear"}`}, want: []string{`"_id":"pear"`}, absent: []string{"apple", "plum"}},
+		{name: "find with limit", args: []string{"find", "-o", "jsonl", "-limit", "1", db, "fruits"}, want: []string{"plum"}, absent: []string{"pear", "apple"}},
+		{name: "find with skip", args: []string{"find", "-o", "jsonl", "-skip", "2", db, "fruits"}, want: []string{"apple"}, absent: []string{"pear", "plum"}},
+		{name: "fields", args: []string{"fields", db, "fruits"}, want: []string{"field", "Name", "name", "Stock"}},
+		{name: "stats", args: []string{"stats", db}, want: []string{"size:", "fruits"}},
+		{name: "stats of a collection", args: []string{"stats", "-o", "jsonl", db, "fruits"}, want: []string{`"collection":"fruits"`, `"keys":3`}, absent: []string{"size:"}},
+		{name: "delete requires -w", args: []string{"delete", db, "fruits", "pear"}, wantErr: "pass -w"},
+		{name: "delete", args: []string{"delete", "-w", db, "fruits", "pear"}},
+		{name: "delete missing", args: []string{"delete", "-w", db, "fruits", "pear"}, wantErr: "not found"},
+		{name: "check", args: []string{"check", db}, want: []string{"checked 2 documents in 1 collections"}},
+		{name: "check finds issues", args: []string{"check", db}, before: corrupt, want: []string{"undecodable", "broken"}, wantErr: "1 issues found"},
+		{name: "check repairs with -w", args: []string{"check", "-w", db}, want: []string{"undecodable", "true"}},
+		{name: "check after repair", args: []string{"check", db}, want: []string{"checked 2 documents"}, absent: []string{"undecodable"}},
+		{name: "compact", args: []string{"compact", db, compacted}},
+		{name: "compacted copy", args: []string{"count", compacted, "fruits"}, want: []string{"2\n"}},
+		{name: "drop requires -w", args: []string{"drop", db, "fruits"}, wantErr: "pass -w"},
+		{name: "drop requires the environment", args: []string{"drop", "-w", db, "fruits"}, wantErr: "BINGO_ALLOW_DROP_FRUITS"},
+		{name: "drop", args: []string{"drop", "-w", db, "fruits"}, before: func(t *testing.T) { t.Setenv("BINGO_ALLOW_DROP_FRUITS", "true") }},
+		{name: "collections after drop", args: []string{"collections", db}, absent: []string{"fruits"}},
+		{name: "missing file", args: []string{"collections", filepath.Join(dir, "missing.db")}, wantErr: "no such file"},
+		{name: "unknown command", args: []string{"bogus", db}, wantErr: "unknown command"},
+		{name: "unknown format", args: []string{"collections", "-o", "xml", db}, wantErr: "unknown output format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.before != nil {
+				tt.before(t)
+			}
+			var out bytes.Buffer
+			err := run(tt.args[0], tt.args[1:], strings.NewReader(tt.stdin), &out)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Fatalf("Expected the output to contain %q, got %s", want, out.String())
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(out.String(), absent) {
+					t.Fatalf("Expected the output not to contain %q, got %s", absent, out.String())
+				}
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "missing.db")); !os.IsNotExist(err) {
+		t.Fatalf("Expected read-only commands not to create a missing database, got %v", err)
+	}
+}
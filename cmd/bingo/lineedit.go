@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+const maxHistory = 1000
+
+// lineEditor reads lines from a terminal with history and tab completion.
+// When the input is not a terminal it falls back to reading plain lines.
+type lineEditor struct {
+	in          *os.File
+	out         io.Writer
+	reader      *bufio.Reader
+	history     []string
+	historyFile string
+	// complete returns the start of the word being completed and the candidates replacing it.
+	complete func(line string) (start int, candidates []string)
+}
+
+func newLineEditor(in *os.File, out io.Writer) *lineEditor {
+	e := &lineEditor{in: in, out: out, reader: bufio.NewReader(in)}
+	if home, err := os.UserHomeDir(); err == nil {
+		e.historyFile = filepath.Join(home, ".bingo_history")
+		if data, err := os.ReadFile(e.historyFile); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != "" {
+					e.history = append(e.history, line)
+				}
+			}
+		}
+	}
+	return e
+}
+
+func (e *lineEditor) addHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+func (e *lineEditor) saveHistory() error {
+	if e.historyFile == "" {
+		return nil
+	}
+	return os.WriteFile(e.historyFile, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+}
+
+// ReadLine prints the prompt and reads a line. It returns io.EOF on Ctrl-D and errInterrupted on Ctrl-C.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(int(e.in.Fd()))
+	if err != nil {
+		line, err := e.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+
+	fmt.Fprint(e.out, prompt)
+	var line []rune
+	pos := 0
+	historyPos := len(e.history)
+	pending := ""
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	insert := func(s string) {
+		r := []rune(s)
+		line = append(line[:pos], append(r, line[pos:]...)...)
+		pos += len(r)
+	}
+
+	for {
+		b, err := e.reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			result := string(line)
+			e.addHistory(strings.TrimSpace(result))
+			return result, nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = line[pos:]
+			pos = 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case '\t':
+			if e.complete == nil {
+				break
+			}
+			text := string(line[:pos])
+			start, candidates := e.complete(text)
+			word := text[start:]
+			if len(candidates) == 0 {
+				break
+			}
+			prefix := commonPrefix(candidates)
+			if len(prefix) > len(word) {
+				insert(prefix[len(word):])
+			} else if len(candidates) > 1 {
+				fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			}
+		case 27: // Escape sequences
+			seq, _ := e.reader.ReadByte()
+			if seq != '[' && seq != 'O' {
+				break
+			}
+			code, _ := e.reader.ReadByte()
+			switch code {
+			case 'A':
+				if historyPos > 0 {
+					historyPos--
+					line = []rune(e.history[historyPos])
+					pos = len(line)
+				}
+			case 'B':
+				if historyPos < len(e.history) {
+					historyPos++
+					if historyPos == len(e.history) {
+						line = nil
+					} else {
+						line = []rune(e.history[historyPos])
+					}
+					pos = len(line)
+				}
+			case 'C':
+				if pos < len(line) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(line)
+			case '3':
+				if next, _ := e.reader.ReadByte(); next == '~' && pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if b < 32 {
+				break
+			}
+			pending += string(b)
+			if !utf8.FullRuneInString(pending) {
+				continue
+			}
+			insert(pending)
+			pending = ""
+		}
+		redraw()
+	}
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,121 @@
+// Command bingo inspects and edits bingo database files.
+//
+//	bingo <command> [flags] <file.db> [args...]
+//
+// Files are opened read-only unless -w is passed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	usage string
+	help  string
+	// args is the number of required arguments after the database file, optional the number of optional ones.
+	args     int
+	optional int
+	writes   bool
+	run      func(ctx *context, args []string) error
+}
+
+var commands = map[string]command{
+	"collections": {usage: "<file.db>", help: "list the collections recorded in the metadata", run: runCollections},
+	"count":       {usage: "<file.db> <collection> ['<json filter>']", help: "count documents, optionally matching a filter", args: 1, optional: 1, run: runCount},
+	"get":         {usage: "<file.db> <collection> <key>", help: "print a document by key", args: 2, run: runGet},
+	"find":        {usage: "<file.db> <collection> ['<json filter>']", help: "print documents matching a filter", args: 1, optional: 1, run: runFind},
+	"put":         {usage: "<file.db> <collection> ['<json document>']", help: "insert a document, read from stdin if omitted", args: 1, optional: 1, writes: true, run: runPut},
+	"delete":      {usage: "<file.db> <collection> <key>", help: "delete a document by key", args: 2, writes: true, run: runDelete},
+	"drop":        {usage: "<file.db> <collection>", help: "drop a collection, requires BINGO_ALLOW_DROP_<COLLECTION>=true", args: 1, writes: true, run: runDrop},
+	"fields":      {usage: "<file.db> <collection>", help: "print the recorded fields of a collection", args: 1, run: runFields},
+	"stats":       {usage: "<file.db> [collection]", help: "print database and collection statistics", optional: 1, run: runStats},
+	"check":       {usage: "<file.db>", help: "check the consistency of documents and metadata, repairs them with -w", run: runCheck},
+	"compact":     {usage: "<file.db> <destination.db>", help: "rewrite the database into a fresh, compacted file", args: 1, run: runCompact},
+	"shell":       {usage: "<file.db>", help: "start an interactive shell", run: runShell},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: bingo <command> [flags] <file.db> [args...]\n\ncommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n  %-12s   %s\n", name, commands[name].usage, "", commands[name].help)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n  -w            open the database for writing\n  -o <format>   output format, pretty or jsonl (default pretty)\n  -limit <n>    maximum number of documents to print\n  -skip <n>     number of documents to skip\n  -upsert       replace existing documents on put\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "bingo: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs a command, documents are read from stdin and results written to stdout.
+func run(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = usage
+	write := flags.Bool("w", false, "open the database for writing")
+	format := flags.String("o", "pretty", "output format, pretty or jsonl")
+	limit := flags.Int("limit", 0, "maximum number of documents to print")
+	skip := flags.Int("skip", 0, "number of documents to skip")
+	upsert := flags.Bool("upsert", false, "replace existing documents on put")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) < 1+cmd.args || len(args) > 1+cmd.args+cmd.optional {
+		return fmt.Errorf("usage: bingo %s %s", name, cmd.usage)
+	}
+	if *format != "pretty" && *format != "jsonl" {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	if cmd.writes && !*write {
+		return fmt.Errorf("%s modifies the database, pass -w to open it for writing", name)
+	}
+	if _, err := os.Stat(args[0]); err != nil && !*write {
+		return err
+	}
+
+	ctx, err := open(args[0], !*write)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+	ctx.in = stdin
+	ctx.out = newPrinter(stdout, *format)
+	ctx.limit = *limit
+	ctx.skip = *skip
+	ctx.upsert = *upsert
+	ctx.writable = *write
+	return cmd.run(ctx, args[1:])
+}
+
+func optionalArg(args []string, i int) string {
+	if i < len(args) {
+		return strings.TrimSpace(args[i])
+	}
+	return ""
+}
@@ -0,0 +1,683 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const shellHelp = `statements:
+  db.<collection>.find([filter]).sort({field: 1}).skip(n).limit(n)
+  db.<collection>.findOne([filter])
+  db.<collection>.get(key)
+  db.<collection>.count([filter])
+  db.<collection>.insert(document | [documents])
+  db.<collection>.update(filter, {$set: {...}, $unset: {...}, $inc: {...}} | document)   // first match
+  db.<collection>.updateOne(filter, update)                                              // first match
+  db.<collection>.updateMany(filter, update)                                             // every match
+  db.<collection>.delete(filter)
+  db.<collection>.deleteOne(filter)
+  db.<collection>.fields()
+  db.getCollection("name").<method>(...)
+
+commands:
+  show collections   list collections
+  mode table|json    switch the output mode
+  begin              start a transaction
+  commit             commit the current transaction
+  rollback           roll back the current transaction
+  help               show this help
+  exit               leave the shell
+`
+
+var shellMethods = []string{"find", "findOne", "get", "count", "insert", "insertOne", "insertMany", "update", "updateOne", "updateMany", "delete", "deleteOne", "deleteMany", "remove", "fields", "sort", "skip", "limit"}
+
+var shellCommands = []string{"show collections", "mode table", "mode json", "begin", "commit", "rollback", "help", "exit", "db."}
+
+// shell is an interactive prompt evaluating Mongo shell style statements against a database.
+type shell struct {
+	ctx      *context
+	writable bool
+	out      io.Writer
+	mode     string
+	tx       *bbolt.Tx
+	fields   map[string][]string
+}
+
+func runShell(ctx *context, _ []string) error {
+	s := &shell{ctx: ctx, writable: ctx.writable, out: os.Stdout, mode: "table", fields: map[string][]string{}}
+	editor := newLineEditor(os.Stdin, os.Stdout)
+	editor.complete = s.complete
+	defer editor.saveHistory()
+
+	interactive := isTerminal(os.Stdin)
+	if interactive {
+		fmt.Fprintln(s.out, `bingo shell, type "help" for help`)
+	}
+	for {
+		prompt := "bingo> "
+		if s.tx != nil {
+			prompt = "bingo*> "
+		}
+		if !interactive {
+			prompt = ""
+		}
+		line, err := editor.ReadLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				return s.close()
+			}
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			return s.close()
+		}
+		if err := s.exec(line); err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+	}
+}
+
+// isTerminal reports whether f is a terminal supporting the line editor.
+func isTerminal(f *os.File) bool {
+	restore, err := makeRaw(int(f.Fd()))
+	if err != nil {
+		return false
+	}
+	restore()
+	return true
+}
+
+func (s *shell) close() error {
+	if s.tx != nil {
+		fmt.Fprintln(s.out, "rolling back open transaction")
+		return s.tx.Rollback()
+	}
+	return nil
+}
+
+func (s *shell) exec(line string) error {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "help":
+		fmt.Fprint(s.out, shellHelp)
+		return nil
+	case "show", "collections":
+		if fields[0] == "show" && (len(fields) != 2 || fields[1] != "collections") {
+			return fmt.Errorf("usage: show collections")
+		}
+		collections, err := s.ctx.driver.GetCollections()
+		if err != nil && !bingo.IsErrDocumentNotFound(err) {
+			return err
+		}
+		sort.Strings(collections)
+		for _, name := range collections {
+			fmt.Fprintln(s.out, name)
+		}
+		return nil
+	case "mode":
+		if len(fields) != 2 || (fields[1] != "table" && fields[1] != "json") {
+			return fmt.Errorf("usage: mode table|json")
+		}
+		s.mode = fields[1]
+		return nil
+	case "begin":
+		if s.tx != nil {
+			return fmt.Errorf("a transaction is already open")
+		}
+		if !s.writable {
+			return fmt.Errorf("the database is open read-only, start the shell with -w")
+		}
+		tx, err := s.ctx.driver.Begin(true)
+		if err != nil {
+			return err
+		}
+		s.tx = tx
+		return nil
+	case "commit", "rollback":
+		if s.tx == nil {
+			return fmt.Errorf("no open transaction")
+		}
+		tx := s.tx
+		s.tx = nil
+		if fields[0] == "commit" {
+			return tx.Commit()
+		}
+		return tx.Rollback()
+	}
+
+	stmt, err := parseStatement(line)
+	if err != nil {
+		return err
+	}
+	return s.eval(stmt)
+}
+
+func (s *shell) collection(name string) *bingo.DynamicCollection {
+	c := s.ctx.driver.Dynamic(name)
+	if s.tx != nil {
+		c = c.InTx(s.tx)
+	}
+	return c
+}
+
+func (s *shell) eval(stmt *statement) error {
+	c := s.collection(stmt.collection)
+	first := stmt.calls[0]
+	switch first.name {
+	case "find", "findOne":
+		return s.find(c, stmt.calls)
+	case "count":
+		filter, err := filterArg(first.args, 0)
+		if err != nil {
+			return err
+		}
+		count, err := c.Count(filter)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, count)
+		return nil
+	case "get":
+		if len(first.args) != 1 {
+			return fmt.Errorf("get expects a key")
+		}
+		key, err := parseValue(first.args[0])
+		if err != nil {
+			return err
+		}
+		doc, err := c.FindByKey(fmt.Sprint(key))
+		if err != nil {
+			return err
+		}
+		return s.print([]map[string]any{doc})
+	case "insert", "insertOne", "insertMany":
+		if !s.writable {
+			return fmt.Errorf("the database is open read-only, start the shell with -w")
+		}
+		if len(first.args) != 1 {
+			return fmt.Errorf("%s expects a document", first.name)
+		}
+		v, err := parseValue(first.args[0])
+		if err != nil {
+			return err
+		}
+		var docs []map[string]any
+		switch value := v.(type) {
+		case map[string]any:
+			docs = append(docs, value)
+		case []any:
+			for _, i := range value {
+				doc, ok := i.(map[string]any)
+				if !ok {
+					return fmt.Errorf("%s expects documents", first.name)
+				}
+				docs = append(docs, doc)
+			}
+		default:
+			return fmt.Errorf("%s expects a document", first.name)
+		}
+		keys, err := c.InsertMany(docs)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Fprintf(s.out, "inserted %s\n", key)
+		}
+		return nil
+	case "update", "updateOne", "updateMany":
+		return s.update(c, first)
+	case "delete", "deleteOne", "deleteMany", "remove":
+		return s.delete(c, first)
+	case "fields":
+		for _, field := range s.fieldsOf(stmt.collection) {
+			fmt.Fprintln(s.out, field)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown method %s", first.name)
+}
+
+func filterArg(args []string, i int) (bingo.Filter, error) {
+	if i >= len(args) || args[i] == "" {
+		return bingo.Filter{}, nil
+	}
+	v, err := parseValue(args[i])
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("filter must be an object")
+	}
+	return m, bingo.Filter(m).Validate()
+}
+
+type match struct {
+	key []byte
+	doc map[string]any
+}
+
+// matches returns the documents matching the filter, stopping after max matches if max is positive.
+func matches(c *bingo.DynamicCollection, filter bingo.Filter, max int) ([]match, error) {
+	var result []match
+	err := c.Iter(func(key []byte, doc map[string]any) error {
+		if filter.Match(doc) {
+			result = append(result, match{key: append([]byte{}, key...), doc: doc})
+			if max > 0 && len(result) >= max {
+				return io.EOF
+			}
+		}
+		return nil
+	})
+	if err == io.EOF {
+		err = nil
+	}
+	return result, err
+}
+
+func (s *shell) find(c *bingo.DynamicCollection, calls []call) error {
+	filter, err := filterArg(calls[0].args, 0)
+	if err != nil {
+		return err
+	}
+	var sortBy []sortField
+	skip, limit := 0, 0
+	if calls[0].name == "findOne" {
+		limit = 1
+	}
+	count := false
+	for _, modifier := range calls[1:] {
+		switch modifier.name {
+		case "sort":
+			if len(modifier.args) != 1 {
+				return fmt.Errorf("sort expects a specification")
+			}
+			if sortBy, err = parseSort(modifier.args[0]); err != nil {
+				return err
+			}
+		case "skip", "limit":
+			if len(modifier.args) != 1 {
+				return fmt.Errorf("%s expects a number", modifier.name)
+			}
+			var n int
+			if _, err := fmt.Sscan(modifier.args[0], &n); err != nil || n < 0 {
+				return fmt.Errorf("%s expects a number", modifier.name)
+			}
+			if modifier.name == "skip" {
+				skip = n
+			} else {
+				limit = n
+			}
+		case "count":
+			count = true
+		default:
+			return fmt.Errorf("unknown cursor method %s", modifier.name)
+		}
+	}
+
+	max := 0
+	if sortBy == nil && limit > 0 && !count {
+		max = skip + limit
+	}
+	found, err := matches(c, filter, max)
+	if err != nil {
+		return err
+	}
+	if len(sortBy) > 0 {
+		sort.SliceStable(found, func(i, j int) bool {
+			for _, field := range sortBy {
+				a, _ := bingo.LookupPath(found[i].doc, field.path)
+				b, _ := bingo.LookupPath(found[j].doc, field.path)
+				if c := compareAny(a, b); c != 0 {
+					return c*field.direction < 0
+				}
+			}
+			return false
+		})
+	}
+	if skip >= len(found) {
+		found = nil
+	} else {
+		found = found[skip:]
+	}
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	if count {
+		fmt.Fprintln(s.out, len(found))
+		return nil
+	}
+	docs := make([]map[string]any, len(found))
+	for i, m := range found {
+		docs[i] = m.doc
+	}
+	return s.print(docs)
+}
+
+func (s *shell) update(c *bingo.DynamicCollection, cl call) error {
+	if !s.writable {
+		return fmt.Errorf("the database is open read-only, start the shell with -w")
+	}
+	if len(cl.args) != 2 {
+		return fmt.Errorf("%s expects a filter and an update", cl.name)
+	}
+	filter, err := filterArg(cl.args, 0)
+	if err != nil {
+		return err
+	}
+	v, err := parseValue(cl.args[1])
+	if err != nil {
+		return err
+	}
+	change, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("update must be an object")
+	}
+	// update changes the first match like updateOne, changing every match takes an explicit updateMany
+	max := 1
+	if cl.name == "updateMany" {
+		max = 0
+	}
+	found, err := matches(c, filter, max)
+	if err != nil {
+		return err
+	}
+	for _, m := range found {
+		doc, err := applyUpdate(m.doc, change, c.KeyPath)
+		if err != nil {
+			return err
+		}
+		if err := c.UpdateByKey(string(m.key), doc); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(s.out, "updated %d\n", len(found))
+	return nil
+}
+
+// applyUpdate applies $set, $unset and $inc operators, or replaces the document while keeping its key.
+func applyUpdate(doc map[string]any, change map[string]any, keyPath string) (map[string]any, error) {
+	operators := false
+	for k := range change {
+		if strings.HasPrefix(k, "$") {
+			operators = true
+		}
+	}
+	if !operators {
+		replacement := map[string]any{}
+		for k, v := range change {
+			replacement[k] = v
+		}
+		if key, ok := bingo.LookupPath(doc, keyPath); ok {
+			bingo.SetPath(replacement, keyPath, key)
+		}
+		return replacement, nil
+	}
+	for op, arg := range change {
+		fields, ok := arg.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s expects an object", op)
+		}
+		for path, value := range fields {
+			switch op {
+			case "$set":
+				bingo.SetPath(doc, path, value)
+			case "$unset":
+				unsetPath(doc, path)
+			case "$inc":
+				current, _ := bingo.LookupPath(doc, path)
+				a, _ := current.(float64)
+				b, ok := value.(float64)
+				if !ok {
+					return nil, fmt.Errorf("$inc expects numbers")
+				}
+				bingo.SetPath(doc, path, a+b)
+			default:
+				return nil, fmt.Errorf("unknown update operator %s", op)
+			}
+		}
+	}
+	return doc, nil
+}
+
+func unsetPath(doc map[string]any, path string) {
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, parts[len(parts)-1])
+}
+
+func (s *shell) delete(c *bingo.DynamicCollection, cl call) error {
+	if !s.writable {
+		return fmt.Errorf("the database is open read-only, start the shell with -w")
+	}
+	filter, err := filterArg(cl.args, 0)
+	if err != nil {
+		return err
+	}
+	if len(filter) == 0 && cl.name != "deleteOne" && cl.name != "deleteMany" {
+		return fmt.Errorf("refusing to delete every document, use deleteMany({}) to do so")
+	}
+	max := 0
+	if cl.name == "deleteOne" {
+		max = 1
+	}
+	found, err := matches(c, filter, max)
+	if err != nil {
+		return err
+	}
+	for _, m := range found {
+		if err := c.DeleteByKey(string(m.key)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(s.out, "deleted %d\n", len(found))
+	return nil
+}
+
+// compareAny orders values of decoded documents: missing and null first, then booleans, numbers and strings.
+func compareAny(a, b any) int {
+	rank := func(v any) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		case string:
+			return 3
+		}
+		return 4
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch va := a.(type) {
+	case bool:
+		vb := b.(bool)
+		if va == vb {
+			return 0
+		}
+		if !va {
+			return -1
+		}
+		return 1
+	case float64:
+		vb := b.(float64)
+		switch {
+		case va < vb:
+			return -1
+		case va > vb:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(va, b.(string))
+	}
+	return 0
+}
+
+func (s *shell) print(docs []map[string]any) error {
+	if s.mode == "json" {
+		p := newPrinter(s.out, "pretty")
+		for _, doc := range docs {
+			if err := p.value(doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(docs) == 0 {
+		fmt.Fprintln(s.out, "(no documents)")
+		return nil
+	}
+	seen := map[string]bool{}
+	var columns []string
+	for _, doc := range docs {
+		for k := range doc {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if (columns[i] == "_id") != (columns[j] == "_id") {
+			return columns[i] == "_id"
+		}
+		return columns[i] < columns[j]
+	})
+	var rows [][]any
+	for _, doc := range docs {
+		row := make([]any, len(columns))
+		for i, column := range columns {
+			row[i] = cell(doc[column])
+		}
+		rows = append(rows, row)
+	}
+	return newPrinter(s.out, "pretty").table(columns, rows)
+}
+
+func cell(v any) string {
+	var s string
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = value
+	default:
+		data, _ := json.Marshal(value)
+		s = string(data)
+	}
+	s = strings.ReplaceAll(s, "\n", " ")
+	if r := []rune(s); len(r) > 40 {
+		s = string(r[:39]) + "…"
+	}
+	return s
+}
+
+func (s *shell) fieldsOf(collection string) []string {
+	if fields, ok := s.fields[collection]; ok {
+		return fields
+	}
+	seen := map[string]bool{}
+	var fields []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			fields = append(fields, name)
+		}
+	}
+	if recorded, err := s.ctx.driver.FieldsOf(collection); err == nil {
+		for _, names := range recorded {
+			for _, name := range names {
+				add(name)
+			}
+		}
+	}
+	if docs, err := matches(s.collection(collection), bingo.Filter{}, 1); err == nil && len(docs) > 0 {
+		for k := range docs[0].doc {
+			add(k)
+		}
+	}
+	sort.Strings(fields)
+	s.fields[collection] = fields
+	return fields
+}
+
+// complete returns completions for collection names, methods and field names.
+func (s *shell) complete(line string) (int, []string) {
+	start := len(line)
+	for start > 0 && strings.IndexByte(" \t({[,:)}", line[start-1]) < 0 {
+		start--
+	}
+	word := line[start:]
+	var candidates []string
+	switch {
+	case strings.TrimSpace(line[:start]) == "" && !strings.HasPrefix(word, "db."):
+		candidates = prefixed(shellCommands, word)
+	case strings.HasPrefix(line[:start], "mode "):
+		candidates = prefixed([]string{"table", "json"}, word)
+	case strings.HasPrefix(word, "db."):
+		parts := strings.Split(word, ".")
+		if len(parts) == 2 {
+			collections, _ := s.ctx.driver.GetCollections()
+			for _, name := range prefixed(collections, parts[1]) {
+				candidates = append(candidates, "db."+name+".")
+			}
+		} else {
+			base := strings.Join(parts[:len(parts)-1], ".") + "."
+			for _, method := range prefixed(shellMethods, parts[len(parts)-1]) {
+				candidates = append(candidates, base+method+"(")
+			}
+		}
+	case strings.HasPrefix(strings.TrimSpace(line), "db."):
+		rest := strings.TrimPrefix(strings.TrimSpace(line), "db.")
+		if end := strings.IndexByte(rest, '.'); end > 0 {
+			candidates = prefixed(s.fieldsOf(rest[:end]), strings.Trim(word, `"'`))
+		}
+		if strings.HasPrefix(word, ".") {
+			for _, method := range prefixed(shellMethods, word[1:]) {
+				candidates = append(candidates, "."+method+"(")
+			}
+		}
+	}
+	sort.Strings(candidates)
+	if len(candidates) > 0 && strings.HasPrefix(word, `"`) {
+		for i, c := range candidates {
+			candidates[i] = `"` + c
+		}
+	}
+	return start, candidates
+}
+
+func prefixed(values []string, prefix string) []string {
+	var result []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
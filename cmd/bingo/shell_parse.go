@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// call is a single method call of a statement, with its arguments as raw text.
+type call struct {
+	name string
+	args []string
+}
+
+// statement is a parsed `db.<collection>.<method>(...).<method>(...)` expression.
+type statement struct {
+	collection string
+	calls      []call
+}
+
+// parseStatement parses Mongo shell style expressions such as
+//
+//	db.users.find({age: {$gt: 18}}).sort({name: 1}).limit(10)
+//	db.getCollection("audit:log").count()
+func parseStatement(s string) (*statement, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), ";")
+	if !strings.HasPrefix(s, "db.") {
+		return nil, fmt.Errorf("expected a statement starting with db.")
+	}
+	s = s[len("db."):]
+
+	stmt := &statement{}
+	if strings.HasPrefix(s, "getCollection(") {
+		args, rest, err := splitCall(s[len("getCollection"):])
+		if err != nil {
+			return nil, err
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("getCollection expects a collection name")
+		}
+		var name string
+		if err := json.Unmarshal([]byte(relaxedJSON(args[0])), &name); err != nil {
+			return nil, fmt.Errorf("getCollection expects a collection name")
+		}
+		stmt.collection = name
+		s = rest
+	} else {
+		end := strings.IndexByte(s, '.')
+		if end <= 0 {
+			return nil, fmt.Errorf("expected db.<collection>.<method>(...)")
+		}
+		stmt.collection = s[:end]
+		s = s[end:]
+	}
+
+	for s != "" {
+		if s[0] != '.' {
+			return nil, fmt.Errorf("unexpected %q", s)
+		}
+		s = s[1:]
+		end := strings.IndexByte(s, '(')
+		if end <= 0 {
+			return nil, fmt.Errorf("expected a method call after %q", "."+s)
+		}
+		name := strings.TrimSpace(s[:end])
+		args, rest, err := splitCall(s[end:])
+		if err != nil {
+			return nil, err
+		}
+		stmt.calls = append(stmt.calls, call{name: name, args: args})
+		s = strings.TrimSpace(rest)
+	}
+	if len(stmt.calls) == 0 {
+		return nil, fmt.Errorf("expected a method call on %s", stmt.collection)
+	}
+	return stmt, nil
+}
+
+// splitCall splits `(a, b)rest` into its top level arguments and the remaining text.
+func splitCall(s string) ([]string, string, error) {
+	if s == "" || s[0] != '(' {
+		return nil, "", fmt.Errorf("expected (")
+	}
+	var args []string
+	depth := 0
+	var quote byte
+	start := 1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+			if depth == 0 {
+				if c != ')' {
+					return nil, "", fmt.Errorf("unbalanced %q", c)
+				}
+				if arg := strings.TrimSpace(s[start:i]); arg != "" || len(args) > 0 {
+					args = append(args, arg)
+				}
+				return args, s[i+1:], nil
+			}
+		case ',':
+			if depth == 1 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("unterminated call")
+}
+
+// relaxedJSON converts Mongo shell style object literals into JSON,
+// quoting bare keys ({age: 1} and {$gt: 1}) and converting single quoted strings.
+func relaxedJSON(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				end = len(s) - 1
+			}
+			out.WriteString(s[i : end+1])
+			i = end
+		case c == '\'':
+			out.WriteByte('"')
+			i++
+			for ; i < len(s) && s[i] != '\''; i++ {
+				switch {
+				case s[i] == '\\' && i+1 < len(s):
+					i++
+					if s[i] == '\'' {
+						out.WriteByte('\'')
+					} else {
+						out.WriteByte('\\')
+						out.WriteByte(s[i])
+					}
+				case s[i] == '"':
+					out.WriteString(`\"`)
+				default:
+					out.WriteByte(s[i])
+				}
+			}
+			out.WriteByte('"')
+		case c == '$' || c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(s) && (s[end] == '$' || s[end] == '_' || s[end] == '.' || s[end] == '-' || unicode.IsLetter(rune(s[end])) || unicode.IsDigit(rune(s[end]))) {
+				end++
+			}
+			word := s[i:end]
+			if isKeyEnd(s, end) {
+				out.WriteString(`"` + word + `"`)
+			} else {
+				out.WriteString(word)
+			}
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// isKeyEnd returns true if the text at i, after optional spaces, is the colon ending an object key.
+func isKeyEnd(s string, i int) bool {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i < len(s) && s[i] == ':'
+}
+
+// parseValue parses a relaxed JSON argument.
+func parseValue(s string) (any, error) {
+	var v any
+	decoder := json.NewDecoder(strings.NewReader(relaxedJSON(s)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid argument %s: %w", s, err)
+	}
+	return normalizeNumbers(v), nil
+}
+
+// normalizeNumbers converts json.Number values into float64, the representation used for stored documents.
+func normalizeNumbers(v any) any {
+	switch value := v.(type) {
+	case json.Number:
+		f, _ := value.Float64()
+		return f
+	case map[string]any:
+		for k, i := range value {
+			value[k] = normalizeNumbers(i)
+		}
+	case []any:
+		for k, i := range value {
+			value[k] = normalizeNumbers(i)
+		}
+	}
+	return v
+}
+
+// sortField is a single key of a sort specification, direction is 1 or -1.
+type sortField struct {
+	path      string
+	direction int
+}
+
+// parseSort parses a sort specification keeping the order of its keys.
+func parseSort(s string) ([]sortField, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(relaxedJSON(s))))
+	decoder.UseNumber()
+	if t, err := decoder.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("sort expects an object such as {name: 1}")
+	}
+	var fields []sortField
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := t.(string)
+		t, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		n, ok := t.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("sort direction of %s must be 1 or -1", key)
+		}
+		direction, _ := n.Int64()
+		if direction != 1 && direction != -1 {
+			return nil, fmt.Errorf("sort direction of %s must be 1 or -1", key)
+		}
+		fields = append(fields, sortField{path: key, direction: int(direction)})
+	}
+	return fields, nil
+}
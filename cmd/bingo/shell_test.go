@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"github.com/nokusukun/bingo"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatement(t *testing.T) {
+	stmt, err := parseStatement(`db.users.find({name: 'o\'brien', "age": {$gte: 18}}).sort({age: -1, name: 1}).limit(10);`)
+	if err != nil {
+		t.Fatalf("Failed to parse statement: %v", err)
+	}
+	if stmt.collection != "users" || len(stmt.calls) != 3 {
+		t.Fatalf("Unexpected statement: %+v", stmt)
+	}
+
+	filter, err := parseValue(stmt.calls[0].args[0])
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	want := map[string]any{"name": "o'brien", "age": map[string]any{"$gte": float64(18)}}
+	if !reflect.DeepEqual(filter, want) {
+		t.Fatalf("Unexpected filter: %v", filter)
+	}
+
+	sortBy, err := parseSort(stmt.calls[1].args[0])
+	if err != nil {
+		t.Fatalf("Failed to parse sort: %v", err)
+	}
+	if !reflect.DeepEqual(sortBy, []sortField{{"age", -1}, {"name", 1}}) {
+		t.Fatalf("Unexpected sort: %v", sortBy)
+	}
+
+	stmt, err = parseStatement(`db.getCollection("audit:log").count()`)
+	if err != nil || stmt.collection != "audit:log" || stmt.calls[0].name != "count" || len(stmt.calls[0].args) != 0 {
+		t.Fatalf("Unexpected statement: %+v (%v)", stmt, err)
+	}
+
+	for _, invalid := range []string{"users.find()", "db.users", "db.users.find({)", "db.users.find() .limit"} {
+		if _, err := parseStatement(invalid); err == nil {
+			t.Fatalf("Expected %q to fail", invalid)
+		}
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	doc := map[string]any{"_id": "1", "name": "a", "visits": float64(1), "address": map[string]any{"city": "x"}}
+	updated, err := applyUpdate(doc, map[string]any{
+		"$set":   map[string]any{"address.zip": "123"},
+		"$unset": map[string]any{"address.city": ""},
+		"$inc":   map[string]any{"visits": float64(2)},
+	}, "_id")
+	if err != nil {
+		t.Fatalf("Failed to apply update: %v", err)
+	}
+	want := map[string]any{"_id": "1", "name": "a", "visits": float64(3), "address": map[string]any{"zip": "123"}}
+	if !reflect.DeepEqual(updated, want) {
+		t.Fatalf("Unexpected document: %v", updated)
+	}
+
+	replaced, _ := applyUpdate(doc, map[string]any{"name": "b"}, "_id")
+	if !reflect.DeepEqual(replaced, map[string]any{"_id": "1", "name": "b"}) {
+		t.Fatalf("Expected replacement to keep the key: %v", replaced)
+	}
+}
+
+func TestShellUpdate(t *testing.T) {
+	driver, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer driver.Close()
+
+	var out bytes.Buffer
+	s := &shell{ctx: &context{driver: driver}, writable: true, out: &out, mode: "json", fields: map[string][]string{}}
+	// update changes the first match only, updateMany every match
+	tests := []struct {
+		statement string
+		want      string
+	}{
+		{`db.fruits.insert([{_id: "a", stock: 0}, {_id: "b", stock: 0}, {_id: "c", stock: 1}])`, "inserted c\n"},
+		{`db.fruits.update({stock: 0}, {$inc: {stock: 5}})`, "updated 1\n"},
+		{`db.fruits.count({stock: 0})`, "1\n"},
+		{`db.fruits.updateOne({stock: 0}, {$inc: {stock: 5}})`, "updated 1\n"},
+		{`db.fruits.updateMany({}, {$set: {checked: true}})`, "updated 3\n"},
+		{`db.fruits.count({checked: true, stock: {$gt: 0}})`, "3\n"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if err := s.exec(tt.statement); err != nil {
+			t.Fatalf("Failed to run %s: %v", tt.statement, err)
+		}
+		if !bytes.HasSuffix(out.Bytes(), []byte(tt.want)) {
+			t.Fatalf("Expected %s to print %q, got %q", tt.statement, tt.want, out.String())
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "fmt"
+
+// makeRaw is not supported on this platform, the shell falls back to plain line input.
+func makeRaw(fd int) (func(), error) {
+	return nil, fmt.Errorf("raw terminal mode is not supported")
+}
@@ -1,11 +1,10 @@
 package bingo
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"github.com/bwmarrin/snowflake"
 	"go.etcd.io/bbolt"
-	"reflect"
 )
 
 type KeyMap map[string]any
@@ -21,57 +20,56 @@ type DocumentSpec interface {
 
 // Collection represents a collection of documents managed by a database driver.
 type Collection[DocumentType DocumentSpec] struct {
-	Driver       *Driver
-	Name         string
-	nameBytes    []byte
-	beforeUpdate func(doc *DocumentType) error
-	afterUpdate  func(doc *DocumentType) error
-	beforeDelete func(doc *DocumentType) error
-	afterDelete  func(doc *DocumentType) error
-	beforeInsert func(doc *DocumentType) error
-	afterInsert  func(doc *DocumentType) error
-	OnNewId      func(count int, document *DocumentType) []byte
+	Driver    *Driver
+	Name      string
+	nameBytes []byte
+	hooks     *hookChain[DocumentType]
+	// OnNewId returns the key of a new document, count increases with every key generated and is never reused,
+	// see WithKeyGenerator for generators persisted with the collection.
+	OnNewId   func(count int, document *DocumentType) []byte
+	batch     bool
+	corrupt   CorruptOptions
+	options   CollectionOptions
+	codec     Codec
+	generator KeyGenerator
+	fields    *fieldPlan
 }
 
 // BeforeUpdate registers a function to be called before a document is updated in the collection.
+// It replaces the function registered by a previous call, use Use to add more hooks to the event.
 func (c *Collection[T]) BeforeUpdate(f func(doc *T) error) *Collection[T] {
-	c.beforeUpdate = f
-	return c
+	return c.setHook(HookBeforeUpdate, f)
 }
 
 // AfterUpdate registers a function to be called after a document is updated in the collection.
 func (c *Collection[T]) AfterUpdate(f func(doc *T) error) *Collection[T] {
-	c.afterUpdate = f
-	return c
+	return c.setHook(HookAfterUpdate, f)
 }
 
 // BeforeDelete registers a function to be called before a document is deleted from the collection.
 func (c *Collection[T]) BeforeDelete(f func(doc *T) error) *Collection[T] {
-	c.beforeDelete = f
-	return c
+	return c.setHook(HookBeforeDelete, f)
 }
 
 // AfterDelete registers a function to be called after a document is deleted from the collection.
 func (c *Collection[T]) AfterDelete(f func(doc *T) error) *Collection[T] {
-	c.afterDelete = f
-	return c
+	return c.setHook(HookAfterDelete, f)
 }
 
 // BeforeInsert registers a function to be called before a document is inserted into the collection.
 func (c *Collection[T]) BeforeInsert(f func(doc *T) error) *Collection[T] {
-	c.beforeInsert = f
-	return c
+	return c.setHook(HookBeforeInsert, f)
 }
 
 // AfterInsert registers a function to be called after a document is inserted into the collection.
 func (c *Collection[T]) AfterInsert(f func(doc *T) error) *Collection[T] {
-	c.afterInsert = f
-	return c
+	return c.setHook(HookAfterInsert, f)
 }
 
 type InsertOptions struct {
 	IgnoreErrors bool
 	Upsert       bool
+	Batch        bool
 }
 
 func IgnoreErrors(opts *InsertOptions) {
@@ -82,9 +80,163 @@ func Upsert(opts *InsertOptions) {
 	opts.Upsert = true
 }
 
+// Batch makes Insert share its transaction with concurrent writers, see Collection.Batched.
+func Batch(opts *InsertOptions) {
+	opts.Batch = true
+}
+
+// Batched returns a copy of the collection whose Insert, UpdateOne and DeleteOne calls go through bbolt's DB.Batch,
+// coalescing concurrent writers into shared transactions. Set DriverConfiguration.BatchWrites to batch every collection.
+// Validate, Before and After hooks run outside the shared transaction so they are never repeated when bbolt retries
+// a batch, AfterTx hooks run inside it and may be. Hooks registered on the original collection after calling Batched are not copied.
+func (c *Collection[T]) Batched() *Collection[T] {
+	batched := *c
+	batched.batch = true
+	batched.hooks = c.hooks.clone()
+	return &batched
+}
+
+func (c *Collection[T]) batched(opt *InsertOptions) bool {
+	return c.batch || c.Driver.config.BatchWrites || (opt != nil && opt.Batch)
+}
+
+// update runs a single document write, through DB.Batch when the collection is batched.
+// f may be called more than once and must only touch the transaction.
+func (c *Collection[T]) update(batch bool, f func(tx *bbolt.Tx) error) error {
+	if c.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if batch {
+		return c.Driver.batch(f)
+	}
+	return c.Driver.update(f)
+}
+
+// encode returns the stored form of a document, using the codec of the collection.
+func (c *Collection[T]) encode(doc T) ([]byte, error) {
+	if c.codec == nil {
+		return Marshaller.Marshal(doc)
+	}
+	return c.codec.Marshal(doc)
+}
+
+// unmarshal decodes a stored document, using the codec of the collection.
+func (c *Collection[T]) unmarshal(data []byte, doc *T) error {
+	if c.codec == nil {
+		return Unmarshaller.Unmarshal(data, doc)
+	}
+	return c.codec.Unmarshal(data, doc)
+}
+
+// validate validates a document with the validator of the collection, see CollectionOptions, then with its Validate
+// method if it is Validatable.
+func (c *Collection[T]) validate(doc T) error {
+	if c.options.SkipValidation {
+		return nil
+	}
+	v := c.options.Validator
+	if v == nil {
+		v = c.Driver.val
+	}
+	return validationError(c.Name, doc.Key(), validateDocument(v, doc))
+}
+
+// put stores an encoded document, maintaining the indexes, reference indexes and expiry of the collection.
+// Nothing is written when the document cannot be stored, so callers skipping its error leave no stale entries.
+func (c *Collection[T]) put(tx *bbolt.Tx, bucket *bbolt.Bucket, key, data []byte, doc *T) error {
+	if err := checkEntries(c.options.Indexes, c.options.References, key, data, *doc); err != nil {
+		return err
+	}
+	if len(c.options.Indexes) > 0 || len(c.options.References) > 0 {
+		old := c.stored(bucket.Get(key))
+		if err := reindex(tx, c.Name, c.options.Indexes, key, old, *doc); err != nil {
+			return err
+		}
+		if err := reindexReferences(tx, c.Name, c.options.References, key, old, *doc); err != nil {
+			return err
+		}
+	}
+	if err := bucket.Put(key, data); err != nil {
+		return err
+	}
+	return c.expireAfter(tx, key)
+}
+
+// stored decodes a stored document for reindexing, nil if it is missing or cannot be decoded.
+func (c *Collection[T]) stored(value []byte) any {
+	if value == nil || (len(c.options.Indexes) == 0 && len(c.options.References) == 0) {
+		return nil
+	}
+	var stored T
+	if c.unmarshal(value, &stored) != nil {
+		return nil
+	}
+	return stored
+}
+
+// remove deletes a stored document after applying the policies of the references to it, see RefPolicy.
+// Soft deleted documents are moved to the __deleted:<collection> bucket.
+func (c *Collection[T]) remove(tx *bbolt.Tx, bucket *bbolt.Bucket, key []byte, soft bool) error {
+	if bucket.Get(key) == nil {
+		return nil
+	}
+	if err := c.Driver.enforceReferences(tx, c.Name, key, map[string]bool{}); err != nil {
+		return err
+	}
+	return removeStored(tx, c.Name, &c.options, bucket, key, c.stored(bucket.Get(key)), soft)
+}
+
+// removeStored deletes a stored document of a collection along with its index entries, reference index entries and expiry.
+// old is the decoded document the entries are removed for, nil leaves them. Soft deleted documents are moved to the
+// __deleted:<collection> bucket.
+func removeStored(tx *bbolt.Tx, collection string, options *CollectionOptions, bucket *bbolt.Bucket, key []byte, old any, soft bool) error {
+	value := bucket.Get(key)
+	if value == nil {
+		return nil
+	}
+	value = append([]byte{}, value...)
+	if err := reindex(tx, collection, options.Indexes, key, old, nil); err != nil {
+		return err
+	}
+	if err := reindexReferences(tx, collection, options.References, key, old, nil); err != nil {
+		return err
+	}
+	if soft {
+		deleted, err := tx.CreateBucketIfNotExists([]byte(DELETED_COLLECTION_NAME + collection))
+		if err != nil {
+			return err
+		}
+		if err := deleted.Put(key, value); err != nil {
+			return err
+		}
+	}
+	if ttl := tx.Bucket([]byte(TTL_COLLECTION_NAME + collection)); ttl != nil {
+		if err := ttl.Delete(key); err != nil {
+			return err
+		}
+	}
+	return bucket.Delete(key)
+}
+
 // Insert inserts a document into the collection. If upsert and ignoreErrors are not set, an error is returned if the document already exists.
 // If IgnoreErrors is passed without Upsert, the document is not inserted and no error is returned if the document already exists.
 func (c *Collection[T]) Insert(document T, opts ...func(options *InsertOptions)) ([]byte, error) {
+	var key []byte
+	err := c.around("Insert", func() (err error) {
+		key, err = c.insert(document, opts...)
+		return err
+	})
+	return key, err
+}
+
+func (c *Collection[T]) insert(document T, opts ...func(options *InsertOptions)) ([]byte, error) {
+	opt := &InsertOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+	if c.batched(opt) {
+		return c.insertBatched(document, opt)
+	}
 	ids, err := c.inserts([]T{document}, opts...)
 	if err != nil {
 		return nil, err
@@ -97,7 +249,12 @@ func (c *Collection[T]) Insert(document T, opts ...func(options *InsertOptions))
 
 // InsertMany inserts a document into the collection. If the document already exists, an error is returned.
 func (c *Collection[T]) InsertMany(documents []T, opts ...func(options *InsertOptions)) ([][]byte, error) {
-	return c.inserts(documents, opts...)
+	var keys [][]byte
+	err := c.around("InsertMany", func() (err error) {
+		keys, err = c.inserts(documents, opts...)
+		return err
+	})
+	return keys, err
 }
 
 func (c *Collection[T]) inserts(docs []T, opts ...func(options *InsertOptions)) ([][]byte, error) {
@@ -105,97 +262,222 @@ func (c *Collection[T]) inserts(docs []T, opts ...func(options *InsertOptions))
 	for _, o := range opts {
 		o(opt)
 	}
+	if c.options.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	// documents failing to prepare are left nil when errors are ignored
+	prepared := make([]*T, len(docs))
+	for i := range docs {
+		doc := docs[i]
+		if err := c.prepare(insertLifecycle, &doc); err != nil {
+			if !opt.IgnoreErrors {
+				return nil, err
+			}
+			continue
+		}
+		prepared[i] = &doc
+	}
 
 	var results [][]byte
-	err := c.Driver.db.Update(func(tx *bbolt.Tx) error {
+	var stored []*T
+	err := c.update(false, func(tx *bbolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(c.nameBytes)
 		if err != nil {
 			return err
 		}
 
-		for _, doc := range docs {
-			id, err := c.insertWithTx(bucket, doc, opt)
-			if !opt.IgnoreErrors && err != nil {
+		for _, doc := range prepared {
+			if doc == nil {
+				results = append(results, nil)
+				continue
+			}
+			id, err := c.insertWithTx(tx, bucket, doc, opt)
+			if err != nil {
+				if !opt.IgnoreErrors {
+					return err
+				}
+				results = append(results, nil)
+				continue
+			}
+			// the document is stored, an AfterTx error rolls the whole transaction back even when errors are ignored,
+			// like BulkLoader fails its whole batch
+			if err := c.written(insertLifecycle, doc); err != nil {
 				return err
 			}
 			results = append(results, id)
+			stored = append(stored, doc)
 		}
 		return nil
 	})
+	if err != nil {
+		return results, err
+	}
 
-	return results, err
+	if err := c.committed(insertLifecycle, stored...); err != nil && !opt.IgnoreErrors {
+		return results, err
+	}
+	return results, nil
 }
 
-func (c *Collection[T]) insertWithTx(bucket *bbolt.Bucket, doc T, opt *InsertOptions) ([]byte, error) {
+// insertWithTx runs the write phase of inserting a prepared document, the caller runs its after-transaction phase.
+func (c *Collection[T]) insertWithTx(tx *bbolt.Tx, bucket *bbolt.Bucket, doc *T, opt *InsertOptions) ([]byte, error) {
 	if !opt.Upsert {
-		_, err := c.FindByBytesKey(doc.Key())
-		if err == nil {
-			return nil, ErrDocumentExists
+		if key := (*doc).Key(); len(key) > 0 && bucket.Get(key) != nil {
+			return nil, documentExists(c.Name, key)
 		}
 	}
 
-	if err := c.Driver.val.Struct(doc); err != nil {
+	generated := len((*doc).Key()) == 0
+	idBytes, err := c.getKey(bucket, doc)
+	if err != nil {
 		return nil, err
 	}
-
-	if c.beforeInsert != nil {
-		err := c.beforeInsert(&doc)
-		if err != nil {
-			return nil, err
-		}
+	if generated && bucket.Get(idBytes) != nil {
+		return nil, documentExists(c.Name, idBytes)
 	}
+	c.keepCreated(doc, bucket.Get(idBytes))
 
-	idBytes := c.getKey(bucket, &doc)
-
-	marshal, err := Marshaller.Marshal(doc)
+	marshal, err := c.encode(*doc)
 	if err != nil {
 		return nil, err
 	}
 
-	err = bucket.Put(idBytes, marshal)
+	err = c.put(tx, bucket, idBytes, marshal, doc)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if c.afterInsert != nil {
-		err := c.afterInsert(&doc)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	return idBytes, nil
 }
 
-var node *snowflake.Node
+// insertBatched inserts a document through DB.Batch. The document is validated and hooked before the shared
+// transaction, which only checks for an existing document, stores it and runs the after-transaction phase.
+// Like inserts, IgnoreErrors only skips the errors of the document, not those of the transaction or its AfterTx hooks.
+func (c *Collection[T]) insertBatched(doc T, opt *InsertOptions) ([]byte, error) {
+	if c.options.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if err := c.prepare(insertLifecycle, &doc); err != nil {
+		if opt.IgnoreErrors {
+			return nil, nil
+		}
+		return nil, err
+	}
 
-func (c *Collection[T]) getKey(bucket *bbolt.Bucket, doc *T) []byte {
-	if node == nil {
+	// a generated key is only known inside the shared transaction, the document is encoded there
+	var key []byte
+	var marshal []byte
+	if key = doc.Key(); len(key) > 0 {
 		var err error
-		node, err = snowflake.NewNode(1)
+		if marshal, err = c.encode(doc); err != nil {
+			if opt.IgnoreErrors {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	stored := doc
+	err := c.update(true, func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(c.nameBytes)
 		if err != nil {
-			panic(fmt.Errorf("unable to create snowflake node: %v", err))
+			return err
+		}
+		data := marshal
+		stored = doc
+		storedKey := key
+		if len(storedKey) == 0 {
+			if storedKey, err = c.getKey(bucket, &stored); err != nil {
+				return &documentError{err}
+			}
+			if data, err = c.encode(stored); err != nil {
+				return &documentError{err}
+			}
+		}
+		if existing := bucket.Get(storedKey); existing != nil {
+			if !opt.Upsert || len(key) == 0 {
+				return &documentError{documentExists(c.Name, storedKey)}
+			}
+			// the upserted document keeps the time the stored one was created at
+			if c.keepCreated(&stored, existing) {
+				if data, err = c.encode(stored); err != nil {
+					return &documentError{err}
+				}
+			}
 		}
+		if err := c.put(tx, bucket, storedKey, data, &stored); err != nil {
+			return &documentError{err}
+		}
+		return c.written(insertLifecycle, &stored)
+	})
+	var skipped *documentError
+	if errors.As(err, &skipped) {
+		if opt.IgnoreErrors {
+			return nil, nil
+		}
+		return nil, skipped.err
+	}
+	if err != nil {
+		return nil, err
 	}
-	var idBytes []byte
+	doc = stored
+
+	if err := c.committed(insertLifecycle, &doc); err != nil && !opt.IgnoreErrors {
+		return nil, err
+	}
+	return doc.Key(), nil
+}
 
+// documentError marks the errors of a single document of a batched insert, skipped by IgnoreErrors unlike the errors
+// of the shared transaction.
+type documentError struct {
+	err error
+}
+
+func (e *documentError) Error() string {
+	return e.err.Error()
+}
+
+// getKey returns the key of a document, generating and setting it if the document has none.
+func (c *Collection[T]) getKey(bucket *bbolt.Bucket, doc *T) ([]byte, error) {
 	key := (*doc).Key()
-	if len(key) == 0 {
-		idBytes = []byte(node.Generate().Base58())
-		if c.OnNewId != nil {
-			idBytes = c.OnNewId(bucket.Stats().KeyN, doc)
+	if len(key) > 0 {
+		return key, nil
+	}
+	if c.OnNewId != nil {
+		n, err := nextSequence(bucket)
+		if err != nil {
+			return nil, err
+		}
+		key = c.OnNewId(int(n-1), doc)
+	} else if c.generator != nil {
+		var err error
+		if key, err = c.generator(bucket); err != nil {
+			return nil, err
 		}
-		reflect.ValueOf(doc).Elem().FieldByName("ID").SetString(string(idBytes))
 	} else {
-		idBytes = key
+		key = newSnowflakeId()
+	}
+	if err := SetKey(doc, key); err != nil {
+		return nil, err
 	}
-	return idBytes
+	return key, nil
 }
 
 func (c *Collection[T]) FindOneWithKey(filter func(doc T) bool) (T, []byte, error) {
+	var doc T
+	var key []byte
+	err := c.around("FindOne", func() (err error) {
+		doc, key, err = c.findOne(filter)
+		return err
+	})
+	return doc, key, err
+}
+
+func (c *Collection[T]) findOne(filter func(doc T) bool) (T, []byte, error) {
 	var empty T
-	r, keys, _, err := c.queryFind(Query[T]{
+	r, keys, _, _, err := c.queryFind(Query[T]{
 		Filter: filter,
 	})
 
@@ -204,7 +486,7 @@ func (c *Collection[T]) FindOneWithKey(filter func(doc T) bool) (T, []byte, erro
 	}
 
 	if len(r) == 0 {
-		return empty, nil, errors.Join(ErrDocumentNotFound, fmt.Errorf("document not found"))
+		return empty, nil, documentNotFound(c.Name, nil)
 	}
 
 	return r[0], keys[0], err
@@ -244,24 +526,51 @@ func Count(count int) IterOptsFunc {
 }
 
 func (c *Collection[T]) FindWithKeys(filter func(doc T) bool, opts ...IterOptsFunc) ([]T, [][]byte, error) {
+	var docs []T
+	var keys [][]byte
+	err := c.around("Find", func() (err error) {
+		docs, keys, err = c.findWithKeys(filter, opts...)
+		return err
+	})
+	return docs, keys, err
+}
+
+func (c *Collection[T]) findWithKeys(filter func(doc T) bool, opts ...IterOptsFunc) ([]T, [][]byte, error) {
 	q := Query[T]{
 		Filter: filter,
 	}
 	applyOpts[T](&q, opts...)
 
-	r, keys, _, err := c.queryFind(q)
+	r, keys, _, _, err := c.queryFind(q)
 
 	if err != nil {
 		return nil, nil, err
 	}
 
 	if len(r) == 0 {
-		return nil, nil, errors.Join(ErrDocumentNotFound, fmt.Errorf("document not found"))
+		return nil, nil, documentNotFound(c.Name, nil)
 	}
 
 	return r, keys, err
 }
 
+// FindByPrefix returns the documents whose key starts with prefix, such as the leading parts of keys built with Key.
+// An empty list is returned if no key starts with prefix.
+func (c *Collection[T]) FindByPrefix(prefix []byte, opts ...IterOptsFunc) ([]T, error) {
+	var documents []T
+	err := c.around("FindByPrefix", func() error {
+		q := Query[T]{Prefix: prefix}
+		applyOpts[T](&q, opts...)
+		var err error
+		documents, _, _, _, err = c.queryFind(q)
+		if IsErrCollectionNotFound(err) {
+			return nil
+		}
+		return err
+	})
+	return documents, err
+}
+
 func (c *Collection[T]) Find(filter func(doc T) bool, opts ...IterOptsFunc) ([]T, error) {
 	r, _, err := c.FindWithKeys(filter, opts...)
 
@@ -289,58 +598,34 @@ func (c *Collection[T]) Update(docs ...T) error {
 // Deprecated: FindByBytesId retrieves a document from the collection by its id. If the document is not found, an error is returned.
 // Use FindByBytesKey instead
 func (c *Collection[T]) FindByBytesId(id []byte) (T, error) {
-	var document T
-	r := c.queryKeys(id)
-	if len(r) == 0 {
-		return document, errors.Join(ErrDocumentNotFound, fmt.Errorf("document with id %v not found", string(id)))
-	}
-	document = r[0]
-	return document, nil
+	return c.findKey(id)
 }
 
 // FindByBytesKey retrieves a document from the collection by its id. If the document is not found, an error is returned.
 func (c *Collection[T]) FindByBytesKey(id []byte) (T, error) {
-	var document T
-	r := c.queryKeys(id)
-	if len(r) == 0 {
-		return document, errors.Join(ErrDocumentNotFound, fmt.Errorf("document with id %v not found", string(id)))
-	}
-	document = r[0]
-	return document, nil
+	return c.findKey(id)
 }
 
 // Deprecated: FindByBytesIds retrieves documents from the collection by their ids. If the document is not found, an empty list is returned.
 // Use FindByBytesKeys instead
 func (c *Collection[T]) FindByBytesIds(ids ...[]byte) []T {
-	return c.queryKeys(ids...)
+	return c.findKeys(ids...)
 }
 
 // FindByBytesKeys retrieves documents from the collection by their ids. If the document is not found, an empty list is returned.
 func (c *Collection[T]) FindByBytesKeys(ids ...[]byte) []T {
-	return c.queryKeys(ids...)
+	return c.findKeys(ids...)
 }
 
 // Deprecated: FindById retrieves a document from the collection by its id. If the document is not found, an error is returned.
 // Use FindByKey instead
 func (c *Collection[T]) FindById(id string) (T, error) {
-	var document T
-	r := c.queryKeys([]byte(id))
-	if len(r) == 0 {
-		return document, errors.Join(ErrDocumentNotFound, fmt.Errorf("document with id %v not found", string(id)))
-	}
-	document = r[0]
-	return document, nil
+	return c.findKey([]byte(id))
 }
 
 // FindByKey retrieves a document from the collection by its id. If the document is not found, an error is returned.
 func (c *Collection[T]) FindByKey(id string) (T, error) {
-	var document T
-	r := c.queryKeys([]byte(id))
-	if len(r) == 0 {
-		return document, errors.Join(ErrDocumentNotFound, fmt.Errorf("document with id %v not found", string(id)))
-	}
-	document = r[0]
-	return document, nil
+	return c.findKey([]byte(id))
 }
 
 // Deprecated: FindByIds retrieves documents from the collection by their ids. If the document is not found, an empty list is returned.
@@ -350,7 +635,7 @@ func (c *Collection[T]) FindByIds(ids ...string) []T {
 	for i, id := range ids {
 		idsBytes[i] = []byte(id)
 	}
-	return c.queryKeys(idsBytes...)
+	return c.findKeys(idsBytes...)
 }
 
 // FindByKeys retrieves documents from the collection by their ids. If the document is not found, an empty list is returned.
@@ -359,211 +644,424 @@ func (c *Collection[T]) FindByKeys(ids ...string) []T {
 	for i, id := range ids {
 		idsBytes[i] = []byte(id)
 	}
-	return c.queryKeys(idsBytes...)
+	return c.findKeys(idsBytes...)
 }
 
 // UpdateIter updates documents in the collection that match the filter function.
 // The updateFunc is called on each document that matches the filter function.
 // return the document from the updateFunc to update the document, otherwise return nil to skip the document.
 func (c *Collection[T]) UpdateIter(updateFunc func(*T) *T) error {
+	return c.around("UpdateIter", func() error {
+		return c.updateIter(updateFunc)
+	})
+}
 
-	return c.Driver.db.Update(func(tx *bbolt.Tx) error {
+func (c *Collection[T]) updateIter(updateFunc func(*T) *T) error {
+	var corrupt []CorruptDocument
+	defer func() {
+		c.handleCorrupt(corrupt)
+	}()
+	var updated []*T
+	err := c.update(false, func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(c.nameBytes)
 		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", c.Name)
+			return collectionNotFound(c.Name)
 		}
+		expired := c.expired(tx)
 		wbucket := &WrappedBucket{bucket}
 		return wbucket.ReverseIter(func(k, v []byte) error {
+			if expired(k) {
+				return nil
+			}
 			var document T
-			err := Unmarshaller.Unmarshal(v, &document)
-			if err != nil {
+			ok, err := c.decode(k, v, &document, &corrupt)
+			if err != nil || !ok {
 				return err
 			}
 			newDocument := updateFunc(&document)
 			if newDocument == nil {
 				return nil
 			}
-			if c.beforeUpdate != nil {
-				err := c.beforeUpdate(newDocument)
-				if err != nil {
-					return err
-				}
+			if err := c.prepare(updateLifecycle, newDocument); err != nil {
+				return err
 			}
 
-			marshal, err := Marshaller.Marshal(newDocument)
+			marshal, err := c.encode(*newDocument)
 			if err != nil {
 				return err
 			}
-			err = bucket.Put(document.Key(), marshal)
+			err = c.put(tx, bucket, document.Key(), marshal, newDocument)
 			if err != nil {
 				return err
 			}
 
-			if c.afterUpdate != nil {
-				err := c.afterUpdate(newDocument)
-				if err != nil {
-					return err
-				}
+			if err := c.written(updateLifecycle, newDocument); err != nil {
+				return err
 			}
+			updated = append(updated, newDocument)
 			return nil
 		})
 	})
+	if err != nil {
+		return err
+	}
+	return c.committed(updateLifecycle, updated...)
 }
 
 // DeleteIter deletes documents from the collection that match the filter function.
 // The deleteFunc is called on each document that matches the filter function.
 // return true from the deleteFunc to delete the document, otherwise return false to skip the document.
 func (c *Collection[T]) DeleteIter(deleteFunc func(*T) bool) error {
+	return c.around("DeleteIter", func() error {
+		return c.deleteIter(deleteFunc)
+	})
+}
 
-	return c.Driver.db.Update(func(tx *bbolt.Tx) error {
+func (c *Collection[T]) deleteIter(deleteFunc func(*T) bool) error {
+	var corrupt []CorruptDocument
+	defer func() {
+		c.handleCorrupt(corrupt)
+	}()
+	var deleted []*T
+	err := c.update(false, func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(c.nameBytes)
 		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", c.Name)
+			return collectionNotFound(c.Name)
 		}
+		expired := c.expired(tx)
 		wbucket := &WrappedBucket{bucket}
 		return wbucket.ReverseIter(func(k, v []byte) error {
+			if expired(k) {
+				return nil
+			}
 			var document T
-			err := Unmarshaller.Unmarshal(v, &document)
-			if err != nil {
+			ok, err := c.decode(k, v, &document, &corrupt)
+			if err != nil || !ok {
 				return err
 			}
 			if !deleteFunc(&document) {
 				return nil
 			}
-			if c.beforeDelete != nil {
-				err := c.beforeDelete(&document)
-				if err != nil {
-					return err
-				}
+			if err := c.prepare(deleteLifecycle, &document); err != nil {
+				return err
 			}
 
-			err = bucket.Delete(document.Key())
+			err = c.remove(tx, bucket, document.Key(), c.options.SoftDelete)
 			if err != nil {
 				return err
 			}
 
-			if c.afterDelete != nil {
-				err := c.afterDelete(&document)
-				if err != nil {
-					return err
-				}
+			if err := c.written(deleteLifecycle, &document); err != nil {
+				return err
 			}
+			deleted = append(deleted, &document)
 			return nil
 		})
 	})
+	if err != nil {
+		return err
+	}
+	return c.committed(deleteLifecycle, deleted...)
 }
 
 // UpdateOne updates a document in the collection.
 func (c *Collection[T]) UpdateOne(doc T) error {
-	if c.beforeUpdate != nil {
-		err := c.beforeUpdate(&doc)
-		if err != nil {
-			return err
-		}
+	return c.around("UpdateOne", func() error {
+		return c.updateOne(doc)
+	})
+}
+
+func (c *Collection[T]) updateOne(doc T) error {
+	if c.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := c.prepare(updateLifecycle, &doc); err != nil {
+		return err
 	}
 
-	marshal, err := Marshaller.Marshal(doc)
+	marshal, err := c.encode(doc)
 	if err != nil {
 		return err
 	}
 
-	err = c.Driver.db.Update(func(tx *bbolt.Tx) error {
+	stored := doc
+	err = c.update(c.batched(nil), func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(c.nameBytes)
 		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", c.Name)
+			return collectionNotFound(c.Name)
 		}
-		return bucket.Put(doc.Key(), marshal)
+		stored = doc
+		if err := c.put(tx, bucket, doc.Key(), marshal, &stored); err != nil {
+			return err
+		}
+		return c.written(updateLifecycle, &stored)
 	})
 	if err != nil {
 		return err
 	}
 
-	if c.afterUpdate != nil {
-		err := c.afterUpdate(&doc)
+	return c.committed(updateLifecycle, &stored)
+}
+
+// UpdateByKeyFunc reads the document stored under a key, changes it with f and writes it back in a single write
+// transaction, so no concurrent write is lost between the read and the write. An error wrapping ErrDocumentNotFound
+// is returned if there is no document, the error of f aborts the update. f must not change the key of the document,
+// its createdAt fields keep their stored value. The document goes through the update lifecycle inside the transaction,
+// like UpdateIter.
+func (c *Collection[T]) UpdateByKeyFunc(key string, f func(doc *T) error) error {
+	return c.around("UpdateByKeyFunc", func() error {
+		return c.updateByKeyFunc([]byte(key), f)
+	})
+}
+
+func (c *Collection[T]) updateByKeyFunc(key []byte, f func(doc *T) error) error {
+	if c.options.ReadOnly {
+		return ErrReadOnly
+	}
+	var corrupt []CorruptDocument
+	defer func() {
+		c.handleCorrupt(corrupt)
+	}()
+	var updated T
+	err := c.update(false, func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(c.nameBytes)
+		if bucket == nil {
+			return collectionNotFound(c.Name)
+		}
+		value := bucket.Get(key)
+		if value == nil || c.expired(tx)(key) {
+			return documentNotFound(c.Name, key)
+		}
+		updated = *new(T)
+		ok, err := c.decode(key, value, &updated, &corrupt)
 		if err != nil {
 			return err
 		}
+		if !ok {
+			return documentNotFound(c.Name, key)
+		}
+		if err := f(&updated); err != nil {
+			return err
+		}
+		if !bytes.Equal(updated.Key(), key) {
+			return fmt.Errorf("the key of document %s cannot be changed to %s", key, updated.Key())
+		}
+		c.keepCreated(&updated, value)
+		if err := c.prepare(updateLifecycle, &updated); err != nil {
+			return err
+		}
+		marshal, err := c.encode(updated)
+		if err != nil {
+			return err
+		}
+		if err := c.put(tx, bucket, key, marshal, &updated); err != nil {
+			return err
+		}
+		return c.written(updateLifecycle, &updated)
+	})
+	if err != nil {
+		return err
 	}
-	return nil
+	return c.committed(updateLifecycle, &updated)
 }
 
 // DeleteOne deletes a document from the collection.
 func (c *Collection[T]) DeleteOne(doc T) error {
-	if c.beforeDelete != nil {
-		err := c.beforeDelete(&doc)
-		if err != nil {
-			return err
-		}
+	return c.around("DeleteOne", func() error {
+		return c.deleteOne(doc)
+	})
+}
+
+func (c *Collection[T]) deleteOne(doc T) error {
+	if c.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := c.prepare(deleteLifecycle, &doc); err != nil {
+		return err
 	}
 
-	err := c.Driver.db.Update(func(tx *bbolt.Tx) error {
+	stored := doc
+	err := c.update(c.batched(nil), func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(c.nameBytes)
 		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", c.Name)
+			return collectionNotFound(c.Name)
 		}
-		return bucket.Delete(doc.Key())
+		stored = doc
+		if err := c.remove(tx, bucket, doc.Key(), c.options.SoftDelete); err != nil {
+			return err
+		}
+		return c.written(deleteLifecycle, &stored)
 	})
 	if err != nil {
 		return err
 	}
 
-	if c.afterDelete != nil {
-		err := c.afterDelete(&doc)
+	return c.committed(deleteLifecycle, &stored)
+}
+
+// DeleteByKey deletes the document stored under a key, an error wrapping ErrDocumentNotFound is returned if there is none.
+// The document is read and its hooks run inside the write transaction, like DeleteIter, so concurrent deletes of the
+// same key fail instead of both succeeding.
+func (c *Collection[T]) DeleteByKey(key string) error {
+	return c.around("DeleteByKey", func() error {
+		return c.deleteByKey([]byte(key))
+	})
+}
+
+func (c *Collection[T]) deleteByKey(key []byte) error {
+	if c.options.ReadOnly {
+		return ErrReadOnly
+	}
+	var corrupt []CorruptDocument
+	defer func() {
+		c.handleCorrupt(corrupt)
+	}()
+	var deleted T
+	err := c.update(false, func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(c.nameBytes)
+		if bucket == nil {
+			return collectionNotFound(c.Name)
+		}
+		value := bucket.Get(key)
+		if value == nil || c.expired(tx)(key) {
+			return documentNotFound(c.Name, key)
+		}
+		ok, err := c.decode(key, value, &deleted, &corrupt)
 		if err != nil {
 			return err
 		}
+		if !ok {
+			return documentNotFound(c.Name, key)
+		}
+		if err := c.prepare(deleteLifecycle, &deleted); err != nil {
+			return err
+		}
+		if err := c.remove(tx, bucket, key, c.options.SoftDelete); err != nil {
+			return err
+		}
+		return c.written(deleteLifecycle, &deleted)
+	})
+	if err != nil {
+		return err
 	}
-	return nil
+	return c.committed(deleteLifecycle, &deleted)
 }
 
 var stoperr = fmt.Errorf("stop")
 
-func (c *Collection[DocumentType]) queryKeys(keys ...[]byte) []DocumentType {
-	var documents []DocumentType
-	_ = c.Driver.db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(c.nameBytes)
-		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", c.Name)
+// queryKeys returns the documents stored under the keys along with their keys, missing keys are skipped.
+// The Ref fields listed in populate are populated in the same transaction.
+func (c *Collection[T]) queryKeys(populate []string, keys ...[]byte) ([]T, [][]byte, []CorruptDocument, error) {
+	var documents []T
+	var found [][]byte
+	var corrupt []CorruptDocument
+	var after populated
+	var failed error
+	err := c.Driver.view(func(tx *bbolt.Tx) error {
+		documents, found, failed = c.readKeys(tx, keys, &corrupt)
+		return c.populate(tx, documents, populate, &after)
+	})
+	warnings := append(c.handleCorrupt(corrupt), after.handleCorrupt()...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return documents, found, warnings, failed
+}
+
+// readKeys reads the documents stored under the keys inside a transaction, missing and expired keys are skipped.
+// The first error of a corrupt document is returned along with the documents read.
+func (c *Collection[T]) readKeys(tx *bbolt.Tx, keys [][]byte, corrupt *[]CorruptDocument) ([]T, [][]byte, error) {
+	var documents []T
+	var found [][]byte
+	var failed error
+	bucket := tx.Bucket(c.nameBytes)
+	if bucket == nil {
+		// keys of a collection without a bucket are simply not found
+		return nil, nil, nil
+	}
+	expired := c.expired(tx)
+	for _, key := range keys {
+		value := bucket.Get(key)
+		if value == nil || expired(key) {
+			continue
 		}
-		for _, key := range keys {
-			value := bucket.Get(key)
-			if value == nil {
-				continue
-			}
-			var document DocumentType
-			err := Unmarshaller.Unmarshal(value, &document)
-			if err != nil {
-				continue
-			}
-			documents = append(documents, document)
+		var document T
+		ok, err := c.decode(key, value, &document, corrupt)
+		if err != nil && failed == nil {
+			failed = err
 		}
+		if !ok {
+			continue
+		}
+		if err := c.runHooks(HookAfterFind, &document); err != nil {
+			return nil, nil, err
+		}
+		documents = append(documents, document)
+		found = append(found, key)
+	}
+	return documents, found, failed
+}
+
+// findKey returns the document stored under a key, failing if it is missing or corrupt.
+func (c *Collection[T]) findKey(key []byte) (T, error) {
+	var document T
+	err := c.around("FindByKey", func() error {
+		r, _, _, err := c.queryKeys(nil, key)
+		if err != nil {
+			return err
+		}
+		if len(r) == 0 {
+			return documentNotFound(c.Name, key)
+		}
+		document = r[0]
 		return nil
 	})
-	return documents
+	return document, err
+}
+
+// findKeys returns the documents stored under the keys, skipping missing and corrupt ones.
+func (c *Collection[T]) findKeys(keys ...[]byte) []T {
+	var r []T
+	_ = c.around("FindByKeys", func() error {
+		var err error
+		r, _, _, err = c.queryKeys(nil, keys...)
+		return err
+	})
+	return r
 }
 
-func (c *Collection[T]) queryFind(q Query[T]) ([]T, [][]byte, int, error) {
+func (c *Collection[T]) queryFind(q Query[T]) ([]T, [][]byte, int, []CorruptDocument, error) {
 	var documents []T
 	var keys [][]byte
+	var corrupt []CorruptDocument
+	var after populated
 	var currentFound = 0
 	var last = 0
-	err := c.Driver.db.View(func(tx *bbolt.Tx) error {
+	err := c.Driver.view(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(c.nameBytes)
 		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", c.Name)
+			return collectionNotFound(c.Name)
 		}
+		expired := c.expired(tx)
 		wbucket := &WrappedBucket{bucket}
-		return wbucket.ReverseIter(func(k, v []byte) error {
+		start, end := q.bounds()
+		err := wbucket.ReverseIterRange(start, end, func(k, v []byte) error {
+			if expired(k) {
+				return nil
+			}
 			last += 1
 			if last <= q.Skip {
 				return nil
 			}
 			var document T
-			err := Unmarshaller.Unmarshal(v, &document)
-			if err != nil {
+			ok, err := c.decode(k, v, &document, &corrupt)
+			if err != nil || !ok {
 				return err
 			}
-			if q.Filter(document) {
+			if q.Filter == nil || q.Filter(document) {
+				if err := c.runHooks(HookAfterFind, &document); err != nil {
+					return err
+				}
 				documents = append(documents, document)
 				keys = append(keys, k)
 				currentFound += 1
@@ -573,18 +1071,38 @@ func (c *Collection[T]) queryFind(q Query[T]) ([]T, [][]byte, int, error) {
 			}
 			return nil
 		})
+		if err != nil && !errors.Is(err, stoperr) {
+			return err
+		}
+		return c.populate(tx, documents, q.Populate, &after)
 	})
+	warnings := append(c.handleCorrupt(corrupt), after.handleCorrupt()...)
 	if err != nil && !errors.Is(err, stoperr) {
-		return documents, keys, last, err
+		return documents, keys, last, warnings, err
 	} else {
-		return documents, keys, last, nil
+		return documents, keys, last, warnings, nil
 	}
 }
 
 // Query executes the query and returns a QueryResult object that contains the results of the query.
 func (c *Collection[T]) Query(q Query[T]) *QueryResult[T] {
+	var result *QueryResult[T]
+	err := c.around("Query", func() error {
+		result = c.query(q)
+		return result.Error
+	})
+	if result == nil {
+		result = &QueryResult[T]{Collection: c}
+	}
+	if result.Error == nil {
+		result.Error = err
+	}
+	return result
+}
+
+func (c *Collection[T]) query(q Query[T]) *QueryResult[T] {
 	if q.Keys != nil && q.Filter != nil {
-		panic(fmt.Errorf("cannot use both key and filter"))
+		return &QueryResult[T]{Collection: c, Error: fmt.Errorf("cannot use both key and filter")}
 	}
 
 	if len(q.KeysStr) > 0 {
@@ -599,20 +1117,25 @@ func (c *Collection[T]) Query(q Query[T]) *QueryResult[T] {
 		Collection: c,
 	}
 	if q.Keys != nil {
-		items := c.queryKeys(q.Keys...)
+		items, keys, warnings, err := c.queryKeys(q.Populate, q.Keys...)
+		if err != nil {
+			result.Error = errors.Join(err, fmt.Errorf("error while querying"))
+		}
+		result.Warnings = warnings
 		for i, item := range items {
 			item := item
 			result.Items = append(result.Items, &item)
-			result.Keys = append(result.Keys, q.Keys[i])
+			result.Keys = append(result.Keys, keys[i])
 		}
 		return result
 	}
 
-	if q.Filter != nil {
-		items, keys, last, err := c.queryFind(q)
+	if q.Filter != nil || q.Prefix != nil || q.Start != nil || q.End != nil {
+		items, keys, last, warnings, err := c.queryFind(q)
 		if err != nil {
 			result.Error = errors.Join(err, fmt.Errorf("error while querying"))
 		}
+		result.Warnings = warnings
 		result.Next = last
 		for i, item := range items {
 			item := item
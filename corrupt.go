@@ -0,0 +1,133 @@
+package bingo
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+)
+
+var ErrCorruptDocument = fmt.Errorf("corrupt document")
+
+// IsErrCorruptDocument returns true if the error is an ErrCorruptDocument error.
+func IsErrCorruptDocument(err error) bool {
+	return errors.Is(err, ErrCorruptDocument)
+}
+
+// CorruptPolicy selects what reads do with stored documents that do not unmarshal into the collection's type.
+type CorruptPolicy int
+
+const (
+	// CorruptFail fails the read with an ErrCorruptDocument error. It is the default.
+	// Reads that cannot return an error, such as FindByKeys, skip the document.
+	CorruptFail CorruptPolicy = iota
+	// CorruptSkip leaves the document out of the result.
+	CorruptSkip
+	// CorruptReport leaves the document out of the result and reports it,
+	// to CorruptOptions.Report and in QueryResult.Warnings.
+	CorruptReport
+)
+
+// CorruptDocument is a stored document that could not be unmarshalled.
+// QuarantineErr is set when moving the document to quarantine failed, see CorruptOptions.
+type CorruptDocument struct {
+	Collection    string
+	Key           []byte
+	Value         []byte
+	Err           error
+	QuarantineErr error
+}
+
+func (d CorruptDocument) Error() string {
+	return fmt.Sprintf("%v: %s %q: %v", ErrCorruptDocument, d.Collection, d.Key, d.Err)
+}
+
+func (d CorruptDocument) Unwrap() []error {
+	return []error{ErrCorruptDocument, d.Err}
+}
+
+// CorruptOptions configures how a collection handles corrupt documents, see Collection.HandleCorrupt.
+// Quarantine moves corrupt documents to the __quarantine:<collection> bucket once they are found,
+// whatever the policy, so later reads no longer see them. See Driver.Quarantined. Documents that could not be
+// quarantined are reported and listed as warnings whatever the policy, with their CorruptDocument.QuarantineErr set.
+type CorruptOptions struct {
+	Policy     CorruptPolicy
+	Report     func(doc CorruptDocument)
+	Quarantine bool
+}
+
+// HandleCorrupt sets how reads of the collection handle documents that do not unmarshal into its type.
+func (c *Collection[T]) HandleCorrupt(opts CorruptOptions) *Collection[T] {
+	c.corrupt = opts
+	return c
+}
+
+// decode unmarshals a stored document. It returns false if the document is corrupt and must be skipped,
+// the corrupt document is appended to found and an error is returned if the policy fails the read.
+func (c *Collection[T]) decode(key, value []byte, doc *T, found *[]CorruptDocument) (bool, error) {
+	err := c.unmarshal(value, doc)
+	if err == nil {
+		return true, nil
+	}
+	corrupt := CorruptDocument{
+		Collection: c.Name,
+		Key:        append([]byte{}, key...),
+		Value:      append([]byte{}, value...),
+		Err:        err,
+	}
+	*found = append(*found, corrupt)
+	if c.corrupt.Policy == CorruptFail {
+		return false, corrupt
+	}
+	return false, nil
+}
+
+// handleCorrupt reports and quarantines the corrupt documents found by a read, once its transaction is closed.
+// It returns the documents to list as warnings.
+func (c *Collection[T]) handleCorrupt(found []CorruptDocument) []CorruptDocument {
+	if len(found) == 0 {
+		return nil
+	}
+	if c.corrupt.Quarantine && !c.Driver.config.ReadOnly {
+		var codec Codec = jsonCodec{}
+		if c.codec != nil {
+			codec = c.codec
+		}
+		err := c.Driver.update(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(c.nameBytes)
+			if bucket == nil {
+				return nil
+			}
+			for _, doc := range found {
+				// The document may have been fixed since it was read
+				if string(bucket.Get(doc.Key)) != string(doc.Value) {
+					continue
+				}
+				if err := quarantine(tx, c.Name, &c.options, codec, bucket, doc.Key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			// documents that could not be quarantined are reported whatever the policy, so the failure is not lost
+			for i := range found {
+				found[i].QuarantineErr = err
+			}
+			if c.corrupt.Report != nil {
+				for _, doc := range found {
+					c.corrupt.Report(doc)
+				}
+			}
+			return found
+		}
+	}
+	if c.corrupt.Policy != CorruptReport {
+		return nil
+	}
+	if c.corrupt.Report != nil {
+		for _, doc := range found {
+			c.corrupt.Report(doc)
+		}
+	}
+	return found
+}
@@ -0,0 +1,151 @@
+package bingo_test
+
+import (
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCorruptDocuments(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testcorrupt.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testcorrupt.db")
+	}()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "corrupt")
+	if _, err := coll.InsertMany([]TestDocument{
+		{Document: bingo.Document{ID: "a"}, Name: "a"},
+		{Document: bingo.Document{ID: "c"}, Name: "c"},
+	}); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+	err = driver.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("corrupt")).Put([]byte("b"), []byte(`{"_id": "b", "Name": 42}`))
+	})
+	if err != nil {
+		t.Fatalf("Failed to store corrupt document: %v", err)
+	}
+	all := func(doc TestDocument) bool { return true }
+
+	// CorruptFail is the default, every read fails the same way
+	if _, err := coll.Find(all); err == nil || !bingo.IsErrCorruptDocument(err) {
+		t.Fatalf("Expected Find to fail, got %v", err)
+	}
+	if _, err := coll.FindByKey("b"); err == nil || !bingo.IsErrCorruptDocument(err) {
+		t.Fatalf("Expected FindByKey to fail, got %v", err)
+	}
+	if qr := coll.Query(bingo.Query[TestDocument]{KeysStr: []string{"a", "b", "c"}}); qr.Error == nil || !bingo.IsErrCorruptDocument(qr.Error) {
+		t.Fatalf("Expected a key query to fail, got %v", qr.Error)
+	}
+	if docs := coll.FindByKeys("a", "b", "c"); len(docs) != 2 {
+		t.Fatalf("Expected FindByKeys to skip the corrupt document, got %v", docs)
+	}
+
+	coll.HandleCorrupt(bingo.CorruptOptions{Policy: bingo.CorruptSkip})
+	if docs, err := coll.Find(all); err != nil || len(docs) != 2 {
+		t.Fatalf("Expected Find to skip the corrupt document, got %v %v", docs, err)
+	}
+	if err := coll.UpdateIter(func(doc *TestDocument) *TestDocument { return doc }); err != nil {
+		t.Fatalf("Expected UpdateIter to skip the corrupt document, got %v", err)
+	}
+
+	var reported []bingo.CorruptDocument
+	coll.HandleCorrupt(bingo.CorruptOptions{Policy: bingo.CorruptReport, Report: func(doc bingo.CorruptDocument) {
+		reported = append(reported, doc)
+	}})
+	qr := coll.Query(bingo.Query[TestDocument]{KeysStr: []string{"a", "b", "c"}})
+	if qr.Error != nil || qr.Count() != 2 || len(qr.Warnings) != 1 || string(qr.Warnings[0].Key) != "b" {
+		t.Fatalf("Expected 2 documents and a warning, got %d %v %v", qr.Count(), qr.Warnings, qr.Error)
+	}
+	if string(qr.Keys[1]) != "c" {
+		t.Fatalf("Expected keys to match the documents, got %q", qr.Keys)
+	}
+	if qr := coll.Query(bingo.Query[TestDocument]{Filter: all}); len(qr.Warnings) != 1 || len(reported) != 2 {
+		t.Fatalf("Expected the corrupt document to be reported, got %v %v", qr.Warnings, reported)
+	}
+
+	coll.HandleCorrupt(bingo.CorruptOptions{Policy: bingo.CorruptFail, Quarantine: true})
+	if _, err := coll.Find(all); err == nil {
+		t.Fatalf("Expected the read finding the corrupt document to fail")
+	}
+	if docs, err := coll.Find(all); err != nil || len(docs) != 2 {
+		t.Fatalf("Expected the corrupt document to be quarantined, got %v %v", docs, err)
+	}
+	if quarantined, _ := driver.Quarantined("corrupt"); len(quarantined) != 1 {
+		t.Fatalf("Expected 1 quarantined document, got %v", quarantined)
+	}
+}
+
+func TestQuarantineEntries(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testquarantine.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testquarantine.db")
+	}()
+
+	coll, err := bingo.OpenCollection[TestDocument](driver, "entries", bingo.WithIndex("Name"), bingo.WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	if _, err := coll.InsertMany([]TestDocument{
+		{Document: bingo.Document{ID: "moved"}, Name: "moved"},
+		{Document: bingo.Document{ID: "broken"}, Name: "broken"},
+		{Document: bingo.Document{ID: "read"}, Name: "read"},
+	}); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+	err = driver.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte("entries"))
+		if err := bucket.Put([]byte("moved"), []byte(`{"_id": "elsewhere", "Name": "moved"}`)); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("broken"), []byte(`{"Name": 12`)); err != nil {
+			return err
+		}
+		return bucket.Put([]byte("read"), []byte(`{"_id": "read", "Name": 42}`))
+	})
+	if err != nil {
+		t.Fatalf("Failed to corrupt documents: %v", err)
+	}
+
+	// reads quarantine the documents they cannot decode, the repair the one stored under the wrong key
+	coll.HandleCorrupt(bingo.CorruptOptions{Policy: bingo.CorruptSkip, Quarantine: true})
+	if docs, err := coll.Find(func(doc TestDocument) bool { return true }); err != nil || len(docs) != 1 {
+		t.Fatalf("Expected the corrupt documents to be skipped, got %v %v", docs, err)
+	}
+	if _, err := driver.Check(bingo.Repair); err != nil {
+		t.Fatalf("Failed to repair: %v", err)
+	}
+	if quarantined, _ := driver.Quarantined("entries"); len(quarantined) != 3 {
+		t.Fatalf("Expected 3 quarantined documents, got %v", quarantined)
+	}
+	err = driver.View(func(tx *bbolt.Tx) error {
+		for _, name := range []string{"__index:entries:Name", "__ttl:entries"} {
+			if n := tx.Bucket([]byte(name)).Stats().KeyN; n != 0 {
+				return fmt.Errorf("%s has %d entries left", name, n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the quarantined documents to leave no entries: %v", err)
+	}
+}
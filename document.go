@@ -12,3 +12,8 @@ func (d Document) WithId(id string) Document {
 	d.ID = id
 	return d
 }
+
+// SetKey sets the ID of the document, see KeySetter.
+func (d *Document) SetKey(key []byte) {
+	d.ID = string(key)
+}
@@ -1,6 +1,7 @@
 package bingo
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	jsoniter "github.com/json-iterator/go"
@@ -8,6 +9,8 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -34,11 +37,6 @@ type HasUnmarshal interface {
 var Marshaller HasMarshal = json
 var Unmarshaller HasUnmarshal = json
 
-var (
-	ErrDocumentNotFound = fmt.Errorf("document not found")
-	ErrDocumentExists   = fmt.Errorf("document already exists")
-)
-
 type WrappedBucket struct {
 	*bbolt.Bucket
 }
@@ -56,61 +54,229 @@ func (b *WrappedBucket) ReverseIter(fn func(k, v []byte) error) error {
 	return nil
 }
 
-// IsErrDocumentNotFound returns true if the error is an ErrDocumentNotFound error.
-func IsErrDocumentNotFound(err error) bool {
-	return strings.Contains(err.Error(), ErrDocumentNotFound.Error())
+// ReverseIterRange iterates over the keys k with start <= k < end in reverse order, a nil bound is open.
+func (b *WrappedBucket) ReverseIterRange(start, end []byte, fn func(k, v []byte) error) error {
+	if b.Tx().DB() == nil {
+		return fmt.Errorf("tx is closed")
+	}
+	c := b.Cursor()
+	var k, v []byte
+	if end == nil {
+		k, v = c.Last()
+	} else if k, _ = c.Seek(end); k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	for ; k != nil && (start == nil || bytes.Compare(k, start) >= 0); k, v = c.Prev() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// IsErrDocumentExists returns true if the error is an ErrDocumentExists error.
-func IsErrDocumentExists(err error) bool {
-	return strings.Contains(err.Error(), ErrDocumentExists.Error())
-}
+// Durability is a preset of the bbolt sync options.
+type Durability int
+
+const (
+	// DurabilitySafe fsyncs every commit, the freelist and file growth. It is the default.
+	DurabilitySafe Durability = iota
+	// DurabilityFast fsyncs every commit but not the freelist or file growth.
+	// Committed data survives a crash, opening the file after a crash is slower since the freelist is rebuilt.
+	DurabilityFast
+	// DurabilityEphemeral never fsyncs. A crash can lose recent commits or corrupt the file,
+	// use it for caches and tests that can rebuild their data.
+	DurabilityEphemeral
+)
 
 // DriverConfiguration represents the configuration for a database driver.
 // DeleteNoVerify specifies whether to verify a Collection DROP operation before executing it.
 // Filename specifies the filename of the database file.
+// ReadOnly opens the database file with a shared lock, every write fails with ErrReadOnly.
+// FileMode is the mode used to create the database file, defaults to 0600.
+// Timeout is how long NewDriver waits for the file lock held by another process, zero waits forever.
+// Durability selects a preset of sync options, NoSync, NoFreelistSync and NoGrowSync can further relax it.
+// FreelistType selects how bbolt tracks free pages, defaults to bbolt.FreelistArrayType. No preset changes it,
+// bbolt.FreelistMapType is faster for large files with many free pages.
+// InitialMmapSize is the initial size of the memory map, a large value avoids remapping which blocks write transactions.
+// MaxBatchSize and MaxBatchDelay tune batched writes, see BatchWrites. Zero keeps the bbolt defaults.
+// SchemaPolicy specifies what CollectionFrom does when a collection's stored schema differs from its Go type.
+// OnSchemaDrift, if set, is called with every detected schema drift.
+// BatchWrites routes single document writes of every collection through bbolt's DB.Batch, see Collection.Batched.
 type DriverConfiguration struct {
-	DeleteNoVerify bool
-	Filename       string
+	DeleteNoVerify  bool
+	Filename        string
+	ReadOnly        bool
+	FileMode        os.FileMode
+	Timeout         time.Duration
+	Durability      Durability
+	NoSync          bool
+	NoFreelistSync  bool
+	NoGrowSync      bool
+	FreelistType    bbolt.FreelistType
+	InitialMmapSize int
+	MaxBatchSize    int
+	MaxBatchDelay   time.Duration
+	SchemaPolicy    SchemaPolicy
+	OnSchemaDrift   func(diff *SchemaDiff)
+	BatchWrites     bool
+}
+
+// boltOptions returns the bbolt options of the configuration.
+func (config DriverConfiguration) boltOptions() *bbolt.Options {
+	options := &bbolt.Options{
+		ReadOnly:        config.ReadOnly,
+		Timeout:         config.Timeout,
+		NoSync:          config.NoSync,
+		NoFreelistSync:  config.NoFreelistSync,
+		NoGrowSync:      config.NoGrowSync,
+		InitialMmapSize: config.InitialMmapSize,
+		FreelistType:    config.FreelistType,
+	}
+	if options.FreelistType == "" {
+		options.FreelistType = bbolt.FreelistArrayType
+	}
+	switch config.Durability {
+	case DurabilityFast:
+		options.NoFreelistSync = true
+		options.NoGrowSync = true
+	case DurabilityEphemeral:
+		options.NoSync = true
+		options.NoFreelistSync = true
+		options.NoGrowSync = true
+	}
+	return options
 }
 
 // Driver represents a database driver that manages collections of documents.
+// Closed is set once the driver is closed, goroutines running alongside Close read it through IsClosed.
 type Driver struct {
 	db     *bbolt.DB
 	val    *validator.Validate
 	config *DriverConfiguration
 	Closed bool
+
+	// swap is held by every transaction and exclusively while the database file is closed or swapped, see CompactInPlace
+	swap   sync.RWMutex
+	mu     sync.RWMutex
+	opened map[string]openedCollection
+	// readers holds the collection last opened under each name, references are populated through it
+	readers map[string]any
+	// referrers caches the references to each collection, nil until read, see referrersOf
+	referrers map[string][]referrer
 }
 
 // NewDriver creates a new database driver with the specified configuration.
 func NewDriver(config DriverConfiguration) (*Driver, error) {
-	db, err := bbolt.Open(config.Filename, 0600, nil)
+	db, err := config.open()
 	if err != nil {
 		return nil, err
 	}
 	return &Driver{
-		db:     db,
-		val:    validator.New(validator.WithRequiredStructEnabled()),
-		config: &config,
+		db:      db,
+		val:     validator.New(validator.WithRequiredStructEnabled()),
+		config:  &config,
+		opened:  map[string]openedCollection{},
+		readers: map[string]any{},
 	}, nil
 }
 
+// open opens the database file of the configuration.
+func (config DriverConfiguration) open() (*bbolt.DB, error) {
+	mode := config.FileMode
+	if mode == 0 {
+		mode = 0600
+	}
+	db, err := bbolt.Open(config.Filename, mode, config.boltOptions())
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxBatchSize > 0 {
+		db.MaxBatchSize = config.MaxBatchSize
+	}
+	if config.MaxBatchDelay > 0 {
+		db.MaxBatchDelay = config.MaxBatchDelay
+	}
+	return db, nil
+}
+
 // Close closes the database file.
 func (d *Driver) Close() error {
+	d.swap.Lock()
+	defer d.swap.Unlock()
 	d.Closed = true
 	return d.db.Close()
 }
 
+// IsClosed reports whether the driver is closed, it is safe to call while another goroutine closes the driver.
+func (d *Driver) IsClosed() bool {
+	d.swap.RLock()
+	defer d.swap.RUnlock()
+	return d.Closed
+}
+
 // Update updates the database using the provided function.
 // This provides low level access to the underlying database.
 func (d *Driver) Update(update func(tx *bbolt.Tx) error) error {
-	return d.db.Update(update)
+	return d.update(update)
+}
+
+// update runs a write transaction, failing with ErrReadOnly on read-only drivers.
+func (d *Driver) update(f func(tx *bbolt.Tx) error) error {
+	d.swap.RLock()
+	defer d.swap.RUnlock()
+	if d.Closed {
+		return ErrDriverClosed
+	}
+	if d.config.ReadOnly {
+		return ErrReadOnly
+	}
+	return d.db.Update(f)
+}
+
+// batch runs f in a write transaction shared with concurrent callers, see bbolt.DB.Batch.
+func (d *Driver) batch(f func(tx *bbolt.Tx) error) error {
+	d.swap.RLock()
+	defer d.swap.RUnlock()
+	if d.Closed {
+		return ErrDriverClosed
+	}
+	if d.config.ReadOnly {
+		return ErrReadOnly
+	}
+	return d.db.Batch(f)
 }
 
 // View updates the database using the provided function.
 // This provides low level access to the underlying database.
 func (d *Driver) View(update func(tx *bbolt.Tx) error) error {
-	return d.db.View(update)
+	return d.view(update)
+}
+
+// view runs a read transaction, failing with ErrDriverClosed once the driver is closed.
+func (d *Driver) view(f func(tx *bbolt.Tx) error) error {
+	d.swap.RLock()
+	defer d.swap.RUnlock()
+	if d.Closed {
+		return ErrDriverClosed
+	}
+	return d.db.View(f)
+}
+
+// Begin starts a transaction on the underlying database.
+// This provides low level access to the underlying database, the transaction must be committed or rolled back by the caller.
+// Unlike the other transactions of the driver it does not block CompactInPlace, which must not run while it is open.
+func (d *Driver) Begin(writable bool) (*bbolt.Tx, error) {
+	d.swap.RLock()
+	defer d.swap.RUnlock()
+	if d.Closed {
+		return nil, ErrDriverClosed
+	}
+	if writable && d.config.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	return d.db.Begin(writable)
 }
 
 func (d *Driver) FieldsOf(name string) ([][]string, error) {
@@ -136,26 +302,63 @@ func (d *Driver) FieldsOf(name string) ([][]string, error) {
 // If the environment variable BINGO_ALLOW_DROP_<COLLECTION_NAME> is not set to true, an error is returned.
 // If Driver.config.DeleteNoVerify is set to true, the collection is dropped without any verification.
 func (c *Collection[DocumentType]) Drop() error {
-	if !c.Driver.config.DeleteNoVerify {
-		if r, _ := os.LookupEnv("BINGO_ALLOW_DROP_" + strings.ToUpper(c.Name)); r != "true" {
-			return fmt.Errorf("delete not allowed, set environment variable BINGO_ALLOW_DROP_%s=true to allow", strings.ToUpper(c.Name))
+	return c.Driver.dropCollection(c.Name)
+}
+
+func (d *Driver) dropCollection(name string) error {
+	if d.config.ReadOnly {
+		return ErrReadOnly
+	}
+	if !d.config.DeleteNoVerify {
+		if r, _ := os.LookupEnv("BINGO_ALLOW_DROP_" + strings.ToUpper(name)); r != "true" {
+			return fmt.Errorf("delete not allowed, set environment variable BINGO_ALLOW_DROP_%s=true to allow", strings.ToUpper(name))
 		}
 	}
-	_ = c.Driver.removeCollection(c.Name)
-	return c.Driver.db.Update(func(tx *bbolt.Tx) error {
-		return tx.DeleteBucket([]byte(c.Name))
+	_ = d.removeCollection(name)
+	d.mu.Lock()
+	delete(d.opened, name)
+	delete(d.readers, name)
+	d.referrers = nil
+	d.mu.Unlock()
+	return d.update(func(tx *bbolt.Tx) error {
+		// the indexes, reference indexes, expiry and soft deleted documents of the collection go with it
+		var related [][]byte
+		_ = tx.ForEach(func(bucket []byte, _ *bbolt.Bucket) error {
+			s := string(bucket)
+			if strings.HasPrefix(s, INDEX_COLLECTION_NAME+name+":") || strings.HasPrefix(s, REFS_COLLECTION_NAME+name+":") ||
+				s == TTL_COLLECTION_NAME+name || s == DELETED_COLLECTION_NAME+name {
+				related = append(related, append([]byte{}, bucket...))
+			}
+			return nil
+		})
+		for _, bucket := range related {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+		}
+		return tx.DeleteBucket([]byte(name))
 	})
 }
 
-// CollectionFrom creates a new collection with the specified driver and name.
-func CollectionFrom[T DocumentSpec](driver *Driver, name string) *Collection[T] {
+// CollectionFrom creates a new collection with the specified driver and name, see OpenCollection for the options.
+// It panics if the collection cannot be opened, use OpenCollection to handle the error instead.
+func CollectionFrom[T DocumentSpec](driver *Driver, name string, opts ...func(options *CollectionOptions)) *Collection[T] {
+	collection, err := OpenCollection[T](driver, name, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return collection
+}
+
+// OpenCollection creates a new collection with the specified driver and name, configured by opts, see CollectionOptions.
+// Opening a collection again with the same type and options is cheap and writes nothing.
+// An error wrapping ErrInvalidDocumentType, ErrInvalidOptions, ErrDriverClosed or ErrSchemaDrift is returned if the
+// collection cannot be opened.
+func OpenCollection[T DocumentSpec](driver *Driver, name string, opts ...func(options *CollectionOptions)) (*Collection[T], error) {
 	var o T
 	typ := reflect.TypeOf(o)
 	if typ == nil {
-		panic(fmt.Errorf("cannot use interface as type"))
-	}
-	if driver.Closed {
-		panic(fmt.Errorf("driver is closed"))
+		return nil, fmt.Errorf("%w: cannot use interface as type", ErrInvalidDocumentType)
 	}
 
 	if name == METADATA_COLLECTION_NAME {
@@ -163,40 +366,42 @@ func CollectionFrom[T DocumentSpec](driver *Driver, name string) *Collection[T]
 			Driver:    driver,
 			Name:      name,
 			nameBytes: []byte(name),
-		}
+			hooks:     &hookChain[T]{},
+		}, nil
 	}
-
-	//We should only write the fields to the metadata if the type is a struct
-	if typ.Kind() == reflect.Struct {
-		var typeFields []string
-		for i := 0; i < typ.NumField(); i++ {
-			var names []string
-			names = append(names, typ.Field(i).Name)
-			if jtag := typ.Field(i).Tag.Get("json"); jtag != "" {
-				names = append(names, strings.Split(jtag, ",")[0])
-			}
-			typeFields = append(typeFields, strings.Join(names, FIELD_ALIAS_SEPARATOR))
-		}
-		err := driver.WriteMetadata(FIELDS_COLLECTION_NAME+name, typeFields)
-		if err != nil {
-			panic(fmt.Sprintf("unable to write fields to metadata: %v", err))
-		}
+	if driver.IsClosed() {
+		return nil, ErrDriverClosed
 	}
 
-	if !reflect.ValueOf(o).FieldByName("ID").IsValid() {
-		panic(fmt.Errorf("document type %v does not have a valid ID field", typ))
+	if typ.Kind() != reflect.Struct || !hasSettableKey(typ) {
+		return nil, fmt.Errorf("%w: document type %v does not have a valid ID field", ErrInvalidDocumentType, typ)
 	}
 
-	err := driver.addCollection(name)
+	fields, err := fieldPlanOf(typ)
 	if err != nil {
-		panic(fmt.Sprintf("unable to add collection to metadata: %v", err))
+		return nil, err
 	}
-
-	return &Collection[T]{
+	if _, err := refFieldsOf(typ); err != nil {
+		return nil, err
+	}
+	options, codec, generator, err := driver.openCollection(name, typ, opts)
+	if err != nil {
+		return nil, err
+	}
+	collection := &Collection[T]{
 		Driver:    driver,
 		Name:      name,
 		nameBytes: []byte(name),
+		hooks:     &hookChain[T]{},
+		options:   *options,
+		codec:     codec,
+		generator: generator,
+		fields:    fields,
 	}
+	driver.mu.Lock()
+	driver.readers[name] = collection
+	driver.mu.Unlock()
+	return collection, nil
 }
 
 type Metadata struct {
@@ -212,9 +417,23 @@ func (m Metadata) Key() []byte {
 func (d *Driver) WriteMetadata(k string, v any) error {
 	metadata := CollectionFrom[Metadata](d, "__metadata")
 	_, err := metadata.Insert(Metadata{K: k, V: v}, Upsert)
+	d.forgetReferrers()
 	return err
 }
 
+// writeMetadataTx writes a metadata entry inside an already open transaction.
+func writeMetadataTx(tx *bbolt.Tx, k string, v any) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(METADATA_COLLECTION_NAME))
+	if err != nil {
+		return err
+	}
+	data, err := Marshaller.Marshal(Metadata{K: k, V: v})
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(k), data)
+}
+
 func (d *Driver) ReadMetadata(k string) (any, error) {
 	metadata := CollectionFrom[Metadata](d, "__metadata")
 	r, err := metadata.FindOne(func(doc Metadata) bool {
@@ -226,6 +445,14 @@ func (d *Driver) ReadMetadata(k string) (any, error) {
 	return r.V, nil
 }
 
+// collectionType returns the Go type a collection was last opened with.
+func (d *Driver) collectionType(name string) (reflect.Type, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	opened, ok := d.opened[name]
+	return opened.typ, ok
+}
+
 func (d *Driver) addCollection(name string) error {
 	return d.WriteMetadata(fmt.Sprintf("collection:%v", name), true)
 }
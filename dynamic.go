@@ -0,0 +1,758 @@
+package bingo
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"strconv"
+)
+
+// DynamicCollection is a schemaless collection of raw JSON documents.
+// It can operate on any collection without compiling in its document type, documents are handled as map[string]any.
+// Documents are encoded with the codec persisted for the collection by OpenCollection, and writes maintain its
+// indexes, references, expiry and soft deletes. Expired documents are never read.
+type DynamicCollection struct {
+	Driver    *Driver
+	Name      string
+	nameBytes []byte
+	// KeyPath is the dotted path of the document key, defaults to "_id" which is where bingo.Document stores its ID.
+	KeyPath string
+	// Schema, if set, is used to validate every document before it is written.
+	// It is loaded from the schema stored with Driver.ImportJSONSchema when the collection is opened.
+	Schema  JSONSchema
+	OnNewId func(count int, document map[string]any) []byte
+	hooks   *hookChain[map[string]any]
+	tx      *bbolt.Tx
+}
+
+// Dynamic opens a schemaless view of a collection. Operations on a view of a closed driver return ErrDriverClosed.
+func (d *Driver) Dynamic(name string) *DynamicCollection {
+	c := &DynamicCollection{
+		Driver:    d,
+		Name:      name,
+		nameBytes: []byte(name),
+		KeyPath:   "_id",
+		hooks:     &hookChain[map[string]any]{},
+	}
+	if schema, err := d.StoredJSONSchema(name); err == nil {
+		c.Schema = schema
+	}
+	return c
+}
+
+// WithKeyPath sets the dotted path the document key is read from and generated keys are written to.
+func (c *DynamicCollection) WithKeyPath(path string) *DynamicCollection {
+	c.KeyPath = path
+	return c
+}
+
+// InTx returns a copy of the collection bound to an open transaction, see Driver.Begin.
+// Every operation of the copy runs inside tx instead of its own transaction.
+func (c *DynamicCollection) InTx(tx *bbolt.Tx) *DynamicCollection {
+	bound := *c
+	bound.tx = tx
+	return &bound
+}
+
+func (c *DynamicCollection) update(f func(tx *bbolt.Tx) error) error {
+	if c.tx != nil {
+		if !c.tx.Writable() {
+			return bbolt.ErrTxNotWritable
+		}
+		return f(c.tx)
+	}
+	return c.Driver.update(f)
+}
+
+func (c *DynamicCollection) view(f func(tx *bbolt.Tx) error) error {
+	if c.tx != nil {
+		return f(c.tx)
+	}
+	return c.Driver.view(f)
+}
+
+// options returns the options persisted by OpenCollection, which apply to dynamic writes too.
+// Collections never opened with OpenCollection have none.
+func (c *DynamicCollection) options(tx *bbolt.Tx) (*CollectionOptions, Codec, error) {
+	options, err := storedOptionsTx(tx, c.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if options == nil {
+		options = &CollectionOptions{Codec: DEFAULT_CODEC}
+	}
+	codec, err := codecNamed(options.Codec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return options, codec, nil
+}
+
+// decode decodes a stored document with the codec of the collection.
+func (c *DynamicCollection) decode(codec Codec, key, value []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := codec.Unmarshal(value, &doc); err != nil {
+		return nil, fmt.Errorf("unable to decode document %s: %w", key, err)
+	}
+	return doc, nil
+}
+
+// stored decodes a stored document for reindexing, nil if it is missing or cannot be decoded.
+func (c *DynamicCollection) stored(options *CollectionOptions, codec Codec, value []byte) any {
+	if value == nil || (len(options.Indexes) == 0 && len(options.References) == 0) {
+		return nil
+	}
+	doc, err := decodeStored(codec, value)
+	if err != nil {
+		return nil
+	}
+	return doc
+}
+
+// put encodes and stores a document, maintaining the indexes, reference indexes and expiry of the collection.
+func (c *DynamicCollection) put(tx *bbolt.Tx, bucket *bbolt.Bucket, key []byte, doc map[string]any) error {
+	options, codec, err := c.options(tx)
+	if err != nil {
+		return err
+	}
+	data, err := codec.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := checkEntries(options.Indexes, options.References, key, data, doc); err != nil {
+		return err
+	}
+	if len(options.Indexes) > 0 || len(options.References) > 0 {
+		old := c.stored(options, codec, bucket.Get(key))
+		if err := reindex(tx, c.Name, options.Indexes, key, old, doc); err != nil {
+			return err
+		}
+		if err := reindexReferences(tx, c.Name, options.References, key, old, doc); err != nil {
+			return err
+		}
+	}
+	if err := bucket.Put(key, data); err != nil {
+		return err
+	}
+	return expireAfter(tx, c.Name, options.TTL, key)
+}
+
+// remove deletes a stored document after applying the policies of the references to it, see Collection.remove.
+func (c *DynamicCollection) remove(tx *bbolt.Tx, bucket *bbolt.Bucket, key []byte) error {
+	if bucket.Get(key) == nil {
+		return nil
+	}
+	options, codec, err := c.options(tx)
+	if err != nil {
+		return err
+	}
+	if err := c.Driver.enforceReferences(tx, c.Name, key, map[string]bool{}); err != nil {
+		return err
+	}
+	return removeStored(tx, c.Name, options, bucket, key, c.stored(options, codec, bucket.Get(key)), options.SoftDelete)
+}
+
+// Use adds a hook to the chain of its event and returns a handle to remove it, see Collection.Use.
+// Hooks are given a pointer to the document map.
+func (c *DynamicCollection) Use(hook Hook[map[string]any]) HookHandle {
+	return c.hooks.add(hook)
+}
+
+// RemoveHook removes a hook added with Use, it returns false if the hook was already removed.
+func (c *DynamicCollection) RemoveHook(handle HookHandle) bool {
+	return c.hooks.remove(func(hook registeredHook[map[string]any]) bool {
+		return hook.handle == handle
+	})
+}
+
+// Hooks returns the hooks of the collection in the order they run.
+func (c *DynamicCollection) Hooks() []Hook[map[string]any] {
+	var hooks []Hook[map[string]any]
+	for _, hook := range c.hooks.list() {
+		hooks = append(hooks, hook.Hook)
+	}
+	return hooks
+}
+
+// Around adds a hook wrapping every operation of the collection, see Hook.Around.
+func (c *DynamicCollection) Around(name string, f func(op Operation, next func() error) error) HookHandle {
+	return c.Use(Hook[map[string]any]{Name: name, Event: HookAround, Around: f})
+}
+
+// BeforeUpdate registers a function to be called before a document is updated in the collection.
+// It replaces the function registered by a previous call, use Use to add more hooks to the event.
+func (c *DynamicCollection) BeforeUpdate(f func(doc map[string]any) error) *DynamicCollection {
+	return c.setHook(HookBeforeUpdate, f)
+}
+
+// AfterUpdate registers a function to be called after a document is updated in the collection.
+func (c *DynamicCollection) AfterUpdate(f func(doc map[string]any) error) *DynamicCollection {
+	return c.setHook(HookAfterUpdate, f)
+}
+
+// BeforeDelete registers a function to be called before a document is deleted from the collection.
+func (c *DynamicCollection) BeforeDelete(f func(doc map[string]any) error) *DynamicCollection {
+	return c.setHook(HookBeforeDelete, f)
+}
+
+// AfterDelete registers a function to be called after a document is deleted from the collection.
+func (c *DynamicCollection) AfterDelete(f func(doc map[string]any) error) *DynamicCollection {
+	return c.setHook(HookAfterDelete, f)
+}
+
+// BeforeInsert registers a function to be called before a document is inserted into the collection.
+func (c *DynamicCollection) BeforeInsert(f func(doc map[string]any) error) *DynamicCollection {
+	return c.setHook(HookBeforeInsert, f)
+}
+
+// AfterInsert registers a function to be called after a document is inserted into the collection.
+func (c *DynamicCollection) AfterInsert(f func(doc map[string]any) error) *DynamicCollection {
+	return c.setHook(HookAfterInsert, f)
+}
+
+// AfterFind registers a function to be called on every document returned by FindByKey, Find and FindWithKeys.
+func (c *DynamicCollection) AfterFind(f func(doc map[string]any) error) *DynamicCollection {
+	return c.setHook(HookAfterFind, f)
+}
+
+// setHook replaces the hook registered by the setter of an event, see Collection.setHook. A nil f removes it.
+func (c *DynamicCollection) setHook(event HookEvent, f func(doc map[string]any) error) *DynamicCollection {
+	name := event.String()
+	c.hooks.remove(func(hook registeredHook[map[string]any]) bool {
+		return hook.Event == event && hook.Name == name
+	})
+	if f != nil {
+		c.hooks.add(Hook[map[string]any]{Name: name, Event: event, Func: func(doc *map[string]any) error {
+			return f(*doc)
+		}})
+	}
+	return c
+}
+
+// runHooks runs the hooks of an event on a document, stopping at the first error.
+func (c *DynamicCollection) runHooks(event HookEvent, doc map[string]any) error {
+	for _, hook := range c.hooks.list() {
+		if hook.Event != event || hook.Func == nil {
+			continue
+		}
+		if err := hook.Func(&doc); err != nil {
+			return &HookError{Hook: hook.Name, Collection: c.Name, Key: c.Key(doc), Err: err}
+		}
+	}
+	return nil
+}
+
+// around runs an operation through the Around hooks of the collection, the first hook to run being the outermost.
+func (c *DynamicCollection) around(method string, f func() error) error {
+	op := Operation{Collection: c.Name, Method: method}
+	next := f
+	hooks := c.hooks.list()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.Event != HookAround || hook.Around == nil {
+			continue
+		}
+		inner := next
+		next = func() error {
+			return hook.Around(op, inner)
+		}
+	}
+	return next()
+}
+
+// prepare runs the validate and before phases of a write on a document, see Phase.
+// Documents are validated against the schema of the collection, they have no tagged fields to fill.
+func (c *DynamicCollection) prepare(l lifecycle, doc map[string]any) error {
+	if l.stores {
+		if err := c.Validate(doc); err != nil {
+			return validationError(c.Name, c.Key(doc), err)
+		}
+		if err := c.runHooks(l.validate, doc); err != nil {
+			return err
+		}
+	}
+	return c.runHooks(l.before, doc)
+}
+
+// written runs the after-transaction phase of a write on a document it stored.
+func (c *DynamicCollection) written(l lifecycle, doc map[string]any) error {
+	return c.runHooks(l.afterTx, doc)
+}
+
+// committed runs the after-commit phase of a write on the documents it stored, joining their errors.
+func (c *DynamicCollection) committed(l lifecycle, docs ...map[string]any) error {
+	var errs []error
+	for _, doc := range docs {
+		if err := c.runHooks(l.after, doc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Key returns the key of a document, read from KeyPath. An empty key is returned if the document has none.
+func (c *DynamicCollection) Key(doc map[string]any) []byte {
+	v, ok := LookupPath(doc, c.KeyPath)
+	if !ok || v == nil {
+		return nil
+	}
+	switch key := v.(type) {
+	case string:
+		return []byte(key)
+	case float64:
+		return []byte(strconv.FormatFloat(key, 'f', -1, 64))
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// Validate validates a document against the collection schema, if any.
+func (c *DynamicCollection) Validate(doc map[string]any) error {
+	if c.Schema == nil {
+		return nil
+	}
+	return c.Schema.Validate(doc)
+}
+
+// Insert inserts a document into the collection, see Collection.Insert for the semantics of the options.
+func (c *DynamicCollection) Insert(doc map[string]any, opts ...func(options *InsertOptions)) ([]byte, error) {
+	var key []byte
+	err := c.around("Insert", func() error {
+		ids, err := c.inserts([]map[string]any{doc}, opts...)
+		if len(ids) > 0 {
+			key = ids[0]
+		}
+		return err
+	})
+	return key, err
+}
+
+// InsertRaw inserts a raw JSON document into the collection, raw can be a json.RawMessage.
+func (c *DynamicCollection) InsertRaw(raw []byte, opts ...func(options *InsertOptions)) ([]byte, error) {
+	var doc map[string]any
+	if err := Unmarshaller.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return c.Insert(doc, opts...)
+}
+
+// InsertMany inserts documents into the collection in a single transaction.
+// The key of a new document is generated before it is validated, so schemas can require it. The validate and before
+// phases of inserts therefore run inside the write transaction, like those of UpdateIter for typed collections.
+func (c *DynamicCollection) InsertMany(docs []map[string]any, opts ...func(options *InsertOptions)) ([][]byte, error) {
+	var keys [][]byte
+	err := c.around("InsertMany", func() (err error) {
+		keys, err = c.inserts(docs, opts...)
+		return err
+	})
+	return keys, err
+}
+
+func (c *DynamicCollection) inserts(docs []map[string]any, opts ...func(options *InsertOptions)) ([][]byte, error) {
+	opt := &InsertOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	var results [][]byte
+	var inserted []map[string]any
+	err := c.update(func(tx *bbolt.Tx) error {
+		results, inserted = nil, nil
+		bucket := tx.Bucket(c.nameBytes)
+		if bucket == nil {
+			var err error
+			bucket, err = tx.CreateBucket(c.nameBytes)
+			if err != nil {
+				return err
+			}
+			if err := writeMetadataTx(tx, fmt.Sprintf("collection:%v", c.Name), true); err != nil {
+				return err
+			}
+		}
+
+		for _, doc := range docs {
+			id, err := c.insertWithTx(tx, bucket, doc, opt)
+			if err != nil {
+				if !opt.IgnoreErrors {
+					return err
+				}
+				results = append(results, nil)
+				continue
+			}
+			// the document is stored, an AfterTx error rolls the whole transaction back even when errors are ignored
+			if err := c.written(insertLifecycle, doc); err != nil {
+				return err
+			}
+			results = append(results, id)
+			inserted = append(inserted, doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+
+	if err := c.committed(insertLifecycle, inserted...); err != nil && !opt.IgnoreErrors {
+		return results, err
+	}
+	return results, nil
+}
+
+// insertWithTx runs the validate, before and write phases of inserting a document.
+func (c *DynamicCollection) insertWithTx(tx *bbolt.Tx, bucket *bbolt.Bucket, doc map[string]any, opt *InsertOptions) ([]byte, error) {
+	if doc == nil {
+		return nil, fmt.Errorf("cannot insert a nil document")
+	}
+
+	// the key is set before validation, so schemas can require it
+	generated := len(c.Key(doc)) == 0
+	if generated {
+		key, err := c.newKey(tx, bucket, doc)
+		if err != nil {
+			return nil, err
+		}
+		SetPath(doc, c.KeyPath, string(key))
+	}
+	if err := c.prepare(insertLifecycle, doc); err != nil {
+		return nil, err
+	}
+
+	key := c.Key(doc)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("document has no key at %s", c.KeyPath)
+	}
+	if (generated || !opt.Upsert) && bucket.Get(key) != nil {
+		return nil, documentExists(c.Name, key)
+	}
+	if err := c.put(tx, bucket, key, doc); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// newKey returns the key of a new document, from OnNewId or the key generator persisted for the collection.
+func (c *DynamicCollection) newKey(tx *bbolt.Tx, bucket *bbolt.Bucket, doc map[string]any) ([]byte, error) {
+	if c.OnNewId != nil {
+		n, err := nextSequence(bucket)
+		if err != nil {
+			return nil, err
+		}
+		return c.OnNewId(int(n-1), doc), nil
+	}
+	options, _, err := c.options(tx)
+	if err != nil {
+		return nil, err
+	}
+	name := options.KeyGenerator
+	if name == "" {
+		name = DEFAULT_KEY_GENERATOR
+	}
+	generator, err := keyGeneratorNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	return generator(bucket)
+}
+
+// FindByKey retrieves a document from the collection by its key. If the document is not found, an error is returned.
+func (c *DynamicCollection) FindByKey(key string) (map[string]any, error) {
+	var doc map[string]any
+	err := c.around("FindByKey", func() error {
+		return c.view(func(tx *bbolt.Tx) error {
+			value, codec, err := c.get(tx, []byte(key))
+			if err != nil {
+				return err
+			}
+			if doc, err = c.decode(codec, []byte(key), value); err != nil {
+				return err
+			}
+			return c.runHooks(HookAfterFind, doc)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// FindRawByKey retrieves the JSON of a document by its key. If the document is not found, an error is returned.
+// Documents of collections using another codec are converted to JSON.
+func (c *DynamicCollection) FindRawByKey(key string) ([]byte, error) {
+	var raw []byte
+	err := c.view(func(tx *bbolt.Tx) error {
+		value, codec, err := c.get(tx, []byte(key))
+		if err != nil {
+			return err
+		}
+		if _, ok := codec.(jsonCodec); ok {
+			raw = append(raw, value...)
+			return nil
+		}
+		doc, err := c.decode(codec, []byte(key), value)
+		if err != nil {
+			return err
+		}
+		raw, err = Marshaller.Marshal(doc)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// get returns the stored value of a document along with the codec of the collection, failing if it is missing or expired.
+func (c *DynamicCollection) get(tx *bbolt.Tx, key []byte) ([]byte, Codec, error) {
+	bucket := tx.Bucket(c.nameBytes)
+	if bucket == nil {
+		return nil, nil, collectionNotFound(c.Name)
+	}
+	value := bucket.Get(key)
+	if value == nil || expiredIn(tx, c.Name)(key) {
+		return nil, nil, documentNotFound(c.Name, key)
+	}
+	_, codec, err := c.options(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, codec, nil
+}
+
+// Iter calls f for every document of the collection in reverse key order. Returning an error from f stops the iteration.
+func (c *DynamicCollection) Iter(f func(key []byte, doc map[string]any) error) error {
+	err := c.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(c.nameBytes)
+		if bucket == nil {
+			return collectionNotFound(c.Name)
+		}
+		_, codec, err := c.options(tx)
+		if err != nil {
+			return err
+		}
+		expired := expiredIn(tx, c.Name)
+		wbucket := &WrappedBucket{bucket}
+		return wbucket.ReverseIter(func(k, v []byte) error {
+			if expired(k) {
+				return nil
+			}
+			doc, err := c.decode(codec, k, v)
+			if err != nil {
+				return err
+			}
+			return f(k, doc)
+		})
+	})
+	if errors.Is(err, stoperr) {
+		return nil
+	}
+	return err
+}
+
+// FindWithKeys returns the documents matching the filter along with their keys.
+func (c *DynamicCollection) FindWithKeys(filter Filter, opts ...IterOptsFunc) ([]map[string]any, [][]byte, error) {
+	var documents []map[string]any
+	var keys [][]byte
+	err := c.around("Find", func() (err error) {
+		documents, keys, err = c.findWithKeys(filter, opts...)
+		return err
+	})
+	return documents, keys, err
+}
+
+func (c *DynamicCollection) findWithKeys(filter Filter, opts ...IterOptsFunc) ([]map[string]any, [][]byte, error) {
+	options := iterOpts{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var documents []map[string]any
+	var keys [][]byte
+	last := 0
+	err := c.Iter(func(key []byte, doc map[string]any) error {
+		last += 1
+		if last <= options.Skip {
+			return nil
+		}
+		if filter.Match(doc) {
+			if err := c.runHooks(HookAfterFind, doc); err != nil {
+				return err
+			}
+			documents = append(documents, doc)
+			keys = append(keys, append([]byte{}, key...))
+			if options.Count > 0 && len(documents) >= options.Count {
+				return stoperr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(documents) == 0 {
+		return nil, nil, documentNotFound(c.Name, nil)
+	}
+	return documents, keys, nil
+}
+
+// Find returns the documents matching the filter.
+func (c *DynamicCollection) Find(filter Filter, opts ...IterOptsFunc) ([]map[string]any, error) {
+	r, _, err := c.FindWithKeys(filter, opts...)
+	return r, err
+}
+
+// Count returns the number of documents matching the filter.
+func (c *DynamicCollection) Count(filter Filter) (int, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	count := 0
+	err := c.around("Count", func() error {
+		return c.Iter(func(_ []byte, doc map[string]any) error {
+			if filter.Match(doc) {
+				count += 1
+			}
+			return nil
+		})
+	})
+	return count, err
+}
+
+// UpdateOne updates a document in the collection.
+func (c *DynamicCollection) UpdateOne(doc map[string]any) error {
+	return c.around("UpdateOne", func() error {
+		key := c.Key(doc)
+		if len(key) == 0 {
+			return fmt.Errorf("document has no key at %s", c.KeyPath)
+		}
+		return c.updateKey(key, doc)
+	})
+}
+
+// UpdateByKey replaces the document stored under the given key.
+func (c *DynamicCollection) UpdateByKey(key string, doc map[string]any) error {
+	return c.around("UpdateByKey", func() error {
+		return c.updateKey([]byte(key), doc)
+	})
+}
+
+// UpdateByKeyFunc reads the document stored under a key, changes it with f and writes it back in a single write
+// transaction, see Collection.UpdateByKeyFunc. f must not change the key of the document.
+func (c *DynamicCollection) UpdateByKeyFunc(key string, f func(doc map[string]any) error) error {
+	return c.around("UpdateByKeyFunc", func() error {
+		var doc map[string]any
+		err := c.update(func(tx *bbolt.Tx) error {
+			value, codec, err := c.get(tx, []byte(key))
+			if err != nil {
+				return err
+			}
+			if doc, err = c.decode(codec, []byte(key), value); err != nil {
+				return err
+			}
+			if err := f(doc); err != nil {
+				return err
+			}
+			if k := c.Key(doc); string(k) != key {
+				return fmt.Errorf("the key of document %s cannot be changed to %s", key, k)
+			}
+			if err := c.prepare(updateLifecycle, doc); err != nil {
+				return err
+			}
+			if err := c.put(tx, tx.Bucket(c.nameBytes), []byte(key), doc); err != nil {
+				return err
+			}
+			return c.written(updateLifecycle, doc)
+		})
+		if err != nil {
+			return err
+		}
+		return c.committed(updateLifecycle, doc)
+	})
+}
+
+func (c *DynamicCollection) updateKey(key []byte, doc map[string]any) error {
+	if err := c.prepare(updateLifecycle, doc); err != nil {
+		return err
+	}
+	err := c.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(c.nameBytes)
+		if bucket == nil {
+			return collectionNotFound(c.Name)
+		}
+		if err := c.put(tx, bucket, key, doc); err != nil {
+			return err
+		}
+		return c.written(updateLifecycle, doc)
+	})
+	if err != nil {
+		return err
+	}
+	return c.committed(updateLifecycle, doc)
+}
+
+// DeleteOne deletes a document from the collection.
+func (c *DynamicCollection) DeleteOne(doc map[string]any) error {
+	return c.around("DeleteOne", func() error {
+		key := c.Key(doc)
+		if len(key) == 0 {
+			return fmt.Errorf("document has no key at %s", c.KeyPath)
+		}
+		if err := c.prepare(deleteLifecycle, doc); err != nil {
+			return err
+		}
+		err := c.update(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(c.nameBytes)
+			if bucket == nil {
+				return collectionNotFound(c.Name)
+			}
+			if err := c.remove(tx, bucket, key); err != nil {
+				return err
+			}
+			return c.written(deleteLifecycle, doc)
+		})
+		if err != nil {
+			return err
+		}
+		return c.committed(deleteLifecycle, doc)
+	})
+}
+
+// DeleteByKey deletes the document stored under the given key from the collection, an error wrapping
+// ErrDocumentNotFound is returned if there is none. The document is read and its hooks run inside the write
+// transaction, see Collection.DeleteByKey. Documents that cannot be decoded are deleted too, their hooks are given
+// a document holding only the key.
+func (c *DynamicCollection) DeleteByKey(key string) error {
+	return c.around("DeleteByKey", func() error {
+		var doc map[string]any
+		err := c.update(func(tx *bbolt.Tx) error {
+			value, codec, err := c.get(tx, []byte(key))
+			if err != nil {
+				return err
+			}
+			if doc, err = c.decode(codec, []byte(key), value); err != nil {
+				doc = map[string]any{}
+				SetPath(doc, c.KeyPath, key)
+			}
+			if err := c.prepare(deleteLifecycle, doc); err != nil {
+				return err
+			}
+			if err := c.remove(tx, tx.Bucket(c.nameBytes), []byte(key)); err != nil {
+				return err
+			}
+			return c.written(deleteLifecycle, doc)
+		})
+		if err != nil {
+			return err
+		}
+		return c.committed(deleteLifecycle, doc)
+	})
+}
+
+// Drop drops the collection from the database, see Collection.Drop.
+func (c *DynamicCollection) Drop() error {
+	if c.tx != nil {
+		return fmt.Errorf("cannot drop %s inside a transaction", c.Name)
+	}
+	return c.Driver.dropCollection(c.Name)
+}
@@ -0,0 +1,232 @@
+package bingo_test
+
+import (
+	"errors"
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDynamicCollection(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testdynamic.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+
+	defer func() {
+		driver.Close()
+		os.Remove("testdynamic.db")
+	}()
+
+	typed := bingo.CollectionFrom[TestDocument](driver, "fruits")
+	_, err = typed.InsertMany([]TestDocument{{Name: "Apple"}, {Name: "Banana"}, {Name: "Cherry"}})
+	if err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+
+	fruits := driver.Dynamic("fruits")
+	inserted := 0
+	fruits.BeforeInsert(func(doc map[string]any) error {
+		inserted += 1
+		return nil
+	})
+
+	key, err := fruits.InsertRaw([]byte(`{"Name": "Durian", "tags": ["smelly", "spiky"], "stock": {"count": 3}}`))
+	if err != nil {
+		t.Fatalf("Failed to insert raw document: %v", err)
+	}
+	if inserted != 1 {
+		t.Fatalf("Expected the insert hook to run")
+	}
+
+	doc, err := fruits.FindByKey(string(key))
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if doc["_id"] != string(key) {
+		t.Fatalf("Expected generated key to be written to the document: %v", doc)
+	}
+
+	found, err := typed.FindByKey(string(key))
+	if err != nil || found.Name != "Durian" {
+		t.Fatalf("Expected dynamic document to be readable as a typed document: %v (%v)", found, err)
+	}
+
+	t.Run("should query with the filter language", func(t *testing.T) {
+		filter, err := bingo.ParseFilter([]byte(`{"$or": [{"Name": {"$regex": "^B"}}, {"stock.count": {"$gte": 3}}]}`))
+		if err != nil {
+			t.Fatalf("Failed to parse filter: %v", err)
+		}
+		result, err := fruits.Find(filter)
+		if err != nil {
+			t.Fatalf("Failed to find documents: %v", err)
+		}
+		if len(result) != 2 {
+			t.Fatalf("Unexpected number of documents: %v", result)
+		}
+
+		count, err := fruits.Count(bingo.Filter{"tags": "spiky"})
+		if err != nil || count != 1 {
+			t.Fatalf("Unexpected count %d (%v)", count, err)
+		}
+
+		typedResult, err := typed.Find(bingo.Where[TestDocument](bingo.Filter{"Name": map[string]any{"$in": []string{"Apple", "Cherry"}}}))
+		if err != nil || len(typedResult) != 2 {
+			t.Fatalf("Unexpected typed result %v (%v)", typedResult, err)
+		}
+	})
+
+	t.Run("should reject unknown operators", func(t *testing.T) {
+		if _, err := bingo.ParseFilter([]byte(`{"Name": {"$like": "A%"}}`)); err == nil {
+			t.Fatalf("Expected an invalid filter error")
+		}
+	})
+
+	t.Run("should use a custom key path", func(t *testing.T) {
+		users := driver.Dynamic("users").WithKeyPath("profile.email")
+		key, err := users.Insert(map[string]any{"profile": map[string]any{"email": "john@example.com"}})
+		if err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+		if string(key) != "john@example.com" {
+			t.Fatalf("Unexpected key %s", key)
+		}
+		_, err = users.Insert(map[string]any{"profile": map[string]any{"email": "john@example.com"}})
+		if err == nil || !bingo.IsErrDocumentExists(err) {
+			t.Fatalf("Expected a document exists error, got: %v", err)
+		}
+		if err := users.DeleteByKey("john@example.com"); err != nil {
+			t.Fatalf("Failed to delete document: %v", err)
+		}
+	})
+
+	t.Run("should generate keys before validating", func(t *testing.T) {
+		if _, err := bingo.OpenCollection[TestDocument](driver, "counters", bingo.WithKeyGenerator("autoincrement")); err != nil {
+			t.Fatalf("Failed to open collection: %v", err)
+		}
+		counters := driver.Dynamic("counters")
+		counters.Schema = bingo.JSONSchema{"type": "object", "required": []any{"_id"}}
+		for _, want := range []string{"1", "2"} {
+			key, err := counters.Insert(map[string]any{"Name": "counter"})
+			if err != nil || string(key) != want {
+				t.Fatalf("Expected the persisted generator to key the document %s, got %s %v", want, key, err)
+			}
+		}
+	})
+
+	t.Run("should use the persisted codec and hide expired documents", func(t *testing.T) {
+		bingo.RegisterCodec("prefixed", prefixCodec{})
+		sessions, err := bingo.OpenCollection[TestDocument](driver, "sessions", bingo.WithCodec("prefixed"), bingo.WithTTL(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to open collection: %v", err)
+		}
+		dynamic := driver.Dynamic("sessions")
+		if _, err := dynamic.Insert(map[string]any{"_id": "s1", "Name": "session"}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+		err = driver.View(func(tx *bbolt.Tx) error {
+			if v := tx.Bucket([]byte("sessions")).Get([]byte("s1")); !strings.HasPrefix(string(v), "prefixed:") {
+				t.Fatalf("Expected the document to be stored with the codec, got %s", v)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to read the bucket: %v", err)
+		}
+		if doc, err := sessions.FindByKey("s1"); err != nil || doc.Name != "session" {
+			t.Fatalf("Expected the typed collection to decode a dynamic write, got %+v %v", doc, err)
+		}
+		if raw, err := dynamic.FindRawByKey("s1"); err != nil || !strings.HasPrefix(string(raw), "{") {
+			t.Fatalf("Expected the document as JSON, got %s %v", raw, err)
+		}
+
+		if _, err := bingo.OpenCollection[TestDocument](driver, "sessions", bingo.WithCodec("prefixed"), bingo.WithTTL(time.Nanosecond)); err != nil {
+			t.Fatalf("Failed to reopen collection: %v", err)
+		}
+		if _, err := dynamic.Insert(map[string]any{"_id": "s2", "Name": "expired"}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+		if _, err := dynamic.FindByKey("s2"); !bingo.IsErrDocumentNotFound(err) {
+			t.Fatalf("Expected an expired document to be hidden, got %v", err)
+		}
+		if _, err := dynamic.FindRawByKey("s2"); !bingo.IsErrDocumentNotFound(err) {
+			t.Fatalf("Expected an expired raw document to be hidden, got %v", err)
+		}
+		if n, err := dynamic.Count(bingo.Filter{}); err != nil || n != 1 {
+			t.Fatalf("Expected only the live document to be counted, got %d %v", n, err)
+		}
+	})
+
+	t.Run("should delete documents that cannot be decoded", func(t *testing.T) {
+		err := driver.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket([]byte("fruits")).Put([]byte("broken"), []byte("{not json"))
+		})
+		if err != nil {
+			t.Fatalf("Failed to store a broken document: %v", err)
+		}
+		if err := fruits.DeleteByKey("broken"); err != nil {
+			t.Fatalf("Failed to delete a broken document: %v", err)
+		}
+		if err := fruits.DeleteByKey("broken"); !bingo.IsErrDocumentNotFound(err) {
+			t.Fatalf("Expected the broken document to be gone, got %v", err)
+		}
+	})
+
+	t.Run("should run hooks through the lifecycle", func(t *testing.T) {
+		notes := driver.Dynamic("notes")
+		var calls []string
+		record := func(name string) func(*map[string]any) error {
+			return func(*map[string]any) error {
+				calls = append(calls, name)
+				return nil
+			}
+		}
+		notes.Use(bingo.Hook[map[string]any]{Name: "late", Event: bingo.HookBeforeInsert, Priority: 10, Func: record("late")})
+		notes.Use(bingo.Hook[map[string]any]{Name: "early", Event: bingo.HookBeforeInsert, Priority: -10, Func: record("early")})
+		notes.Use(bingo.Hook[map[string]any]{Name: "validate", Event: bingo.HookValidateInsert, Func: record("validate")})
+		notes.Use(bingo.Hook[map[string]any]{Name: "after", Event: bingo.HookAfterInsert, Func: record("after")})
+		var ops []string
+		notes.Around("trace", func(op bingo.Operation, next func() error) error {
+			ops = append(ops, op.Method)
+			return next()
+		})
+		if _, err := notes.Insert(map[string]any{"_id": "a"}); err != nil {
+			t.Fatalf("Failed to insert note: %v", err)
+		}
+		if got := strings.Join(calls, ","); got != "validate,early,late,after" {
+			t.Fatalf("Expected the hooks to run by phase and priority, got %s", got)
+		}
+
+		errRejected := errors.New("rejected")
+		tx := notes.Use(bingo.Hook[map[string]any]{Name: "reject", Event: bingo.HookAfterInsertTx, Func: func(doc *map[string]any) error {
+			if (*doc)["_id"] == "c" {
+				return errRejected
+			}
+			return nil
+		}})
+		_, err := notes.InsertMany([]map[string]any{{"_id": "b"}, {"_id": "c"}}, bingo.IgnoreErrors)
+		var hookErr *bingo.HookError
+		if !errors.Is(err, errRejected) || !errors.As(err, &hookErr) || hookErr.Hook != "reject" {
+			t.Fatalf("Expected the AfterTx hook to fail the insert, got %v", err)
+		}
+		if _, err := notes.FindByKey("b"); !bingo.IsErrDocumentNotFound(err) {
+			t.Fatalf("Expected the failed insert to be rolled back, got %v", err)
+		}
+		notes.RemoveHook(tx)
+
+		if err := notes.DeleteByKey("a"); err != nil {
+			t.Fatalf("Failed to delete note: %v", err)
+		}
+		if got := strings.Join(ops, ","); got != "Insert,InsertMany,FindByKey,DeleteByKey" {
+			t.Fatalf("Expected the operations to be wrapped, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package bingo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrDocumentNotFound    = fmt.Errorf("document not found")
+	ErrDocumentExists      = fmt.Errorf("document already exists")
+	ErrCollectionNotFound  = fmt.Errorf("collection not found")
+	ErrDriverClosed        = fmt.Errorf("driver is closed")
+	ErrReadOnly            = fmt.Errorf("database is read-only")
+	ErrValidation          = fmt.Errorf("validation failed")
+	ErrInvalidDocumentType = fmt.Errorf("invalid document type")
+	ErrInvalidOptions      = fmt.Errorf("invalid collection options")
+	ErrNotIndexed          = fmt.Errorf("field is not indexed")
+	ErrReferenced          = fmt.Errorf("document is referenced")
+)
+
+// IsErrDocumentNotFound returns true if the error is an ErrDocumentNotFound error.
+func IsErrDocumentNotFound(err error) bool {
+	return errors.Is(err, ErrDocumentNotFound)
+}
+
+// IsErrDocumentExists returns true if the error is an ErrDocumentExists error.
+func IsErrDocumentExists(err error) bool {
+	return errors.Is(err, ErrDocumentExists)
+}
+
+// IsErrCollectionNotFound returns true if the error is an ErrCollectionNotFound error.
+func IsErrCollectionNotFound(err error) bool {
+	return errors.Is(err, ErrCollectionNotFound)
+}
+
+// IsErrDriverClosed returns true if the error is an ErrDriverClosed error.
+func IsErrDriverClosed(err error) bool {
+	return errors.Is(err, ErrDriverClosed)
+}
+
+// IsErrReadOnly returns true if the error is an ErrReadOnly error.
+func IsErrReadOnly(err error) bool {
+	return errors.Is(err, ErrReadOnly)
+}
+
+// IsErrValidation returns true if the error is a *ValidationError.
+func IsErrValidation(err error) bool {
+	return errors.Is(err, ErrValidation)
+}
+
+// IsErrReferenced returns true if the error is a *ReferenceError.
+func IsErrReferenced(err error) bool {
+	return errors.Is(err, ErrReferenced)
+}
+
+// collectionNotFound returns the error of a collection without a bucket.
+func collectionNotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+}
+
+// DocumentError is an error concerning a single document, it wraps ErrDocumentNotFound or ErrDocumentExists.
+type DocumentError struct {
+	Collection string
+	Key        []byte
+	Err        error
+}
+
+func (e *DocumentError) Error() string {
+	return fmt.Sprintf("%v: %s/%s", e.Err, e.Collection, e.Key)
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+func documentNotFound(collection string, key []byte) error {
+	return &DocumentError{Collection: collection, Key: key, Err: ErrDocumentNotFound}
+}
+
+func documentExists(collection string, key []byte) error {
+	return &DocumentError{Collection: collection, Key: key, Err: ErrDocumentExists}
+}
+
+// HookError is an error returned by a hook, Hook is the name of the hook such as "BeforeInsert".
+type HookError struct {
+	Hook       string
+	Collection string
+	Key        []byte
+	Err        error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook of %s failed for %q: %v", e.Hook, e.Collection, e.Key, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// ReferenceError is returned when deleting a document referenced with the RefRestrict policy, it matches ErrReferenced
+// with errors.Is. Keys are the documents of Referrer referencing it at Path.
+type ReferenceError struct {
+	Collection string
+	Key        []byte
+	Referrer   string
+	Path       string
+	Keys       [][]byte
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("%v: %s/%s by %d documents of %s at %s", ErrReferenced, e.Collection, e.Key, len(e.Keys), e.Referrer, e.Path)
+}
+
+func (e *ReferenceError) Unwrap() error {
+	return ErrReferenced
+}
+
+// FieldError describes a field failing validation. Tag is the failed validation, such as "required" or "min".
+// Message describes the failure in plain words, such as "Name is required".
+// Validate methods of documents may return a FieldError to report the field they reject, see Validatable.
+type FieldError struct {
+	Field     string
+	Namespace string
+	Tag       string
+	Param     string
+	Value     any
+	Message   string
+}
+
+func (f FieldError) Error() string {
+	if f.Message != "" {
+		return f.Message
+	}
+	return fmt.Sprintf("%s failed %s", f.Field, f.Tag)
+}
+
+// ValidationError is returned when a document fails validation, it matches ErrValidation with errors.Is.
+// The validator.ValidationErrors it was built from can still be retrieved with errors.As.
+type ValidationError struct {
+	Collection string
+	Key        []byte
+	Fields     []FieldError
+	Err        error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("%v for %s/%s: %v", ErrValidation, e.Collection, e.Key, e.Err)
+	}
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Error()
+	}
+	return fmt.Sprintf("%v for %s/%s: %s", ErrValidation, e.Collection, e.Key, strings.Join(problems, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// validationError converts an error of the validator or of a Validate method into a *ValidationError.
+func validationError(collection string, key []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ValidationError{Collection: collection, Key: key, Fields: fieldErrors(err), Err: err}
+}
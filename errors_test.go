@@ -0,0 +1,108 @@
+package bingo_test
+
+import (
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/nokusukun/bingo"
+	"os"
+	"testing"
+)
+
+type NoID struct {
+	Name string
+}
+
+func (n NoID) Key() []byte {
+	return []byte(n.Name)
+}
+
+func TestTypedErrors(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testerrors.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testerrors.db")
+	}()
+
+	coll, err := bingo.OpenCollection[TestDocument](driver, "errors")
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+
+	// nothing was written yet, the bucket does not exist
+	if _, err := coll.Find(func(doc TestDocument) bool { return true }); !errors.Is(err, bingo.ErrCollectionNotFound) {
+		t.Fatalf("Expected ErrCollectionNotFound, got %v", err)
+	}
+
+	if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "a"}, Name: "a"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	var derr *bingo.DocumentError
+	_, err = coll.FindByKey("b")
+	if !errors.Is(err, bingo.ErrDocumentNotFound) || !errors.As(err, &derr) || string(derr.Key) != "b" || derr.Collection != "errors" {
+		t.Fatalf("Expected a DocumentError for errors/b, got %v", err)
+	}
+	_, err = coll.Insert(TestDocument{Document: bingo.Document{ID: "a"}, Name: "a"})
+	if !errors.Is(err, bingo.ErrDocumentExists) || !errors.As(err, &derr) || string(derr.Key) != "a" {
+		t.Fatalf("Expected a DocumentError for errors/a, got %v", err)
+	}
+
+	// validation errors carry the failing fields and still unwrap to the validator errors
+	var verr *bingo.ValidationError
+	_, err = coll.Insert(TestDocument{Document: bingo.Document{ID: "c"}})
+	if !errors.Is(err, bingo.ErrValidation) || !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	if len(verr.Fields) != 1 || verr.Fields[0].Field != "Name" || verr.Fields[0].Tag != "required" {
+		t.Fatalf("Expected Name to fail required, got %+v", verr.Fields)
+	}
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		t.Fatalf("Expected the validator errors to be wrapped, got %v", err)
+	}
+
+	// hook errors name the hook and wrap the returned error
+	errRejected := errors.New("rejected")
+	coll.BeforeInsert(func(doc *TestDocument) error {
+		return errRejected
+	})
+	var herr *bingo.HookError
+	_, err = coll.Insert(TestDocument{Document: bingo.Document{ID: "d"}, Name: "d"})
+	if !errors.Is(err, errRejected) || !errors.As(err, &herr) || herr.Hook != "BeforeInsert" || string(herr.Key) != "d" {
+		t.Fatalf("Expected a BeforeInsert HookError, got %v", err)
+	}
+	coll.BeforeInsert(nil)
+
+	dynamic := driver.Dynamic("errors")
+	dynamic.BeforeDelete(func(doc map[string]any) error {
+		return errRejected
+	})
+	if err := dynamic.DeleteByKey("a"); !errors.As(err, &herr) || herr.Hook != "BeforeDelete" || !errors.Is(err, errRejected) {
+		t.Fatalf("Expected a BeforeDelete HookError, got %v", err)
+	}
+	if _, err := dynamic.FindByKey("b"); !errors.Is(err, bingo.ErrDocumentNotFound) {
+		t.Fatalf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	if _, err := bingo.OpenCollection[NoID](driver, "noid"); !errors.Is(err, bingo.ErrInvalidDocumentType) {
+		t.Fatalf("Expected ErrInvalidDocumentType, got %v", err)
+	}
+
+	driver.Close()
+	if _, err := coll.FindByKey("a"); !errors.Is(err, bingo.ErrDriverClosed) {
+		t.Fatalf("Expected ErrDriverClosed, got %v", err)
+	}
+	if _, err := bingo.OpenCollection[TestDocument](driver, "errors"); !errors.Is(err, bingo.ErrDriverClosed) {
+		t.Fatalf("Expected ErrDriverClosed from OpenCollection, got %v", err)
+	}
+	if _, err := driver.Dynamic("errors").FindByKey("a"); !errors.Is(err, bingo.ErrDriverClosed) {
+		t.Fatalf("Expected ErrDriverClosed from a dynamic collection, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package bingo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"time"
+)
+
+const (
+	TTL_COLLECTION_NAME     = "__ttl:"
+	DELETED_COLLECTION_NAME = "__deleted:"
+)
+
+// expireAfter records when a document written now expires, see WithTTL.
+// The expiry of documents of collections without TTL is cleared, so they no longer expire.
+func (c *Collection[T]) expireAfter(tx *bbolt.Tx, key []byte) error {
+	return expireAfter(tx, c.Name, c.options.TTL, key)
+}
+
+// expireAfter records when a document of a collection written now expires, a ttl of zero clears its expiry.
+func expireAfter(tx *bbolt.Tx, collection string, ttl time.Duration, key []byte) error {
+	name := []byte(TTL_COLLECTION_NAME + collection)
+	if ttl <= 0 {
+		if expiries := tx.Bucket(name); expiries != nil {
+			return expiries.Delete(key)
+		}
+		return nil
+	}
+	expiries, err := tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return err
+	}
+	at := make([]byte, 8)
+	binary.BigEndian.PutUint64(at, uint64(time.Now().Add(ttl).UnixNano()))
+	return expiries.Put(key, at)
+}
+
+// expired returns a function reporting whether the document stored under a key has expired.
+func (c *Collection[T]) expired(tx *bbolt.Tx) func(key []byte) bool {
+	return expiredIn(tx, c.Name)
+}
+
+// expiredIn returns a function reporting whether the document of a collection stored under a key has expired.
+func expiredIn(tx *bbolt.Tx, collection string) func(key []byte) bool {
+	ttl := tx.Bucket([]byte(TTL_COLLECTION_NAME + collection))
+	if ttl == nil {
+		return func([]byte) bool { return false }
+	}
+	now := time.Now().UnixNano()
+	return func(key []byte) bool {
+		at := ttl.Get(key)
+		return len(at) == 8 && int64(binary.BigEndian.Uint64(at)) <= now
+	}
+}
+
+// PurgeExpired deletes the expired documents of the collection and returns how many were deleted.
+// Expired documents are already left out of reads, purging reclaims their space. Purged documents go through the
+// delete lifecycle and the reference policies like DeleteByKey, so a restricted reference or a failing hook aborts
+// the purge, but they are never soft deleted. Expired documents that cannot be decoded are deleted without hooks.
+func (c *Collection[T]) PurgeExpired() (int, error) {
+	purged := 0
+	err := c.around("PurgeExpired", func() error {
+		var err error
+		purged, err = c.purgeExpired()
+		return err
+	})
+	return purged, err
+}
+
+func (c *Collection[T]) purgeExpired() (int, error) {
+	var deleted []*T
+	err := c.update(false, func(tx *bbolt.Tx) error {
+		deleted = nil
+		ttl := tx.Bucket([]byte(TTL_COLLECTION_NAME + c.Name))
+		bucket := tx.Bucket(c.nameBytes)
+		if ttl == nil || bucket == nil {
+			return nil
+		}
+		expired := c.expired(tx)
+		var keys [][]byte
+		_ = ttl.ForEach(func(k, _ []byte) error {
+			if expired(k) {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		})
+		for _, key := range keys {
+			// the document may be gone already, its expiry is cleared either way
+			value := bucket.Get(key)
+			if value == nil {
+				if err := ttl.Delete(key); err != nil {
+					return err
+				}
+				continue
+			}
+			document := new(T)
+			decoded := c.unmarshal(value, document) == nil
+			if decoded {
+				if err := c.prepare(deleteLifecycle, document); err != nil {
+					return err
+				}
+			}
+			if err := c.remove(tx, bucket, key, false); err != nil {
+				return err
+			}
+			if !decoded {
+				continue
+			}
+			if err := c.written(deleteLifecycle, document); err != nil {
+				return err
+			}
+			deleted = append(deleted, document)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(deleted), c.committed(deleteLifecycle, deleted...)
+}
+
+// Deleted returns the documents soft deleted from the collection, see SoftDelete.
+func (c *Collection[T]) Deleted() ([]T, error) {
+	var documents []T
+	err := c.Driver.view(func(tx *bbolt.Tx) error {
+		deleted := tx.Bucket([]byte(DELETED_COLLECTION_NAME + c.Name))
+		if deleted == nil {
+			return nil
+		}
+		return deleted.ForEach(func(k, v []byte) error {
+			var document T
+			if err := c.unmarshal(v, &document); err != nil {
+				return CorruptDocument{Collection: c.Name, Key: append([]byte{}, k...), Value: append([]byte{}, v...), Err: err}
+			}
+			documents = append(documents, document)
+			return nil
+		})
+	})
+	return documents, err
+}
+
+// Undelete restores a soft deleted document, failing if a document with the same key was inserted since.
+// The restored document goes through the insert lifecycle, so it is validated and runs the insert hooks.
+func (c *Collection[T]) Undelete(key string) error {
+	return c.around("Undelete", func() error {
+		return c.undelete([]byte(key))
+	})
+}
+
+func (c *Collection[T]) undelete(key []byte) error {
+	var document T
+	err := c.update(false, func(tx *bbolt.Tx) error {
+		deleted := tx.Bucket([]byte(DELETED_COLLECTION_NAME + c.Name))
+		if deleted == nil || deleted.Get(key) == nil {
+			return documentNotFound(c.Name, key)
+		}
+		document = *new(T)
+		if err := c.unmarshal(deleted.Get(key), &document); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucketIfNotExists(c.nameBytes)
+		if err != nil {
+			return err
+		}
+		if bucket.Get(key) != nil {
+			return documentExists(c.Name, key)
+		}
+		if err := c.prepare(insertLifecycle, &document); err != nil {
+			return err
+		}
+		if !bytes.Equal(document.Key(), key) {
+			return fmt.Errorf("the key of document %s cannot be changed to %s", key, document.Key())
+		}
+		value, err := c.encode(document)
+		if err != nil {
+			return err
+		}
+		if err := c.put(tx, bucket, key, value, &document); err != nil {
+			return err
+		}
+		if err := deleted.Delete(key); err != nil {
+			return err
+		}
+		return c.written(insertLifecycle, &document)
+	})
+	if err != nil {
+		return err
+	}
+	return c.committed(insertLifecycle, &document)
+}
@@ -0,0 +1,528 @@
+package bingo
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"go.etcd.io/bbolt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is a logical export format.
+type Format string
+
+const (
+	// JSONL writes one document per line.
+	JSONL Format = "jsonl"
+	// JSONArray writes the documents as a single JSON array.
+	JSONArray Format = "json"
+	// CSV writes one document per row, with columns derived from the fields of the collection.
+	CSV Format = "csv"
+)
+
+// ExportOptions configures Collection.Export.
+// Columns sets the dotted field paths exported as CSV columns, they default to the fields of the collection.
+// Mapping maps CSV column headers to field paths, it is used by both Export and Import.
+type ExportOptions struct {
+	Columns []string
+	Mapping map[string]string
+}
+
+// ImportOptions configures Collection.Import.
+// The embedded InsertOptions select the conflict policy: Upsert replaces existing documents,
+// IgnoreErrors skips existing and invalid documents instead of failing the import.
+// BatchSize is the number of documents inserted per transaction, it defaults to 1000.
+type ImportOptions struct {
+	InsertOptions
+	BatchSize int
+	Mapping   map[string]string
+}
+
+// Export writes every document of the collection to w in key order.
+func (c *Collection[T]) Export(w io.Writer, format Format, opts ...func(options *ExportOptions)) error {
+	opt := &ExportOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	bw := bufio.NewWriter(w)
+	var write func(raw []byte) error
+	var finish func() error
+	switch format {
+	case JSONL:
+		write = func(raw []byte) error {
+			if _, err := bw.Write(raw); err != nil {
+				return err
+			}
+			return bw.WriteByte('\n')
+		}
+		finish = func() error { return nil }
+	case JSONArray:
+		first := true
+		bw.WriteString("[")
+		write = func(raw []byte) error {
+			if !first {
+				bw.WriteString(",")
+			}
+			first = false
+			bw.WriteString("\n  ")
+			_, err := bw.Write(raw)
+			return err
+		}
+		finish = func() error {
+			_, err := bw.WriteString("\n]\n")
+			return err
+		}
+	case CSV:
+		columns := opt.Columns
+		if len(columns) == 0 {
+			columns = c.columns()
+		}
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = column
+			for header, path := range opt.Mapping {
+				if path == column {
+					headers[i] = header
+				}
+			}
+		}
+		cw := csv.NewWriter(bw)
+		if err := cw.Write(headers); err != nil {
+			return err
+		}
+		row := make([]string, len(columns))
+		write = func(raw []byte) error {
+			var doc map[string]any
+			if err := Unmarshaller.Unmarshal(raw, &doc); err != nil {
+				return err
+			}
+			for i, column := range columns {
+				row[i] = ""
+				value, ok := LookupPath(doc, column)
+				if !ok || value == nil {
+					continue
+				}
+				if s, isString := value.(string); isString {
+					row[i] = s
+					continue
+				}
+				data, err := Marshaller.Marshal(value)
+				if err != nil {
+					return err
+				}
+				row[i] = string(data)
+			}
+			return cw.Write(row)
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	err := c.Driver.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(c.nameBytes)
+		if bucket == nil {
+			return nil
+		}
+		expired := c.expired(tx)
+		return bucket.ForEach(func(k, v []byte) error {
+			if expired(k) {
+				return nil
+			}
+			// documents of other codecs are exported as JSON
+			if c.codec != nil && c.options.Codec != DEFAULT_CODEC {
+				var doc T
+				if err := c.unmarshal(v, &doc); err != nil {
+					return err
+				}
+				data, err := Marshaller.Marshal(doc)
+				if err != nil {
+					return err
+				}
+				v = data
+			}
+			return write(v)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// columns returns the stored names of the fields recorded for the collection, see Driver.FieldsOf.
+// Embedded structs are stored inline, so their fields are listed in their place.
+func (c *Collection[T]) columns() []string {
+	var o T
+	typ := reflect.TypeOf(o)
+	fields, err := c.Driver.FieldsOf(c.Name)
+	if err != nil {
+		return storedNames(typ)
+	}
+	var columns []string
+	for _, aliases := range fields {
+		field, ok := typ.FieldByName(aliases[0])
+		if !ok {
+			continue
+		}
+		if field.Anonymous && isNestedStruct(field.Type) {
+			columns = append(columns, storedNames(field.Type)...)
+			continue
+		}
+		columns = append(columns, storedName(field))
+	}
+	return columns
+}
+
+// storedName returns the key a struct field is stored under.
+func storedName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("bingo_json"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return field.Name
+}
+
+func storedNames(typ reflect.Type) []string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() || field.Tag.Get("bingo_json") == "-" {
+			continue
+		}
+		if field.Anonymous && isNestedStruct(field.Type) {
+			names = append(names, storedNames(field.Type)...)
+			continue
+		}
+		names = append(names, storedName(field))
+	}
+	return names
+}
+
+// storedType returns the type of the field stored at a dotted path.
+func storedType(typ reflect.Type, path string) (reflect.Type, bool) {
+	for _, part := range strings.Split(path, ".") {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return nil, false
+		}
+		field, ok := storedField(typ, part)
+		if !ok {
+			return nil, false
+		}
+		typ = field.Type
+	}
+	return typ, true
+}
+
+func storedField(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && isNestedStruct(field.Type) {
+			inner := field.Type
+			for inner.Kind() == reflect.Pointer {
+				inner = inner.Elem()
+			}
+			if f, ok := storedField(inner, name); ok {
+				return f, true
+			}
+			continue
+		}
+		if storedName(field) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Import reads documents in the given format from r and inserts them in batches.
+// Documents are validated and the insert hooks run exactly like Collection.InsertMany.
+// It returns the number of documents inserted.
+func (c *Collection[T]) Import(r io.Reader, format Format, options ...func(options *ImportOptions)) (int, error) {
+	opts := ImportOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	insertOpt := func(options *InsertOptions) {
+		*options = opts.InsertOptions
+	}
+
+	imported := 0
+	var batch []T
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ids, err := c.inserts(batch, insertOpt)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id != nil {
+				imported += 1
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	add := func(line int, raw []byte) error {
+		var doc T
+		if err := Unmarshaller.Unmarshal(raw, &doc); err != nil {
+			if opts.IgnoreErrors {
+				return nil
+			}
+			return fmt.Errorf("document %d: %w", line, err)
+		}
+		batch = append(batch, doc)
+		if len(batch) >= opts.BatchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	var err error
+	switch format {
+	case JSONL:
+		err = readJSONL(r, add)
+	case JSONArray:
+		err = readJSONArray(r, add)
+	case CSV:
+		var o T
+		err = readCSV(r, reflect.TypeOf(o), opts.Mapping, add)
+	default:
+		err = fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return imported, err
+	}
+	return imported, flush()
+}
+
+func readJSONL(r io.Reader, add func(line int, raw []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		raw := scanner.Bytes()
+		if len(strings.TrimSpace(string(raw))) == 0 {
+			continue
+		}
+		if err := add(line, append([]byte{}, raw...)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func readJSONArray(r io.Reader, add func(line int, raw []byte) error) error {
+	iter := jsoniter.Parse(json, r, 64*1024)
+	index := 0
+	var err error
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		index += 1
+		raw := iter.SkipAndReturnBytes()
+		if iter.Error != nil {
+			return false
+		}
+		err = add(index, raw)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if iter.Error != nil && iter.Error != io.EOF {
+		return fmt.Errorf("invalid json array: %w", iter.Error)
+	}
+	return nil
+}
+
+// readCSV converts CSV rows into documents. Cells of string fields are kept as is,
+// other cells are parsed as JSON so numbers, booleans, arrays and objects keep their type.
+func readCSV(r io.Reader, typ reflect.Type, mapping map[string]string, add func(line int, raw []byte) error) error {
+	cr := csv.NewReader(r)
+	headers, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	paths := make([]string, len(headers))
+	strs := make([]bool, len(headers))
+	for i, header := range headers {
+		paths[i] = header
+		if path, ok := mapping[header]; ok {
+			paths[i] = path
+		}
+		if t, ok := storedType(typ, paths[i]); ok && t.Kind() == reflect.String {
+			strs[i] = true
+		}
+	}
+
+	line := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line += 1
+		if err != nil {
+			return err
+		}
+		doc := map[string]any{}
+		for i, cell := range record {
+			if cell == "" || paths[i] == "" {
+				continue
+			}
+			var value any = cell
+			if !strs[i] {
+				var parsed any
+				if err := Unmarshaller.Unmarshal([]byte(cell), &parsed); err == nil {
+					value = parsed
+				}
+			}
+			SetPath(doc, paths[i], value)
+		}
+		raw, err := Marshaller.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if err := add(line, raw); err != nil {
+			return err
+		}
+	}
+}
+
+// exportEntry is a line of a full database export.
+type exportEntry struct {
+	Collection string              `bingo_json:"collection"`
+	Key        string              `bingo_json:"key,omitempty"`
+	Key64      string              `bingo_json:"key64,omitempty"`
+	Document   jsoniter.RawMessage `bingo_json:"document,omitempty"`
+	Document64 string              `bingo_json:"document64,omitempty"`
+}
+
+// ExportAll writes every collection of the database, including the metadata, to w as JSON lines.
+func (d *Driver) ExportAll(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	err := d.view(func(tx *bbolt.Tx) error {
+		var names []string
+		if err := tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			names = append(names, string(name))
+			return nil
+		}); err != nil {
+			return err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			err := tx.Bucket([]byte(name)).ForEach(func(k, v []byte) error {
+				entry := exportEntry{Collection: name}
+				// values that are not JSON, such as documents of other codecs and index entries, are base64 encoded
+				if json.Valid(v) {
+					entry.Document = v
+				} else {
+					entry.Document64 = base64.StdEncoding.EncodeToString(v)
+				}
+				if utf8.Valid(k) {
+					entry.Key = string(k)
+				} else {
+					entry.Key64 = base64.StdEncoding.EncodeToString(k)
+				}
+				data, err := Marshaller.Marshal(entry)
+				if err != nil {
+					return err
+				}
+				if _, err := bw.Write(data); err != nil {
+					return err
+				}
+				return bw.WriteByte('\n')
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ImportAll reads an export written by ExportAll, storing every document as is.
+// Existing documents with the same keys are replaced, hooks and validation are not run.
+func (d *Driver) ImportAll(r io.Reader) error {
+	var entries []exportEntry
+	flush := func() error {
+		err := d.update(func(tx *bbolt.Tx) error {
+			for _, entry := range entries {
+				bucket, err := tx.CreateBucketIfNotExists([]byte(entry.Collection))
+				if err != nil {
+					return err
+				}
+				key := []byte(entry.Key)
+				if entry.Key64 != "" {
+					if key, err = base64.StdEncoding.DecodeString(entry.Key64); err != nil {
+						return err
+					}
+				}
+				value := []byte(entry.Document)
+				if entry.Document64 != "" || value == nil {
+					if value, err = base64.StdEncoding.DecodeString(entry.Document64); err != nil {
+						return err
+					}
+				}
+				if err := bucket.Put(key, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		entries = entries[:0]
+		// the export may hold the options of collections
+		d.forgetReferrers()
+		return err
+	}
+	err := readJSONL(r, func(line int, raw []byte) error {
+		var entry exportEntry
+		if err := Unmarshaller.Unmarshal(raw, &entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.Collection == "" || (entry.Key == "" && entry.Key64 == "") {
+			return fmt.Errorf("line %d: missing collection or key", line)
+		}
+		entries = append(entries, entry)
+		if len(entries) >= 1000 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,136 @@
+package bingo_test
+
+import (
+	"bytes"
+	"github.com/nokusukun/bingo"
+	"os"
+	"strings"
+	"testing"
+)
+
+type ExportDocument struct {
+	bingo.Document
+	Name    string   `json:"name" validate:"required"`
+	Age     int      `json:"age"`
+	Tags    []string `json:"tags"`
+	Address struct {
+		City string
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testexport.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testexport.db")
+	}()
+
+	people := bingo.CollectionFrom[ExportDocument](driver, "people")
+	john := ExportDocument{Document: bingo.Document{ID: "john"}, Name: "John", Age: 30, Tags: []string{"a", "b"}}
+	john.Address.City = "Manila"
+	jane := ExportDocument{Document: bingo.Document{ID: "jane"}, Name: "Jane, Jr.", Age: 25}
+	if _, err := people.InsertMany([]ExportDocument{john, jane}); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+
+	for _, format := range []bingo.Format{bingo.JSONL, bingo.JSONArray, bingo.CSV} {
+		var buf bytes.Buffer
+		if err := people.Export(&buf, format); err != nil {
+			t.Fatalf("Failed to export %s: %v", format, err)
+		}
+		copies := bingo.CollectionFrom[ExportDocument](driver, "copies-"+string(format))
+		n, err := copies.Import(bytes.NewReader(buf.Bytes()), format, func(options *bingo.ImportOptions) { options.BatchSize = 1 })
+		if err != nil || n != 2 {
+			t.Fatalf("Failed to import %s: %d %v\n%s", format, n, err, buf.String())
+		}
+		got, err := copies.FindByKey("john")
+		if err != nil || got.Name != "John" || got.Age != 30 || len(got.Tags) != 2 || got.Address.City != "Manila" {
+			t.Fatalf("Unexpected %s round trip: %+v %v\n%s", format, got, err, buf.String())
+		}
+		if got, _ := copies.FindByKey("jane"); got.Name != "Jane, Jr." {
+			t.Fatalf("Unexpected %s round trip of jane: %+v", format, got)
+		}
+
+		if _, err := copies.Import(bytes.NewReader(buf.Bytes()), format); err == nil || !bingo.IsErrDocumentExists(err) {
+			t.Fatalf("Expected importing %s twice to conflict, got %v", format, err)
+		}
+		n, err = copies.Import(bytes.NewReader(buf.Bytes()), format, func(options *bingo.ImportOptions) { options.IgnoreErrors = true })
+		if err != nil || n != 0 {
+			t.Fatalf("Expected existing documents to be skipped, got %d %v", n, err)
+		}
+		n, err = copies.Import(bytes.NewReader(buf.Bytes()), format, func(options *bingo.ImportOptions) { options.Upsert = true })
+		if err != nil || n != 2 {
+			t.Fatalf("Expected existing documents to be replaced, got %d %v", n, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = people.Export(&buf, bingo.CSV, func(options *bingo.ExportOptions) {
+		options.Columns = []string{"_id", "Name", "Address.City"}
+		options.Mapping = map[string]string{"id": "_id", "city": "Address.City"}
+	})
+	if err != nil {
+		t.Fatalf("Failed to export csv with mapping: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "id,Name,city\n") {
+		t.Fatalf("Unexpected csv headers: %s", buf.String())
+	}
+
+	mapped := bingo.CollectionFrom[ExportDocument](driver, "mapped")
+	csvData := "id,full name,age\nbob,Bob,41\n,,3\n"
+	mapping := map[string]string{"id": "_id", "full name": "Name", "age": "Age"}
+	_, err = mapped.Import(strings.NewReader(csvData), bingo.CSV, func(options *bingo.ImportOptions) {
+		options.Mapping = mapping
+	})
+	if err == nil {
+		t.Fatalf("Expected the row without a name to fail validation")
+	}
+	n, err := mapped.Import(strings.NewReader(csvData), bingo.CSV, func(options *bingo.ImportOptions) {
+		options.IgnoreErrors = true
+		options.Mapping = mapping
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("Expected the invalid row to be skipped, got %d %v", n, err)
+	}
+	if bob, err := mapped.FindByKey("bob"); err != nil || bob.Age != 41 {
+		t.Fatalf("Expected bob to be imported, got %+v %v", bob, err)
+	}
+
+	var dump bytes.Buffer
+	if err := driver.ExportAll(&dump); err != nil {
+		t.Fatalf("Failed to export database: %v", err)
+	}
+	if !strings.Contains(dump.String(), `"collection":"__metadata"`) {
+		t.Fatalf("Expected the metadata to be exported")
+	}
+
+	restored, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: "testexport-restored.db"})
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		restored.Close()
+		os.Remove("testexport-restored.db")
+	}()
+	if err := restored.ImportAll(&dump); err != nil {
+		t.Fatalf("Failed to import database: %v", err)
+	}
+	collections, err := restored.GetCollections()
+	if err != nil || len(collections) != 5 {
+		t.Fatalf("Expected 5 collections in the metadata, got %v %v", collections, err)
+	}
+	if fields, err := restored.FieldsOf("people"); err != nil || len(fields) == 0 {
+		t.Fatalf("Expected fields of people to be restored, got %v %v", fields, err)
+	}
+	got, err := bingo.CollectionFrom[ExportDocument](restored, "people").FindByKey("john")
+	if err != nil || got.Address.City != "Manila" {
+		t.Fatalf("Expected john in the restored database, got %+v %v", got, err)
+	}
+}
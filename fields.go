@@ -0,0 +1,190 @@
+package bingo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Computed is implemented by documents deriving some of their fields from others, such as a slug or a lowercase
+// search key. Compute is called on every document inserted or updated, before it is validated, so the derived fields
+// are always stored. It needs a pointer receiver to change the document.
+type Computed interface {
+	Compute()
+}
+
+// fieldPlan holds the fields of a document type filled by their bingo tags:
+//
+//	bingo:"default=value" sets the field to value when it is the zero value on insert, the value takes the rest of the tag.
+//	bingo:"createdAt" sets a time.Time or *time.Time field to the time of the insert when it is unset, upserts keep the stored one.
+//	bingo:"updatedAt" sets a time.Time or *time.Time field to the time of every write.
+//
+// Default values are parsed as JSON for fields other than strings and time.Duration, like bingo:"default=[\"a\"]".
+type fieldPlan struct {
+	defaults  []fieldDefault
+	createdAt [][]int
+	updatedAt [][]int
+}
+
+type fieldDefault struct {
+	index []int
+	value string
+}
+
+var fieldPlans sync.Map
+
+// fieldPlanOf returns the field plan of a document type, failing on tags it cannot apply.
+func fieldPlanOf(typ reflect.Type) (*fieldPlan, error) {
+	if plan, ok := fieldPlans.Load(typ); ok {
+		return plan.(*fieldPlan), nil
+	}
+	plan := &fieldPlan{}
+	if err := plan.collect(typ, nil); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidDocumentType, typ, err)
+	}
+	if len(plan.defaults) == 0 && len(plan.createdAt) == 0 && len(plan.updatedAt) == 0 {
+		plan = nil
+	}
+	fieldPlans.Store(typ, plan)
+	return plan, nil
+}
+
+func (p *fieldPlan) collect(typ reflect.Type, parent []int) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := p.collect(field.Type, index); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := field.Tag.Get("bingo")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		for tag != "" {
+			property := tag
+			if strings.HasPrefix(property, "default=") {
+				tag = ""
+			} else if i := strings.Index(property, ","); i >= 0 {
+				property, tag = property[:i], property[i+1:]
+			} else {
+				tag = ""
+			}
+			switch {
+			case strings.HasPrefix(property, "default="):
+				value := strings.TrimPrefix(property, "default=")
+				if _, err := parseDefault(field.Type, value); err != nil {
+					return fmt.Errorf("invalid default of %s: %w", field.Name, err)
+				}
+				p.defaults = append(p.defaults, fieldDefault{index: index, value: value})
+			case property == "createdAt" || property == "updatedAt":
+				if field.Type != timeType && field.Type != reflect.PtrTo(timeType) {
+					return fmt.Errorf("%s field %s must be a time.Time or *time.Time", property, field.Name)
+				}
+				if property == "createdAt" {
+					p.createdAt = append(p.createdAt, index)
+				} else {
+					p.updatedAt = append(p.updatedAt, index)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseDefault parses the default value of a field of a type.
+func parseDefault(typ reflect.Type, s string) (reflect.Value, error) {
+	value := reflect.New(typ)
+	target := value.Elem()
+	for target.Kind() == reflect.Pointer {
+		target.Set(reflect.New(target.Type().Elem()))
+		target = target.Elem()
+	}
+	switch {
+	case target.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		target.SetInt(int64(d))
+	case target.Kind() == reflect.String:
+		target.SetString(s)
+	default:
+		if err := Unmarshaller.Unmarshal([]byte(s), target.Addr().Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return value.Elem(), nil
+}
+
+// fill applies the field plan to a document written at now, defaults and createdAt fields are only set on creation.
+func (p *fieldPlan) fill(doc reflect.Value, now time.Time, creates bool) {
+	for _, index := range p.updatedAt {
+		setTime(doc.FieldByIndex(index), now)
+	}
+	if !creates {
+		return
+	}
+	for _, d := range p.defaults {
+		// defaults are parsed again for every document, so documents never share a slice, map or pointer
+		if field := doc.FieldByIndex(d.index); field.IsZero() {
+			value, _ := parseDefault(field.Type(), d.value)
+			field.Set(value)
+		}
+	}
+	for _, index := range p.createdAt {
+		if field := doc.FieldByIndex(index); field.IsZero() || isZeroTime(field) {
+			setTime(field, now)
+		}
+	}
+}
+
+// keepCreated copies the createdAt fields of the stored document old to doc, so upserts replacing a document keep
+// the time it was created at. Unset fields of old are left.
+func (p *fieldPlan) keepCreated(doc, old reflect.Value) {
+	for _, index := range p.createdAt {
+		if field := old.FieldByIndex(index); !field.IsZero() && !isZeroTime(field) {
+			doc.FieldByIndex(index).Set(field)
+		}
+	}
+}
+
+func isZeroTime(field reflect.Value) bool {
+	return field.Kind() == reflect.Pointer && field.Elem().Interface().(time.Time).IsZero()
+}
+
+func setTime(field reflect.Value, now time.Time) {
+	if field.Kind() == reflect.Pointer {
+		field.Set(reflect.ValueOf(&now))
+		return
+	}
+	field.Set(reflect.ValueOf(now))
+}
+
+// fillFields fills the tagged fields of a document created or updated, then computes its derived fields.
+func (c *Collection[T]) fillFields(doc *T, creates bool) {
+	if c.fields != nil {
+		c.fields.fill(reflect.ValueOf(doc).Elem(), time.Now(), creates)
+	}
+	if computed, ok := any(doc).(Computed); ok {
+		computed.Compute()
+	}
+}
+
+// keepCreated keeps the createdAt fields of the document stored as value on a document upserted over it, returning
+// whether the document may have changed. Stored documents that cannot be decoded are replaced as they are.
+func (c *Collection[T]) keepCreated(doc *T, value []byte) bool {
+	if c.fields == nil || len(c.fields.createdAt) == 0 || value == nil {
+		return false
+	}
+	var old T
+	if c.unmarshal(value, &old) != nil {
+		return false
+	}
+	c.fields.keepCreated(reflect.ValueOf(doc).Elem(), reflect.ValueOf(old))
+	return true
+}
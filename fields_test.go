@@ -0,0 +1,113 @@
+package bingo_test
+
+import (
+	"errors"
+	"github.com/nokusukun/bingo"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Article struct {
+	bingo.Document
+	Title     string        `json:"title" validate:"required"`
+	Slug      string        `json:"slug" validate:"required"`
+	Status    string        `json:"status" bingo:"default=draft"`
+	Tags      []string      `json:"tags" bingo:"default=[\"new\",\"unread\"]"`
+	Views     int           `json:"views" bingo:"default=1"`
+	Expiry    time.Duration `json:"expiry" bingo:"default=1h"`
+	Featured  bool          `json:"featured" bingo:"default=true"`
+	CreatedAt time.Time     `json:"createdAt" bingo:"createdAt"`
+	UpdatedAt *time.Time    `json:"updatedAt" bingo:"updatedAt"`
+}
+
+func (a *Article) Compute() {
+	a.Slug = strings.ReplaceAll(strings.ToLower(a.Title), " ", "-")
+}
+
+type BadTimestamp struct {
+	bingo.Document
+	CreatedAt string `bingo:"createdAt"`
+}
+
+func TestFieldTags(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testfields.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testfields.db")
+	}()
+
+	if _, err := bingo.OpenCollection[BadTimestamp](driver, "bad"); !errors.Is(err, bingo.ErrInvalidDocumentType) {
+		t.Fatalf("Expected a createdAt string field to be rejected, got %v", err)
+	}
+
+	articles := bingo.CollectionFrom[Article](driver, "articles")
+	// the slug is computed before validation, so the required slug is never missing
+	if _, err := articles.Insert(Article{Document: bingo.Document{ID: "a"}, Title: "Hello World"}); err != nil {
+		t.Fatalf("Failed to insert article: %v", err)
+	}
+	if _, err := articles.Insert(Article{Document: bingo.Document{ID: "b"}, Title: "Other", Status: "published", Views: 5}); err != nil {
+		t.Fatalf("Failed to insert article: %v", err)
+	}
+
+	a, err := articles.FindByKey("a")
+	if err != nil {
+		t.Fatalf("Failed to find article: %v", err)
+	}
+	if a.Slug != "hello-world" || a.Status != "draft" || strings.Join(a.Tags, ",") != "new,unread" || a.Views != 1 || a.Expiry != time.Hour || !a.Featured {
+		t.Fatalf("Expected the defaults and computed fields to be stored, got %+v", a)
+	}
+	if a.CreatedAt.IsZero() || a.UpdatedAt == nil || !a.UpdatedAt.Equal(a.CreatedAt) {
+		t.Fatalf("Expected the timestamps to be set, got %v %v", a.CreatedAt, a.UpdatedAt)
+	}
+	if b, _ := articles.FindByKey("b"); b.Status != "published" || b.Views != 5 {
+		t.Fatalf("Expected set fields to keep their values, got %+v", b)
+	}
+
+	a.Tags = append(a.Tags, "shared")
+	a.Title = "Hello Again"
+	time.Sleep(time.Millisecond)
+	if err := articles.UpdateOne(a); err != nil {
+		t.Fatalf("Failed to update article: %v", err)
+	}
+	updated, _ := articles.FindByKey("a")
+	if updated.Slug != "hello-again" || !updated.CreatedAt.Equal(a.CreatedAt) || !updated.UpdatedAt.After(updated.CreatedAt) {
+		t.Fatalf("Expected the update to recompute the slug and only touch updatedAt, got %+v", updated)
+	}
+
+	// defaults only apply on insert, updates store zero values as given
+	updated.Featured = false
+	updated.Views = 0
+	if err := articles.UpdateOne(updated); err != nil {
+		t.Fatalf("Failed to update article: %v", err)
+	}
+	if cleared, _ := articles.FindByKey("a"); cleared.Featured || cleared.Views != 0 || cleared.Status != "draft" {
+		t.Fatalf("Expected the update to keep the zero values, got %+v", cleared)
+	}
+
+	// upserts over a stored document keep the time it was created at
+	for _, opts := range [][]func(*bingo.InsertOptions){{bingo.Upsert}, {bingo.Upsert, bingo.Batch}} {
+		time.Sleep(time.Millisecond)
+		if _, err := articles.Insert(Article{Document: bingo.Document{ID: "a"}, Title: "Replaced"}, opts...); err != nil {
+			t.Fatalf("Failed to upsert article: %v", err)
+		}
+		if replaced, _ := articles.FindByKey("a"); !replaced.CreatedAt.Equal(a.CreatedAt) || !replaced.UpdatedAt.After(a.CreatedAt) {
+			t.Fatalf("Expected the upsert to keep createdAt, got %v %v", replaced.CreatedAt, replaced.UpdatedAt)
+		}
+	}
+
+	if _, err := articles.Insert(Article{Document: bingo.Document{ID: "c"}, Title: "Third"}); err != nil {
+		t.Fatalf("Failed to insert article: %v", err)
+	}
+	if c, _ := articles.FindByKey("c"); strings.Join(c.Tags, ",") != "new,unread" {
+		t.Fatalf("Expected documents not to share default values, got %v", c.Tags)
+	}
+}
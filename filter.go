@@ -0,0 +1,430 @@
+package bingo
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Filter is a declarative, JSON friendly document filter modelled after MongoDB queries.
+//
+//	{"name": "john", "age": {"$gte": 18}, "$or": [{"role": "admin"}, {"tags": {"$in": ["staff"]}}]}
+//
+// Field names are the names used when storing documents, nested fields are addressed with dots ("address.city").
+// Supported operators are $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists, $regex, $contains, $size and $not
+// on fields, and $and, $or and $nor at the document level.
+type Filter map[string]any
+
+// ParseFilter parses and validates a JSON filter.
+func ParseFilter(data []byte) (Filter, error) {
+	var f Filter
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return Filter{}, nil
+	}
+	if err := Unmarshaller.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	if f == nil {
+		f = Filter{}
+	}
+	return f, f.Validate()
+}
+
+// Validate returns an error if the filter uses an unknown operator or malformed operands.
+func (f Filter) Validate() error {
+	for k, v := range f {
+		switch k {
+		case "$and", "$or", "$nor":
+			subs, ok := subFilters(v)
+			if !ok {
+				return fmt.Errorf("invalid filter: %s expects an array of objects", k)
+			}
+			for _, sub := range subs {
+				if err := sub.Validate(); err != nil {
+					return err
+				}
+			}
+		default:
+			if strings.HasPrefix(k, "$") {
+				return fmt.Errorf("invalid filter: unknown operator %s", k)
+			}
+			if err := validateCondition(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateCondition(v any) error {
+	ops, ok := operators(v)
+	if !ok {
+		return nil
+	}
+	for op, arg := range ops {
+		switch op {
+		case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$exists", "$contains":
+		case "$in", "$nin":
+			if _, ok := toSlice(arg); !ok {
+				return fmt.Errorf("invalid filter: %s expects an array", op)
+			}
+		case "$size":
+			if _, ok := toFloat(arg); !ok {
+				return fmt.Errorf("invalid filter: $size expects a number")
+			}
+		case "$regex":
+			s, ok := arg.(string)
+			if !ok {
+				return fmt.Errorf("invalid filter: $regex expects a string")
+			}
+			if _, err := compileRegex(s); err != nil {
+				return fmt.Errorf("invalid filter: %w", err)
+			}
+		case "$not":
+			if err := validateCondition(arg); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid filter: unknown operator %s", op)
+		}
+	}
+	return nil
+}
+
+// operators returns the operator map of a condition, if the condition is an operator expression.
+func operators(v any) (map[string]any, bool) {
+	m, ok := toObject(v)
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// Match returns true if the document satisfies the filter. An empty filter matches every document.
+func (f Filter) Match(doc map[string]any) bool {
+	for k, v := range f {
+		switch k {
+		case "$and":
+			subs, _ := subFilters(v)
+			for _, sub := range subs {
+				if !sub.Match(doc) {
+					return false
+				}
+			}
+		case "$or":
+			matched := false
+			subs, _ := subFilters(v)
+			for _, sub := range subs {
+				if sub.Match(doc) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "$nor":
+			subs, _ := subFilters(v)
+			for _, sub := range subs {
+				if sub.Match(doc) {
+					return false
+				}
+			}
+		default:
+			value, exists := LookupPath(doc, k)
+			if !matchCondition(value, exists, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchCondition(value any, exists bool, cond any) bool {
+	ops, ok := operators(cond)
+	if !ok {
+		return exists && matchEquals(value, cond)
+	}
+	for op, arg := range ops {
+		var matched bool
+		switch op {
+		case "$eq":
+			matched = exists && matchEquals(value, arg)
+		case "$ne":
+			matched = !exists || !matchEquals(value, arg)
+		case "$gt", "$gte", "$lt", "$lte":
+			matched = exists && matchAny(value, func(v any) bool {
+				c, ok := compareValues(v, arg)
+				if !ok {
+					return false
+				}
+				switch op {
+				case "$gt":
+					return c > 0
+				case "$gte":
+					return c >= 0
+				case "$lt":
+					return c < 0
+				}
+				return c <= 0
+			})
+		case "$in":
+			args, _ := toSlice(arg)
+			for _, a := range args {
+				if exists && matchEquals(value, a) {
+					matched = true
+					break
+				}
+			}
+		case "$nin":
+			matched = true
+			args, _ := toSlice(arg)
+			for _, a := range args {
+				if exists && matchEquals(value, a) {
+					matched = false
+					break
+				}
+			}
+		case "$exists":
+			want, _ := arg.(bool)
+			matched = exists == want
+		case "$regex":
+			pattern, _ := arg.(string)
+			re, err := compileRegex(pattern)
+			matched = err == nil && exists && matchAny(value, func(v any) bool {
+				s, ok := v.(string)
+				return ok && re.MatchString(s)
+			})
+		case "$contains":
+			switch v := value.(type) {
+			case string:
+				s, ok := arg.(string)
+				matched = ok && strings.Contains(v, s)
+			case []any:
+				for _, i := range v {
+					if valuesEqual(i, arg) {
+						matched = true
+						break
+					}
+				}
+			}
+		case "$size":
+			n, _ := toFloat(arg)
+			items, ok := value.([]any)
+			matched = ok && float64(len(items)) == n
+		case "$not":
+			matched = !matchCondition(value, exists, arg)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchEquals compares a value to an operand, arrays match if any of their elements equals the operand.
+func matchEquals(value any, arg any) bool {
+	if valuesEqual(value, arg) {
+		return true
+	}
+	if items, ok := value.([]any); ok {
+		for _, i := range items {
+			if valuesEqual(i, arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(value any, f func(v any) bool) bool {
+	if items, ok := value.([]any); ok {
+		for _, i := range items {
+			if f(i) {
+				return true
+			}
+		}
+		return false
+	}
+	return f(value)
+}
+
+func valuesEqual(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compareValues(a, b any) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	sb, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func toObject(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case Filter:
+		return m, true
+	case map[string]any:
+		return m, true
+	}
+	return nil, false
+}
+
+// toSlice converts JSON arrays and Go slices into a []any.
+func toSlice(v any) ([]any, bool) {
+	if s, ok := v.([]any); ok {
+		return s, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	s := make([]any, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+	return s, true
+}
+
+func subFilters(v any) ([]Filter, bool) {
+	items, ok := toSlice(v)
+	if !ok {
+		return nil, false
+	}
+	filters := make([]Filter, len(items))
+	for i, item := range items {
+		m, ok := toObject(item)
+		if !ok {
+			return nil, false
+		}
+		filters[i] = m
+	}
+	return filters, true
+}
+
+var regexCache sync.Map
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// LookupPath returns the value at a dotted path of a decoded JSON document.
+// Array elements can be addressed by their index ("tags.0").
+func LookupPath(doc map[string]any, path string) (any, bool) {
+	var current any = doc
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]any:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SetPath sets the value at a dotted path of a decoded JSON document, creating intermediate objects as needed.
+func SetPath(doc map[string]any, path string, value any) {
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// Where returns a filter function for typed collections from a declarative filter.
+// Documents are matched on their stored JSON representation.
+//
+//	users.Find(bingo.Where[User](bingo.Filter{"Age": map[string]any{"$gte": 18}}))
+func Where[T DocumentSpec](f Filter) func(doc T) bool {
+	return func(doc T) bool {
+		m, err := toMap(doc)
+		if err != nil {
+			return false
+		}
+		return f.Match(m)
+	}
+}
+
+// toMap converts a document into its stored JSON representation.
+func toMap(doc any) (map[string]any, error) {
+	data, err := Marshaller.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	err = Unmarshaller.Unmarshal(data, &m)
+	return m, err
+}
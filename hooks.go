@@ -0,0 +1,204 @@
+package bingo
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// HookEvent is the event of a collection a hook runs on.
+type HookEvent int
+
+const (
+	HookBeforeInsert HookEvent = iota
+	HookAfterInsert
+	HookBeforeUpdate
+	HookAfterUpdate
+	HookBeforeDelete
+	HookAfterDelete
+	// HookAfterFind runs on every document returned by a read, before it is returned. Documents read by
+	// UpdateIter and DeleteIter are not returned and do not run it.
+	HookAfterFind
+	// HookAround wraps whole operations, see Hook.Around.
+	HookAround
+	// HookValidateInsert and HookValidateUpdate run once the validator of the collection accepted the document,
+	// see PhaseValidate.
+	HookValidateInsert
+	HookValidateUpdate
+	// HookAfterInsertTx, HookAfterUpdateTx and HookAfterDeleteTx run inside the write transaction once the document is
+	// stored, see PhaseAfterTx. The After events run once it is committed.
+	HookAfterInsertTx
+	HookAfterUpdateTx
+	HookAfterDeleteTx
+)
+
+var hookEventNames = map[HookEvent]string{
+	HookBeforeInsert:   "BeforeInsert",
+	HookAfterInsert:    "AfterInsert",
+	HookBeforeUpdate:   "BeforeUpdate",
+	HookAfterUpdate:    "AfterUpdate",
+	HookBeforeDelete:   "BeforeDelete",
+	HookAfterDelete:    "AfterDelete",
+	HookAfterFind:      "AfterFind",
+	HookAround:         "Around",
+	HookValidateInsert: "ValidateInsert",
+	HookValidateUpdate: "ValidateUpdate",
+	HookAfterInsertTx:  "AfterInsertTx",
+	HookAfterUpdateTx:  "AfterUpdateTx",
+	HookAfterDeleteTx:  "AfterDeleteTx",
+}
+
+func (e HookEvent) String() string {
+	return hookEventNames[e]
+}
+
+// Operation describes the collection method an Around hook wraps. Method is the name of the method, such as "Insert",
+// "UpdateOne" or "QueryResult.Delete". Methods built on others are seen as the method they call: Delete and Update as
+// DeleteOne and UpdateOne for each document, FindWithKeys as "Find", FindOneWithKey as "FindOne", and the variants of
+// FindByKey and FindByKeys as "FindByKey" and "FindByKeys".
+type Operation struct {
+	Collection string
+	Method     string
+}
+
+// Hook is a named hook of a collection, see Collection.Use and DynamicCollection.Use.
+// Hooks of the same event run by ascending Priority, hooks of the same priority in the order they were added.
+// Func is called with the document of the event, its error aborts the operation wrapped in a *HookError naming the hook.
+// Around is called instead of Func for HookAround hooks, it must call next to run the operation and return its error.
+type Hook[T any] struct {
+	Name     string
+	Event    HookEvent
+	Priority int
+	Func     func(doc *T) error
+	Around   func(op Operation, next func() error) error
+}
+
+// HookHandle identifies a hook added with Collection.Use, see Collection.RemoveHook.
+type HookHandle uint64
+
+var hookHandles uint64
+
+type registeredHook[T any] struct {
+	Hook[T]
+	handle HookHandle
+}
+
+// hookChain holds the hooks of a collection. Hooks are replaced rather than modified, so the hooks of a running
+// operation are never changed under it.
+type hookChain[T any] struct {
+	mu    sync.RWMutex
+	hooks []registeredHook[T]
+}
+
+func (h *hookChain[T]) add(hook Hook[T]) HookHandle {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	handle := HookHandle(atomic.AddUint64(&hookHandles, 1))
+	hooks := append(append([]registeredHook[T]{}, h.hooks...), registeredHook[T]{Hook: hook, handle: handle})
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Priority < hooks[j].Priority
+	})
+	h.hooks = hooks
+	return handle
+}
+
+func (h *hookChain[T]) remove(match func(hook registeredHook[T]) bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var hooks []registeredHook[T]
+	for _, hook := range h.hooks {
+		if !match(hook) {
+			hooks = append(hooks, hook)
+		}
+	}
+	removed := len(hooks) != len(h.hooks)
+	h.hooks = hooks
+	return removed
+}
+
+func (h *hookChain[T]) list() []registeredHook[T] {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.hooks
+}
+
+func (h *hookChain[T]) clone() *hookChain[T] {
+	return &hookChain[T]{hooks: h.list()}
+}
+
+// Use adds a hook to the chain of its event and returns a handle to remove it.
+func (c *Collection[T]) Use(hook Hook[T]) HookHandle {
+	return c.hooks.add(hook)
+}
+
+// RemoveHook removes a hook added with Use, it returns false if the hook was already removed.
+func (c *Collection[T]) RemoveHook(handle HookHandle) bool {
+	return c.hooks.remove(func(hook registeredHook[T]) bool {
+		return hook.handle == handle
+	})
+}
+
+// Hooks returns the hooks of the collection in the order they run.
+func (c *Collection[T]) Hooks() []Hook[T] {
+	var hooks []Hook[T]
+	for _, hook := range c.hooks.list() {
+		hooks = append(hooks, hook.Hook)
+	}
+	return hooks
+}
+
+// Around adds a hook wrapping every operation of the collection, see Hook.Around.
+func (c *Collection[T]) Around(name string, f func(op Operation, next func() error) error) HookHandle {
+	return c.Use(Hook[T]{Name: name, Event: HookAround, Around: f})
+}
+
+// AfterFind registers a function to be called on every document returned by a read.
+func (c *Collection[T]) AfterFind(f func(doc *T) error) *Collection[T] {
+	return c.setHook(HookAfterFind, f)
+}
+
+// setHook replaces the hook registered by the setter of an event, such as BeforeInsert. A nil f removes it.
+func (c *Collection[T]) setHook(event HookEvent, f func(doc *T) error) *Collection[T] {
+	name := event.String()
+	c.hooks.remove(func(hook registeredHook[T]) bool {
+		return hook.Event == event && hook.Name == name
+	})
+	if f != nil {
+		c.hooks.add(Hook[T]{Name: name, Event: event, Func: f})
+	}
+	return c
+}
+
+// runHooks runs the hooks of an event on a document, stopping at the first error.
+func (c *Collection[T]) runHooks(event HookEvent, doc *T) error {
+	for _, hook := range c.hooks.list() {
+		if hook.Event != event || hook.Func == nil {
+			continue
+		}
+		if err := hook.Func(doc); err != nil {
+			return &HookError{Hook: hook.Name, Collection: c.Name, Key: (*doc).Key(), Err: err}
+		}
+	}
+	return nil
+}
+
+// around runs an operation through the Around hooks of the collection, the first hook to run being the outermost.
+func (c *Collection[T]) around(method string, f func() error) error {
+	op := Operation{Collection: c.Name, Method: method}
+	next := f
+	hooks := c.hooks.list()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.Event != HookAround || hook.Around == nil {
+			continue
+		}
+		inner := next
+		next = func() error {
+			return hook.Around(op, inner)
+		}
+	}
+	return next()
+}
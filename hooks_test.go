@@ -0,0 +1,213 @@
+package bingo_test
+
+import (
+	"errors"
+	"github.com/nokusukun/bingo"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHookChains(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testhooks.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testhooks.db")
+	}()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "hooks")
+	var calls []string
+	record := func(name string) func(doc *TestDocument) error {
+		return func(doc *TestDocument) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	coll.Use(bingo.Hook[TestDocument]{Name: "search", Event: bingo.HookBeforeInsert, Priority: 10, Func: record("search")})
+	audit := coll.Use(bingo.Hook[TestDocument]{Name: "audit", Event: bingo.HookBeforeInsert, Priority: -10, Func: record("audit")})
+	coll.BeforeInsert(record("setter"))
+	// setters replace their own hook only
+	coll.BeforeInsert(record("setter again"))
+
+	if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "a"}, Name: "a"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "audit,setter again,search" {
+		t.Fatalf("Expected hooks to run by priority, got %s", got)
+	}
+
+	if !coll.RemoveHook(audit) || coll.RemoveHook(audit) {
+		t.Fatalf("Expected the audit hook to be removed once")
+	}
+	calls = nil
+	if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "b"}, Name: "b"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "setter again,search" {
+		t.Fatalf("Expected the removed hook not to run, got %s", got)
+	}
+
+	errRejected := errors.New("rejected")
+	coll.Use(bingo.Hook[TestDocument]{Name: "reject", Event: bingo.HookBeforeDelete, Func: func(doc *TestDocument) error {
+		return errRejected
+	}})
+	var herr *bingo.HookError
+	if err := coll.DeleteOne(TestDocument{Document: bingo.Document{ID: "a"}}); !errors.As(err, &herr) || herr.Hook != "reject" {
+		t.Fatalf("Expected the reject hook to fail the delete, got %v", err)
+	}
+
+	coll.AfterFind(func(doc *TestDocument) error {
+		doc.Name = strings.ToUpper(doc.Name)
+		return nil
+	})
+	if doc, err := coll.FindByKey("a"); err != nil || doc.Name != "A" {
+		t.Fatalf("Expected AfterFind to change the document, got %v %v", doc, err)
+	}
+	if docs, err := coll.Find(func(doc TestDocument) bool { return true }); err != nil || len(docs) != 2 || docs[0].Name != strings.ToUpper(docs[0].Name) {
+		t.Fatalf("Expected AfterFind to run on Find, got %v %v", docs, err)
+	}
+
+	var ops []string
+	coll.Around("trace", func(op bingo.Operation, next func() error) error {
+		ops = append(ops, "start "+op.Method)
+		err := next()
+		ops = append(ops, "end "+op.Method)
+		return err
+	})
+	errDenied := errors.New("denied")
+	coll.Use(bingo.Hook[TestDocument]{Name: "deny", Event: bingo.HookAround, Priority: 1, Around: func(op bingo.Operation, next func() error) error {
+		if op.Method == "UpdateOne" {
+			return errDenied
+		}
+		return next()
+	}})
+	if _, err := coll.FindByKey("b"); err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if err := coll.UpdateOne(TestDocument{Document: bingo.Document{ID: "b"}, Name: "changed"}); !errors.Is(err, errDenied) {
+		t.Fatalf("Expected the around hook to deny the update, got %v", err)
+	}
+	if got := strings.Join(ops, ","); got != "start FindByKey,end FindByKey,start UpdateOne,end UpdateOne" {
+		t.Fatalf("Expected the operations to be wrapped, got %s", got)
+	}
+	if doc, _ := coll.FindByKey("b"); doc.Name != "B" {
+		t.Fatalf("Expected the denied update not to be written, got %v", doc)
+	}
+}
+
+func TestHookPhases(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testphases.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testphases.db")
+	}()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "phases")
+	var calls []string
+	for _, event := range []bingo.HookEvent{
+		bingo.HookAfterUpdate, bingo.HookAfterUpdateTx, bingo.HookBeforeUpdate, bingo.HookValidateUpdate,
+		bingo.HookAfterDelete, bingo.HookAfterDeleteTx, bingo.HookBeforeDelete,
+	} {
+		event := event
+		coll.Use(bingo.Hook[TestDocument]{Name: event.String(), Event: event, Func: func(doc *TestDocument) error {
+			calls = append(calls, event.Phase().String())
+			return nil
+		}})
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: id}, Name: id}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	updates := map[string]func() error{
+		"UpdateOne": func() error {
+			return coll.UpdateOne(TestDocument{Document: bingo.Document{ID: "a"}, Name: "updated"})
+		},
+		"UpdateIter": func() error {
+			return coll.UpdateIter(func(doc *TestDocument) *TestDocument {
+				if doc.ID != "a" {
+					return nil
+				}
+				return doc
+			})
+		},
+		"QueryResult.Update": func() error {
+			return coll.Query(bingo.Query[TestDocument]{KeysStr: []string{"a"}}).Update()
+		},
+	}
+	for method, update := range updates {
+		calls = nil
+		if err := update(); err != nil {
+			t.Fatalf("%s failed: %v", method, err)
+		}
+		if got := strings.Join(calls, ","); got != "Validate,Before,AfterTx,AfterCommit" {
+			t.Fatalf("Expected %s to run every phase in order, got %s", method, got)
+		}
+	}
+
+	calls = nil
+	if err := coll.DeleteOne(TestDocument{Document: bingo.Document{ID: "c"}}); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "Before,AfterTx,AfterCommit" {
+		t.Fatalf("Expected deletes to skip validation, got %s", got)
+	}
+
+	if err := coll.UpdateOne(TestDocument{Document: bingo.Document{ID: "a"}}); !bingo.IsErrValidation(err) {
+		t.Fatalf("Expected updates to be validated, got %v", err)
+	}
+
+	errRollback := errors.New("rollback")
+	handle := coll.Use(bingo.Hook[TestDocument]{Name: "rollback", Event: bingo.HookAfterUpdateTx, Func: func(doc *TestDocument) error {
+		return errRollback
+	}})
+	if err := coll.UpdateOne(TestDocument{Document: bingo.Document{ID: "b"}, Name: "lost"}); !errors.Is(err, errRollback) {
+		t.Fatalf("Expected the AfterTx hook to fail the update, got %v", err)
+	}
+	if doc, _ := coll.FindByKey("b"); doc.Name != "b" {
+		t.Fatalf("Expected the update to be rolled back, got %v", doc)
+	}
+	coll.RemoveHook(handle)
+
+	// documents failing before the write are skipped by IgnoreErrors, a failing AfterTx hook rolls back the whole insert
+	handle = coll.Use(bingo.Hook[TestDocument]{Name: "rollback", Event: bingo.HookAfterInsertTx, Func: func(doc *TestDocument) error {
+		if doc.ID == "e" {
+			return errRollback
+		}
+		return nil
+	}})
+	docs := []TestDocument{{Document: bingo.Document{ID: "d"}, Name: "d"}, {Document: bingo.Document{ID: "e"}, Name: "e"}}
+	if _, err := coll.InsertMany(docs, bingo.IgnoreErrors); !errors.Is(err, errRollback) {
+		t.Fatalf("Expected the AfterTx hook to fail the insert, got %v", err)
+	}
+	if _, err := coll.FindByKey("d"); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected the insert to be rolled back, got %v", err)
+	}
+	coll.RemoveHook(handle)
+
+	coll.Use(bingo.Hook[TestDocument]{Name: "late", Event: bingo.HookAfterUpdate, Func: func(doc *TestDocument) error {
+		return errRollback
+	}})
+	if err := coll.UpdateOne(TestDocument{Document: bingo.Document{ID: "b"}, Name: "kept"}); !errors.Is(err, errRollback) {
+		t.Fatalf("Expected the After hook error to be returned, got %v", err)
+	}
+	if doc, _ := coll.FindByKey("b"); doc.Name != "kept" {
+		t.Fatalf("Expected the committed update to stay, got %v", doc)
+	}
+}
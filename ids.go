@@ -0,0 +1,256 @@
+package bingo
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/bwmarrin/snowflake"
+	"go.etcd.io/bbolt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeySetter is implemented by documents whose key can be set, Document implements it.
+// Generated keys are written back to documents through it, document types should implement it, see SetKey.
+type KeySetter interface {
+	SetKey(key []byte)
+}
+
+// SetKey sets the key of a document through KeySetter.
+// Documents that are not a KeySetter have their key written to their ID string field instead. This fallback only
+// keeps older document types working and is deprecated, it ignores how their Key method derives the key.
+func SetKey[T DocumentSpec](doc *T, key []byte) error {
+	if setter, ok := any(doc).(KeySetter); ok {
+		setter.SetKey(key)
+		return nil
+	}
+	id := reflect.ValueOf(doc).Elem()
+	if id.Kind() == reflect.Struct {
+		id = id.FieldByName("ID")
+	}
+	if !id.IsValid() || id.Kind() != reflect.String || !id.CanSet() {
+		return fmt.Errorf("%w: cannot set the key of %T", ErrInvalidDocumentType, *doc)
+	}
+	id.SetString(string(key))
+	return nil
+}
+
+// hasSettableKey reports whether the key of the documents of a type can be set, see SetKey.
+func hasSettableKey(typ reflect.Type) bool {
+	if reflect.PtrTo(typ).Implements(reflect.TypeOf((*KeySetter)(nil)).Elem()) {
+		return true
+	}
+	id, ok := typ.FieldByName("ID")
+	return ok && id.Type.Kind() == reflect.String
+}
+
+// nextSequence returns the next value of the sequence of a bucket.
+// Buckets written before their sequence was used start it past their largest numeric key, so keys left by
+// deleted documents do not make it collide with the stored ones.
+func nextSequence(bucket *bbolt.Bucket) (uint64, error) {
+	if bucket.Sequence() == 0 {
+		if n := largestNumericKey(bucket); n > 0 {
+			if err := bucket.SetSequence(n); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return bucket.NextSequence()
+}
+
+// largestNumericKey returns the largest key of a bucket that is a decimal number, 0 if it has none.
+func largestNumericKey(bucket *bbolt.Bucket) uint64 {
+	var largest uint64
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if n, err := strconv.ParseUint(string(k), 10, 64); err == nil && n > largest {
+			largest = n
+		}
+	}
+	return largest
+}
+
+var defaultSnowflake = struct {
+	sync.Mutex
+	node *snowflake.Node
+}{}
+
+// SetSnowflakeNode sets the node of the default "snowflake" key generator, which is 1 unless set.
+// Processes sharing a database file must use different nodes, node must be between 0 and 1023.
+func SetSnowflakeNode(node int64) error {
+	n, err := snowflake.NewNode(node)
+	if err != nil {
+		return err
+	}
+	defaultSnowflake.Lock()
+	defer defaultSnowflake.Unlock()
+	defaultSnowflake.node = n
+	return nil
+}
+
+func newSnowflakeId() []byte {
+	defaultSnowflake.Lock()
+	if defaultSnowflake.node == nil {
+		defaultSnowflake.node, _ = snowflake.NewNode(1)
+	}
+	node := defaultSnowflake.node
+	defaultSnowflake.Unlock()
+	return []byte(node.Generate().Base58())
+}
+
+// SnowflakeGenerator returns a generator of base58 snowflake keys for a node, to register with RegisterKeyGenerator.
+func SnowflakeGenerator(node int64) (KeyGenerator, error) {
+	n, err := snowflake.NewNode(node)
+	if err != nil {
+		return nil, err
+	}
+	return func(*bbolt.Bucket) ([]byte, error) {
+		return []byte(n.Generate().Base58()), nil
+	}, nil
+}
+
+// AutoIncrement returns a generator of increasing decimal keys backed by the sequence of the collection bucket, so keys
+// are never reused after deletes. Keys are padded with zeros to width digits, which keeps them sorted when width
+// is large enough. The "autoincrement" generator does not pad them.
+func AutoIncrement(width int) KeyGenerator {
+	return func(bucket *bbolt.Bucket) ([]byte, error) {
+		n, err := nextSequence(bucket)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("%0*d", width, n)), nil
+	}
+}
+
+// builtinKeyGenerators returns a new map of the generators registered under their name, see WithKeyGenerator.
+func builtinKeyGenerators() map[string]KeyGenerator {
+	return map[string]KeyGenerator{
+		DEFAULT_KEY_GENERATOR: func(*bbolt.Bucket) ([]byte, error) {
+			return newSnowflakeId(), nil
+		},
+		"autoincrement": AutoIncrement(0),
+		"uuid":          newUUIDv4,
+		"uuidv7":        newUUIDv7,
+		"ulid":          newULID,
+		"ksuid":         newKSUID,
+	}
+}
+
+func formatUUID(u []byte) []byte {
+	s := hex.EncodeToString(u)
+	return []byte(s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:])
+}
+
+func newUUIDv4(*bbolt.Bucket) ([]byte, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return nil, err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u[:]), nil
+}
+
+// monotonic keeps the keys generated in the same millisecond increasing.
+var monotonic = struct {
+	sync.Mutex
+	uuidMs  int64
+	uuidSeq uint16
+	ulidMs  int64
+	ulid    [10]byte
+}{}
+
+// newUUIDv7 generates a time ordered UUID, keys of the same millisecond are ordered by a 12 bit counter.
+func newUUIDv7(*bbolt.Bucket) ([]byte, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return nil, err
+	}
+	monotonic.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= monotonic.uuidMs {
+		ms = monotonic.uuidMs
+		monotonic.uuidSeq += 1
+		if monotonic.uuidSeq > 0xfff {
+			ms += 1
+			monotonic.uuidSeq = 0
+		}
+	} else {
+		monotonic.uuidSeq = 0
+	}
+	monotonic.uuidMs = ms
+	seq := monotonic.uuidSeq
+	monotonic.Unlock()
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(ms))
+	copy(u[0:6], ts[2:])
+	u[6] = 0x70 | byte(seq>>8)
+	u[7] = byte(seq)
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u[:]), nil
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID generates a ULID, keys of the same millisecond increment the random part of the previous one.
+func newULID(*bbolt.Bucket) ([]byte, error) {
+	var random [10]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return nil, err
+	}
+	monotonic.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= monotonic.ulidMs {
+		ms = monotonic.ulidMs
+		random = monotonic.ulid
+		for i := len(random) - 1; i >= 0; i-- {
+			random[i] += 1
+			if random[i] != 0 {
+				break
+			}
+		}
+	}
+	monotonic.ulidMs = ms
+	monotonic.ulid = random
+	monotonic.Unlock()
+
+	var u [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(ms))
+	copy(u[0:6], ts[2:])
+	copy(u[6:], random[:])
+	return encodeBase(u[:], crockford, 26), nil
+}
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ksuidEpoch is the epoch of KSUID timestamps, 2014-05-13.
+const ksuidEpoch = 1400000000
+
+// newKSUID generates a KSUID, a 32 bit timestamp in seconds followed by 128 random bits.
+func newKSUID(*bbolt.Bucket) ([]byte, error) {
+	var k [20]byte
+	binary.BigEndian.PutUint32(k[:4], uint32(time.Now().Unix()-ksuidEpoch))
+	if _, err := rand.Read(k[4:]); err != nil {
+		return nil, err
+	}
+	return encodeBase(k[:], base62, 27), nil
+}
+
+// encodeBase encodes bytes as a number in the base of an alphabet, padded to width digits.
+func encodeBase(b []byte, alphabet string, width int) []byte {
+	n := new(big.Int).SetBytes(b)
+	base := big.NewInt(int64(len(alphabet)))
+	digit := new(big.Int)
+	out := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		out[i] = alphabet[digit.Int64()]
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package bingo_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"os"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+type Coded struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func (c Coded) Key() []byte {
+	return []byte(c.Code)
+}
+
+func (c *Coded) SetKey(key []byte) {
+	c.Code = string(key)
+}
+
+func TestKeyGenerators(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testids.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testids.db")
+	}()
+
+	node, err := bingo.SnowflakeGenerator(7)
+	if err != nil {
+		t.Fatalf("Failed to create snowflake generator: %v", err)
+	}
+	bingo.RegisterKeyGenerator("snowflake-7", node)
+	if _, err := bingo.SnowflakeGenerator(5000); err == nil {
+		t.Fatalf("Expected an out of range snowflake node to fail")
+	}
+
+	formats := map[string]string{
+		"snowflake":     `^[1-9a-km-zA-HJ-NP-Z]+$`,
+		"snowflake-7":   `^[1-9a-km-zA-HJ-NP-Z]+$`,
+		"autoincrement": `^[0-9]+$`,
+		"uuid":          `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		"uuidv7":        `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		"ulid":          `^[0-9A-HJKMNP-TV-Z]{26}$`,
+		"ksuid":         `^[0-9A-Za-z]{27}$`,
+	}
+	ordered := map[string]bool{"uuidv7": true, "ulid": true}
+	for name, format := range formats {
+		coll, err := bingo.OpenCollection[TestDocument](driver, "ids-"+name, bingo.WithKeyGenerator(name))
+		if err != nil {
+			t.Fatalf("Failed to open collection with %s: %v", name, err)
+		}
+		var keys []string
+		for i := 0; i < 50; i++ {
+			key, err := coll.Insert(TestDocument{Name: fmt.Sprint(i)})
+			if err != nil {
+				t.Fatalf("Failed to insert with %s: %v", name, err)
+			}
+			if !regexp.MustCompile(format).Match(key) {
+				t.Fatalf("Expected %s keys to match %s, got %s", name, format, key)
+			}
+			keys = append(keys, string(key))
+		}
+		if ordered[name] && !sort.StringsAreSorted(keys) {
+			t.Fatalf("Expected %s keys to be ordered, got %v", name, keys)
+		}
+		if doc, err := coll.FindByKey(keys[0]); err != nil || doc.ID != keys[0] {
+			t.Fatalf("Expected the %s key to be written to the document, got %v %v", name, doc, err)
+		}
+	}
+
+	// sequences are never reused after deletes
+	counter := bingo.CollectionFrom[TestDocument](driver, "ids-autoincrement")
+	if err := counter.DeleteOne(TestDocument{Document: bingo.Document{ID: "50"}}); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if key, err := counter.Insert(TestDocument{Name: "next"}); err != nil || string(key) != "51" {
+		t.Fatalf("Expected the next key to be 51, got %s %v", key, err)
+	}
+	counter.OnNewId = func(count int, doc *TestDocument) []byte {
+		return []byte(fmt.Sprintf("doc-%d", count))
+	}
+	if key, err := counter.Insert(TestDocument{Name: "custom"}); err != nil || string(key) != "doc-51" {
+		t.Fatalf("Expected OnNewId to follow the sequence, got %s %v", key, err)
+	}
+
+	// sequences of collections written with their own keys start past the largest numeric key
+	seeded := bingo.CollectionFrom[TestDocument](driver, "ids-seeded")
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := seeded.Insert(TestDocument{Document: bingo.Document{ID: id}, Name: id}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+	if err := seeded.DeleteOne(TestDocument{Document: bingo.Document{ID: "1"}}); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	seeded, err = bingo.OpenCollection[TestDocument](driver, "ids-seeded", bingo.WithKeyGenerator("autoincrement"))
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	if key, err := seeded.Insert(TestDocument{Name: "next"}); err != nil || string(key) != "4" {
+		t.Fatalf("Expected the next key to be 4, got %s %v", key, err)
+	}
+	if doc, err := seeded.FindByKey("3"); err != nil || doc.Name != "3" {
+		t.Fatalf("Expected document 3 to be kept, got %v %v", doc, err)
+	}
+
+	// generated keys never overwrite stored documents
+	seeded.OnNewId = func(int, *TestDocument) []byte {
+		return []byte("3")
+	}
+	if _, err := seeded.Insert(TestDocument{Name: "clash"}, bingo.Upsert); !errors.Is(err, bingo.ErrDocumentExists) {
+		t.Fatalf("Expected a generated key clash to fail, got %v", err)
+	}
+	dynamic := driver.Dynamic("ids-seeded")
+	dynamic.OnNewId = func(int, map[string]any) []byte {
+		return []byte("3")
+	}
+	if _, err := dynamic.Insert(map[string]any{"name": "clash"}); !errors.Is(err, bingo.ErrDocumentExists) {
+		t.Fatalf("Expected a generated dynamic key clash to fail, got %v", err)
+	}
+
+	// keys are written back through KeySetter, documents do not need an ID field
+	coded, err := bingo.OpenCollection[Coded](driver, "coded", bingo.WithKeyGenerator("autoincrement"))
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	if _, err := coded.Insert(Coded{Name: "first"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	if doc, err := coded.FindByKey("1"); err != nil || doc.Code != "1" || doc.Name != "first" {
+		t.Fatalf("Expected the key to be set through SetKey, got %v %v", doc, err)
+	}
+}
@@ -0,0 +1,228 @@
+package bingo
+
+import (
+	"bytes"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"reflect"
+)
+
+const INDEX_COLLECTION_NAME = "__index:"
+
+// indexBucket returns the name of the bucket holding the index of a field.
+// Index entries are keyed by the JSON encoded field value, a zero byte and the document key.
+func indexBucket(collection, field string) []byte {
+	return []byte(INDEX_COLLECTION_NAME + collection + ":" + field)
+}
+
+func indexEntry(value, key []byte) []byte {
+	entry := make([]byte, 0, len(value)+1+len(key))
+	entry = append(entry, value...)
+	entry = append(entry, 0)
+	return append(entry, key...)
+}
+
+// splitEntry splits an index entry into its JSON encoded value and document key, JSON never holds a zero byte.
+func splitEntry(entry []byte) (value, key []byte, ok bool) {
+	i := bytes.IndexByte(entry, 0)
+	if i < 0 {
+		return nil, nil, false
+	}
+	return entry[:i], entry[i+1:], true
+}
+
+// indexValues returns the JSON encoded values of the indexed fields of a document, missing fields are left out.
+func indexValues(doc any, fields []string) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	if doc == nil || len(fields) == 0 {
+		return values, nil
+	}
+	data, err := Marshaller.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := Unmarshaller.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		value, ok := LookupPath(m, field)
+		if !ok || value == nil {
+			continue
+		}
+		encoded, err := Marshaller.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		values[field] = encoded
+	}
+	return values, nil
+}
+
+// reindex replaces the index entries of the stored document old by the ones of doc, either may be nil.
+func reindex(tx *bbolt.Tx, collection string, fields []string, key []byte, old, doc any) error {
+	before, err := indexValues(old, fields)
+	if err != nil {
+		return err
+	}
+	after, err := indexValues(doc, fields)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if bytes.Equal(before[field], after[field]) {
+			continue
+		}
+		bucket, err := tx.CreateBucketIfNotExists(indexBucket(collection, field))
+		if err != nil {
+			return err
+		}
+		if value, ok := before[field]; ok {
+			if err := bucket.Delete(indexEntry(value, key)); err != nil {
+				return err
+			}
+		}
+		if value, ok := after[field]; ok {
+			if err := bucket.Put(indexEntry(value, key), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkEntries returns the error bbolt would fail to store a document with, checking its key, its encoded value and
+// its index and reference index entries before any of them is written, so a failing write leaves no stale entries.
+func checkEntries(indexes []string, refs []Reference, key, data []byte, doc any) error {
+	if err := checkPut(key, data); err != nil {
+		return err
+	}
+	values, err := indexValues(doc, indexes)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if err := checkPut(indexEntry(value, key), nil); err != nil {
+			return err
+		}
+	}
+	referenced, err := refValues(doc, refs)
+	if err != nil {
+		return err
+	}
+	for _, keys := range referenced {
+		for _, value := range keys {
+			if err := checkPut(indexEntry(value, key), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkPut returns the error bbolt.Bucket.Put fails with for a key and value.
+func checkPut(key, value []byte) error {
+	switch {
+	case len(key) == 0:
+		return bbolt.ErrKeyRequired
+	case len(key) > bbolt.MaxKeySize:
+		return bbolt.ErrKeyTooLarge
+	case int64(len(value)) > bbolt.MaxValueSize:
+		return bbolt.ErrValueTooLarge
+	}
+	return nil
+}
+
+// indexedKeys returns the keys of the documents whose field is stored with the value.
+func indexedKeys(tx *bbolt.Tx, collection, field string, value any) ([][]byte, error) {
+	encoded, err := Marshaller.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	bucket := tx.Bucket(indexBucket(collection, field))
+	if bucket == nil {
+		return nil, nil
+	}
+	return entriesWithValue(bucket, encoded), nil
+}
+
+// entriesWithValue returns the document keys of the entries of an index bucket with a JSON encoded value.
+func entriesWithValue(bucket *bbolt.Bucket, encoded []byte) [][]byte {
+	prefix := indexEntry(encoded, nil)
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte{}, k[len(prefix):]...))
+	}
+	return keys
+}
+
+// updateIndexes builds the indexes added to a collection and drops the removed ones.
+func (d *Driver) updateIndexes(name string, codec Codec, typ reflect.Type, previous, current []string) error {
+	var added []string
+	var dropped []string
+	for _, field := range current {
+		if !contains(previous, field) {
+			added = append(added, field)
+		}
+	}
+	for _, field := range previous {
+		if !contains(current, field) {
+			dropped = append(dropped, field)
+		}
+	}
+	if len(added) == 0 && len(dropped) == 0 {
+		return nil
+	}
+	return d.update(func(tx *bbolt.Tx) error {
+		for _, field := range dropped {
+			if err := tx.DeleteBucket(indexBucket(name, field)); err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		for _, field := range added {
+			if _, err := tx.CreateBucketIfNotExists(indexBucket(name, field)); err != nil {
+				return err
+			}
+		}
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil || len(added) == 0 {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			doc := reflect.New(typ)
+			if err := codec.Unmarshal(v, doc.Interface()); err != nil {
+				// corrupt documents are left out of the index, see Driver.Check
+				return nil
+			}
+			return reindex(tx, name, added, k, nil, doc.Interface())
+		})
+	})
+}
+
+// FindByIndex returns the documents whose indexed field is stored with the value, see WithIndex.
+// An error wrapping ErrNotIndexed is returned if the field is not indexed.
+func (c *Collection[T]) FindByIndex(field string, value any) ([]T, error) {
+	if !contains(c.options.Indexes, field) {
+		return nil, fmt.Errorf("%w: %s of %s", ErrNotIndexed, field, c.Name)
+	}
+	var documents []T
+	err := c.around("FindByIndex", func() error {
+		var corrupt []CorruptDocument
+		var failed error
+		err := c.Driver.view(func(tx *bbolt.Tx) error {
+			keys, err := indexedKeys(tx, c.Name, field, value)
+			if err != nil {
+				return err
+			}
+			documents, _, failed = c.readKeys(tx, keys, &corrupt)
+			return nil
+		})
+		c.handleCorrupt(corrupt)
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	return documents, err
+}
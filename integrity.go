@@ -0,0 +1,441 @@
+package bingo
+
+import (
+	"bytes"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"reflect"
+	"sort"
+)
+
+const REFS_COLLECTION_NAME = "__refs:"
+
+// RefPolicy is what deleting a referenced document does to the documents referencing it.
+type RefPolicy string
+
+const (
+	// RefRestrict fails the deletion with a *ReferenceError, it is the default policy.
+	RefRestrict RefPolicy = "restrict"
+	// RefCascade deletes the referencing documents along with the referenced one.
+	RefCascade RefPolicy = "cascade"
+	// RefSetNull clears the reference, references held in a list are removed from it.
+	// Referencing documents stored with a custom codec are rewritten through a map[string]any, which the codec must support.
+	RefSetNull RefPolicy = "setnull"
+)
+
+func (p RefPolicy) valid() bool {
+	return p == RefRestrict || p == RefCascade || p == RefSetNull
+}
+
+// Reference declares that the stored field Path of a collection holds the keys of documents of Collection,
+// as a string or a list of strings. OnDelete is applied when one of these documents is deleted.
+// Tagged references come from the bingo tags of Ref fields and follow the document type.
+type Reference struct {
+	Path       string
+	Collection string
+	OnDelete   RefPolicy
+	Tagged     bool
+}
+
+// WithReference declares that the stored field path, such as "AuthorID", holds keys of the documents of collection.
+// It is how plain string fields get a policy, and it overrides the policy of a Ref field tag for the same path.
+// Existing documents are indexed when the collection is opened.
+func WithReference(path, collection string, onDelete RefPolicy) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		opts.References = append(withoutReference(opts.References, path), Reference{Path: path, Collection: collection, OnDelete: onDelete})
+	}
+}
+
+// WithoutReference drops the references declared with WithReference for stored field paths.
+func WithoutReference(paths ...string) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		for _, path := range paths {
+			opts.References = withoutReference(opts.References, path)
+		}
+	}
+}
+
+func withoutReference(refs []Reference, path string) []Reference {
+	var kept []Reference
+	for _, ref := range refs {
+		if ref.Path != path {
+			kept = append(kept, ref)
+		}
+	}
+	return kept
+}
+
+// declaredReferences replaces the tagged references of refs by the Ref fields of a document type,
+// references declared with WithReference take precedence.
+func declaredReferences(refs []Reference, typ reflect.Type) ([]Reference, error) {
+	var declared []Reference
+	for _, ref := range refs {
+		if !ref.Tagged {
+			declared = append(declared, ref)
+		}
+	}
+	fields, err := refFieldsOf(typ)
+	if err != nil {
+		return nil, err
+	}
+	tagged := make([]refField, 0, len(fields))
+	for _, field := range fields {
+		tagged = append(tagged, field)
+	}
+	// fields are kept in declaration order so the persisted options stay stable
+	sort.Slice(tagged, func(i, j int) bool {
+		return lessIndex(tagged[i].index, tagged[j].index)
+	})
+	for _, field := range tagged {
+		if !referenceAt(declared, field.path) {
+			declared = append(declared, Reference{Path: field.path, Collection: field.collection, OnDelete: field.onDelete, Tagged: true})
+		}
+	}
+	for _, ref := range declared {
+		if ref.Path == "" || ref.Collection == "" || !ref.OnDelete.valid() {
+			return nil, fmt.Errorf("%w: invalid reference %+v", ErrInvalidOptions, ref)
+		}
+	}
+	return declared, nil
+}
+
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func referenceAt(refs []Reference, path string) bool {
+	for _, ref := range refs {
+		if ref.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func referencePaths(refs []Reference) []string {
+	paths := make([]string, len(refs))
+	for i, ref := range refs {
+		paths[i] = ref.Path
+	}
+	return paths
+}
+
+// refIndexBucket returns the name of the bucket indexing the documents by the keys they reference at a path.
+// Entries are keyed like the field indexes, by the JSON encoded referenced key, a zero byte and the document key.
+func refIndexBucket(collection, path string) []byte {
+	return []byte(REFS_COLLECTION_NAME + collection + ":" + path)
+}
+
+// refValues returns the JSON encoded keys a document references at every path, one per element of a list.
+func refValues(doc any, refs []Reference) (map[string][][]byte, error) {
+	values, err := indexValues(doc, referencePaths(refs))
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string][][]byte{}
+	for path, value := range values {
+		var held any
+		if err := Unmarshaller.Unmarshal(value, &held); err != nil {
+			return nil, err
+		}
+		list, ok := held.([]any)
+		if !ok {
+			list = []any{held}
+		}
+		for _, element := range list {
+			if key, ok := element.(string); ok && key != "" {
+				encoded, err := Marshaller.Marshal(key)
+				if err != nil {
+					return nil, err
+				}
+				keys[path] = append(keys[path], encoded)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// reindexReferences replaces the reference index entries of the stored document old by the ones of doc, either may be nil.
+func reindexReferences(tx *bbolt.Tx, collection string, refs []Reference, key []byte, old, doc any) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	before, err := refValues(old, refs)
+	if err != nil {
+		return err
+	}
+	after, err := refValues(doc, refs)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		bucket, err := tx.CreateBucketIfNotExists(refIndexBucket(collection, ref.Path))
+		if err != nil {
+			return err
+		}
+		for _, value := range before[ref.Path] {
+			if err := bucket.Delete(indexEntry(value, key)); err != nil {
+				return err
+			}
+		}
+		for _, value := range after[ref.Path] {
+			if err := bucket.Put(indexEntry(value, key), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateReferences builds the reference indexes of the references added to a collection and drops the removed ones.
+func (d *Driver) updateReferences(name string, codec Codec, previous, current []Reference) error {
+	var added []Reference
+	var dropped []string
+	for _, ref := range current {
+		if !referenceAt(previous, ref.Path) {
+			added = append(added, ref)
+		}
+	}
+	for _, ref := range previous {
+		if !referenceAt(current, ref.Path) {
+			dropped = append(dropped, ref.Path)
+		}
+	}
+	if len(added) == 0 && len(dropped) == 0 {
+		return nil
+	}
+	return d.update(func(tx *bbolt.Tx) error {
+		for _, path := range dropped {
+			if err := tx.DeleteBucket(refIndexBucket(name, path)); err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		for _, ref := range added {
+			if _, err := tx.CreateBucketIfNotExists(refIndexBucket(name, ref.Path)); err != nil {
+				return err
+			}
+		}
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil || len(added) == 0 {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var doc map[string]any
+			if err := codec.Unmarshal(v, &doc); err != nil {
+				// corrupt documents are left out of the index, see Driver.Check
+				return nil
+			}
+			return reindexReferences(tx, name, added, k, nil, doc)
+		})
+	})
+}
+
+// referrer is a reference declared by a collection, with the options the collection was opened with.
+type referrer struct {
+	collection string
+	options    CollectionOptions
+	Reference
+}
+
+// referrersOf returns the references to a collection. They are read from the persisted options of every collection
+// once and cached on the driver until the metadata changes, see forgetReferrers.
+func (d *Driver) referrersOf(tx *bbolt.Tx, collection string) ([]referrer, error) {
+	d.mu.RLock()
+	referrers := d.referrers
+	d.mu.RUnlock()
+	if referrers == nil {
+		var err error
+		if referrers, err = readReferrers(tx); err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		d.referrers = referrers
+		d.mu.Unlock()
+	}
+	return referrers[collection], nil
+}
+
+// forgetReferrers drops the cached references, it is called whenever the metadata may have changed.
+func (d *Driver) forgetReferrers() {
+	d.mu.Lock()
+	d.referrers = nil
+	d.mu.Unlock()
+}
+
+// readReferrers returns the references to every collection by referenced collection, read from the persisted options in tx.
+func readReferrers(tx *bbolt.Tx) (map[string][]referrer, error) {
+	referrers := map[string][]referrer{}
+	meta := tx.Bucket([]byte(METADATA_COLLECTION_NAME))
+	if meta == nil {
+		return referrers, nil
+	}
+	prefix := []byte(OPTIONS_COLLECTION_NAME)
+	c := meta.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var entry struct {
+			V CollectionOptions
+		}
+		if err := Unmarshaller.Unmarshal(v, &entry); err != nil {
+			return nil, fmt.Errorf("unable to read the options of %s: %w", k[len(prefix):], err)
+		}
+		for _, ref := range entry.V.References {
+			referrers[ref.Collection] = append(referrers[ref.Collection], referrer{collection: string(k[len(prefix):]), options: entry.V, Reference: ref})
+		}
+	}
+	return referrers, nil
+}
+
+// referencingKeys returns the keys of the documents of a referrer referencing key.
+func (r referrer) referencingKeys(tx *bbolt.Tx, key []byte) ([][]byte, error) {
+	bucket := tx.Bucket(refIndexBucket(r.collection, r.Path))
+	if bucket == nil {
+		return nil, nil
+	}
+	encoded, err := Marshaller.Marshal(string(key))
+	if err != nil {
+		return nil, err
+	}
+	return entriesWithValue(bucket, encoded), nil
+}
+
+// enforceReferences applies the policies of the references to a document about to be deleted.
+// visited holds the documents already deleted by the same deletion, so reference cycles end.
+func (d *Driver) enforceReferences(tx *bbolt.Tx, collection string, key []byte, visited map[string]bool) error {
+	visited[collection+"\x00"+string(key)] = true
+	referrers, err := d.referrersOf(tx, collection)
+	if err != nil {
+		return err
+	}
+	for _, r := range referrers {
+		keys, err := r.referencingKeys(tx, key)
+		if err != nil {
+			return err
+		}
+		var pending [][]byte
+		for _, k := range keys {
+			if !visited[r.collection+"\x00"+string(k)] {
+				pending = append(pending, k)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		switch r.OnDelete {
+		case RefCascade:
+			for _, k := range pending {
+				if err := r.remove(d, tx, k, visited); err != nil {
+					return err
+				}
+			}
+		case RefSetNull:
+			for _, k := range pending {
+				if err := r.clear(tx, k, key); err != nil {
+					return err
+				}
+			}
+		default:
+			return &ReferenceError{Collection: collection, Key: append([]byte{}, key...), Referrer: r.collection, Path: r.Path, Keys: pending}
+		}
+	}
+	return nil
+}
+
+// remove deletes a referencing document of a cascading reference, applying the references to it first.
+// The hooks of the referencing collection are not run.
+func (r referrer) remove(d *Driver, tx *bbolt.Tx, key []byte, visited map[string]bool) error {
+	if visited[r.collection+"\x00"+string(key)] {
+		return nil
+	}
+	if err := d.enforceReferences(tx, r.collection, key, visited); err != nil {
+		return err
+	}
+	bucket := tx.Bucket([]byte(r.collection))
+	if bucket == nil {
+		return nil
+	}
+	value := bucket.Get(key)
+	if value == nil {
+		return nil
+	}
+	codec, err := codecNamed(r.options.Codec)
+	if err != nil {
+		return err
+	}
+	old, err := decodeStored(codec, value)
+	if err != nil {
+		return CorruptDocument{Collection: r.collection, Key: append([]byte{}, key...), Value: append([]byte{}, value...), Err: err}
+	}
+	return removeStored(tx, r.collection, &r.options, bucket, key, old, r.options.SoftDelete)
+}
+
+// clear removes the reference to target from a referencing document of a set-null reference.
+// The hooks of the referencing collection are not run.
+func (r referrer) clear(tx *bbolt.Tx, key, target []byte) error {
+	bucket := tx.Bucket([]byte(r.collection))
+	if bucket == nil {
+		return nil
+	}
+	value := bucket.Get(key)
+	if value == nil {
+		return nil
+	}
+	codec, err := codecNamed(r.options.Codec)
+	if err != nil {
+		return err
+	}
+	old, err := decodeStored(codec, value)
+	if err != nil {
+		return CorruptDocument{Collection: r.collection, Key: append([]byte{}, key...), Value: append([]byte{}, value...), Err: err}
+	}
+	doc, err := decodeStored(codec, value)
+	if err != nil {
+		return err
+	}
+	held, _ := LookupPath(doc, r.Path)
+	if list, ok := held.([]any); ok {
+		kept := []any{}
+		for _, element := range list {
+			if element != string(target) {
+				kept = append(kept, element)
+			}
+		}
+		SetPath(doc, r.Path, kept)
+	} else {
+		SetPath(doc, r.Path, nil)
+	}
+	data, err := codec.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := reindex(tx, r.collection, r.options.Indexes, key, old, doc); err != nil {
+		return err
+	}
+	if err := reindexReferences(tx, r.collection, r.options.References, key, old, doc); err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// decodeStored decodes a stored document of a collection that may not be opened by this process.
+// JSON documents keep their numbers as json.Number, so they are written back unchanged, documents of other codecs
+// are decoded into a map by the codec.
+func decodeStored(codec Codec, value []byte) (map[string]any, error) {
+	var doc map[string]any
+	if _, ok := codec.(jsonCodec); !ok {
+		if err := codec.Unmarshal(value, &doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
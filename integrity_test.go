@@ -0,0 +1,293 @@
+package bingo_test
+
+import (
+	"errors"
+	"github.com/nokusukun/bingo"
+	"os"
+	"testing"
+)
+
+type Member struct {
+	bingo.Document
+	Name string `json:"name"`
+}
+
+type Thread struct {
+	bingo.Document
+	Title     string              `json:"title"`
+	Author    bingo.Ref[Member]   `json:"author" bingo:"ref=members,onDelete=cascade"`
+	Moderator bingo.Ref[Member]   `json:"moderator" bingo:"ref=members"`
+	Watchers  []bingo.Ref[Member] `json:"watchers" bingo:"ref=members,onDelete=setnull"`
+}
+
+type Reply struct {
+	bingo.Document
+	ThreadID string `json:"threadId"`
+	Text     string `json:"text"`
+}
+
+type Badge struct {
+	bingo.Document
+	Owner  bingo.Ref[Member] `json:"owner" bingo:"ref=members,onDelete=setnull"`
+	Big    int64             `json:"big"`
+	Label  string            `json:"label"`
+	Scores []float64         `json:"scores"`
+}
+
+type BadPolicy struct {
+	bingo.Document
+	Author bingo.Ref[Member] `json:"author" bingo:"ref=members,onDelete=ignore"`
+}
+
+func TestReferentialIntegrity(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testintegrity.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testintegrity.db")
+	}()
+
+	if _, err := bingo.OpenCollection[BadPolicy](driver, "badpolicy"); !errors.Is(err, bingo.ErrInvalidDocumentType) {
+		t.Fatalf("Expected an unknown policy to be rejected, got %v", err)
+	}
+
+	members := bingo.CollectionFrom[Member](driver, "members")
+	threads := bingo.CollectionFrom[Thread](driver, "threads")
+	replies, err := bingo.OpenCollection[Reply](driver, "replies", bingo.WithReference("ThreadID", "threads", bingo.RefCascade))
+	if err != nil {
+		t.Fatalf("Failed to open replies: %v", err)
+	}
+
+	var people []Member
+	for _, name := range []string{"ann", "bob", "cat", "dan", "eve"} {
+		people = append(people, Member{Document: bingo.Document{ID: name}, Name: name})
+	}
+	if _, err := members.InsertMany(people); err != nil {
+		t.Fatalf("Failed to insert members: %v", err)
+	}
+	ann, bob, cat, dan, eve := people[0], people[1], people[2], people[3], people[4]
+	_, err = threads.InsertMany([]Thread{
+		{Document: bingo.Document{ID: "t1"}, Title: "One", Author: bingo.RefTo(ann), Moderator: bingo.RefTo(bob), Watchers: []bingo.Ref[Member]{bingo.RefTo(cat), bingo.RefTo(dan)}},
+		{Document: bingo.Document{ID: "t2"}, Title: "Two", Author: bingo.RefTo(cat), Watchers: []bingo.Ref[Member]{bingo.RefTo(ann)}},
+		{Document: bingo.Document{ID: "t3"}, Title: "Three", Author: bingo.RefTo(dan)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert threads: %v", err)
+	}
+	_, err = replies.InsertMany([]Reply{
+		{Document: bingo.Document{ID: "r1"}, ThreadID: "t1", Text: "first"},
+		{Document: bingo.Document{ID: "r2"}, ThreadID: "t1", Text: "second"},
+		{Document: bingo.Document{ID: "r3"}, ThreadID: "t3", Text: "third"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert replies: %v", err)
+	}
+
+	// restrict fails the whole deletion
+	err = members.DeleteOne(bob)
+	var refErr *bingo.ReferenceError
+	if !bingo.IsErrReferenced(err) || !errors.As(err, &refErr) || refErr.Referrer != "threads" || refErr.Path != "Moderator" ||
+		len(refErr.Keys) != 1 || string(refErr.Keys[0]) != "t1" {
+		t.Fatalf("Expected bob to be restricted by t1, got %v", err)
+	}
+	if _, err := members.FindByKey("bob"); err != nil {
+		t.Fatalf("Expected bob to remain, got %v", err)
+	}
+
+	// cascade deletes the threads cat wrote, set-null removes cat from the watchers
+	if err := members.DeleteOne(cat); err != nil {
+		t.Fatalf("Failed to delete cat: %v", err)
+	}
+	if _, err := threads.FindByKey("t2"); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected t2 to be deleted along with cat, got %v", err)
+	}
+	t1, err := threads.FindByKey("t1")
+	if err != nil || len(t1.Watchers) != 1 || t1.Watchers[0].Key != "dan" || t1.Author.Key != "ann" {
+		t.Fatalf("Expected cat to be removed from the watchers of t1, got %+v %v", t1, err)
+	}
+
+	// a restricted document anywhere in the cascade fails the whole deletion
+	bookmarks, err := bingo.OpenCollection[Reply](driver, "bookmarks", bingo.WithReference("ThreadID", "threads", bingo.RefRestrict))
+	if err != nil {
+		t.Fatalf("Failed to open bookmarks: %v", err)
+	}
+	if _, err := bookmarks.Insert(Reply{Document: bingo.Document{ID: "b1"}, ThreadID: "t3"}); err != nil {
+		t.Fatalf("Failed to insert bookmark: %v", err)
+	}
+	err = members.DeleteIter(func(m *Member) bool { return m.ID == "dan" })
+	if !errors.As(err, &refErr) || refErr.Collection != "threads" || refErr.Referrer != "bookmarks" {
+		t.Fatalf("Expected t3 to be restricted by the bookmark, got %v", err)
+	}
+	if _, err := members.FindByKey("dan"); err != nil {
+		t.Fatalf("Expected dan to remain, got %v", err)
+	}
+	if _, err := replies.FindByKey("r3"); err != nil {
+		t.Fatalf("Expected r3 to remain, got %v", err)
+	}
+	if err := bookmarks.DeleteOne(Reply{Document: bingo.Document{ID: "b1"}}); err != nil {
+		t.Fatalf("Failed to delete bookmark: %v", err)
+	}
+
+	// cascades go through every level
+	if err := members.DeleteIter(func(m *Member) bool { return m.ID == "dan" }); err != nil {
+		t.Fatalf("Failed to delete dan: %v", err)
+	}
+	if _, err := threads.FindByKey("t3"); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected t3 to be deleted along with dan, got %v", err)
+	}
+	if _, err := replies.FindByKey("r3"); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected r3 to be deleted along with t3, got %v", err)
+	}
+	if t1, _ := threads.FindByKey("t1"); len(t1.Watchers) != 0 {
+		t.Fatalf("Expected dan to be removed from the watchers of t1, got %+v", t1.Watchers)
+	}
+
+	result := members.Query(bingo.Query[Member]{Filter: func(m Member) bool { return m.ID == "ann" }})
+	if err := result.Delete(); err != nil {
+		t.Fatalf("Failed to delete ann: %v", err)
+	}
+	if count := len(threads.FindByKeys("t1")) + len(replies.FindByKeys("r1", "r2")); count != 0 {
+		t.Fatalf("Expected t1 and its replies to be deleted along with ann, %d remain", count)
+	}
+
+	// the thread moderated by bob is gone, nothing references bob anymore
+	if err := members.DeleteOne(bob); err != nil {
+		t.Fatalf("Failed to delete bob: %v", err)
+	}
+	if err := members.DeleteOne(eve); err != nil {
+		t.Fatalf("Failed to delete eve: %v", err)
+	}
+}
+
+func TestSetNullKeepsFields(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testsetnull.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testsetnull.db")
+	}()
+
+	members := bingo.CollectionFrom[Member](driver, "members")
+	badges := bingo.CollectionFrom[Badge](driver, "badges")
+	owner := Member{Document: bingo.Document{ID: "ann"}, Name: "ann"}
+	if _, err := members.Insert(owner); err != nil {
+		t.Fatalf("Failed to insert member: %v", err)
+	}
+	badge := Badge{Document: bingo.Document{ID: "b1"}, Owner: bingo.RefTo(owner), Big: 1<<60 + 1, Label: "gold", Scores: []float64{0.1, 2}}
+	if _, err := badges.Insert(badge); err != nil {
+		t.Fatalf("Failed to insert badge: %v", err)
+	}
+	if err := members.DeleteOne(owner); err != nil {
+		t.Fatalf("Failed to delete member: %v", err)
+	}
+	stored, err := badges.FindByKey("b1")
+	if err != nil {
+		t.Fatalf("Failed to find badge: %v", err)
+	}
+	if stored.Owner.Key != "" || stored.Big != 1<<60+1 || stored.Label != "gold" || len(stored.Scores) != 2 || stored.Scores[0] != 0.1 {
+		t.Fatalf("Expected only the owner of the badge to be cleared, got %+v", stored)
+	}
+}
+
+func TestReferencesOpenedLater(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testreflater.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testreflater.db")
+	}()
+
+	members := bingo.CollectionFrom[Member](driver, "members")
+	ann := Member{Document: bingo.Document{ID: "ann"}, Name: "ann"}
+	bob := Member{Document: bingo.Document{ID: "bob"}, Name: "bob"}
+	if _, err := members.InsertMany([]Member{ann, bob}); err != nil {
+		t.Fatalf("Failed to insert members: %v", err)
+	}
+	// no collection references members yet
+	if err := members.DeleteOne(ann); err != nil {
+		t.Fatalf("Failed to delete ann: %v", err)
+	}
+
+	threads := bingo.CollectionFrom[Thread](driver, "threads")
+	if _, err := threads.Insert(Thread{Document: bingo.Document{ID: "t1"}, Title: "moderated", Moderator: bingo.RefTo(bob)}); err != nil {
+		t.Fatalf("Failed to insert thread: %v", err)
+	}
+	if err := members.DeleteOne(bob); !bingo.IsErrReferenced(err) {
+		t.Fatalf("Expected references of a collection opened later to be enforced, got %v", err)
+	}
+}
+
+func TestDynamicReferences(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testdynamicrefs.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testdynamicrefs.db")
+	}()
+
+	members := bingo.CollectionFrom[Member](driver, "members")
+	threads := bingo.CollectionFrom[Thread](driver, "threads")
+	ann, bob := Member{Document: bingo.Document{ID: "ann"}}, Member{Document: bingo.Document{ID: "bob"}}
+	if _, err := members.InsertMany([]Member{ann, bob}); err != nil {
+		t.Fatalf("Failed to insert members: %v", err)
+	}
+	_, err = threads.InsertMany([]Thread{
+		{Document: bingo.Document{ID: "t1"}, Author: bingo.RefTo(ann), Moderator: bingo.RefTo(bob)},
+		{Document: bingo.Document{ID: "t2"}, Author: bingo.RefTo(bob)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert threads: %v", err)
+	}
+
+	// dynamic deletes apply the policies of the references declared by typed collections
+	if err := driver.Dynamic("members").DeleteByKey("bob"); !bingo.IsErrReferenced(err) {
+		t.Fatalf("Expected bob to be restricted by t1, got %v", err)
+	}
+	if err := driver.Dynamic("threads").DeleteByKey("t1"); err != nil {
+		t.Fatalf("Failed to delete t1: %v", err)
+	}
+	if err := driver.Dynamic("members").DeleteByKey("bob"); err != nil {
+		t.Fatalf("Expected the references of t1 to be removed along with it, got %v", err)
+	}
+	if _, err := threads.FindByKey("t2"); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected t2 to be deleted along with bob, got %v", err)
+	}
+
+	// dynamic updates reindex the references they change
+	if _, err := threads.Insert(Thread{Document: bingo.Document{ID: "t3"}, Author: bingo.RefTo(ann)}); err != nil {
+		t.Fatalf("Failed to insert thread: %v", err)
+	}
+	if err := driver.Dynamic("threads").UpdateByKey("t3", map[string]any{"_id": "t3", "author": ""}); err != nil {
+		t.Fatalf("Failed to update t3: %v", err)
+	}
+	if err := members.DeleteOne(ann); err != nil {
+		t.Fatalf("Failed to delete ann: %v", err)
+	}
+	if _, err := threads.FindByKey("t3"); err != nil {
+		t.Fatalf("Expected t3 to no longer reference ann, got %v", err)
+	}
+}
@@ -0,0 +1,414 @@
+package bingo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const JSONSCHEMA_COLLECTION_NAME = "__jsonschema:"
+
+var ErrSchemaViolation = fmt.Errorf("document does not match schema")
+
+// IsErrSchemaViolation returns true if the error is an ErrSchemaViolation error.
+func IsErrSchemaViolation(err error) bool {
+	return errors.Is(err, ErrSchemaViolation)
+}
+
+// JSONSchema is a JSON Schema (draft 2020-12) document describing a collection.
+type JSONSchema map[string]any
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// JSONSchemaOf generates a JSON Schema from a Go document type.
+// Property names follow the names used when storing documents, and `validate` tags are translated where possible:
+// required, min, max, len, gt, gte, lt, lte, email, url, uuid and oneof.
+func JSONSchemaOf(typ reflect.Type) JSONSchema {
+	schema := jsonSchemaOfType(typ, map[reflect.Type]bool{})
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return schema
+}
+
+func jsonSchemaOfType(typ reflect.Type, seen map[reflect.Type]bool) JSONSchema {
+	if typ.Kind() == reflect.Pointer {
+		inner := jsonSchemaOfType(typ.Elem(), seen)
+		if t, ok := inner["type"].(string); ok {
+			inner["type"] = []any{t, "null"}
+		}
+		return inner
+	}
+	if typ == timeType {
+		return JSONSchema{"type": "string", "format": "date-time"}
+	}
+	if reflect.PtrTo(typ).Implements(referenceType) {
+		return JSONSchema{"type": []any{"string", "null"}}
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return JSONSchema{"type": "array", "items": jsonSchemaOfType(typ.Elem(), seen)}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": jsonSchemaOfType(typ.Elem(), seen)}
+	case reflect.Struct:
+		if seen[typ] {
+			return JSONSchema{"type": "object"}
+		}
+		seen[typ] = true
+		defer delete(seen, typ)
+		schema := JSONSchema{"type": "object"}
+		properties := JSONSchema{}
+		var required []any
+		jsonSchemaProperties(typ, properties, &required, seen)
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return JSONSchema{}
+}
+
+func jsonSchemaProperties(typ reflect.Type, properties JSONSchema, required *[]any, seen map[reflect.Type]bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("bingo_json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if field.Anonymous && tag[0] == "" && field.Type.Kind() == reflect.Struct {
+			jsonSchemaProperties(field.Type, properties, required, seen)
+			continue
+		}
+		name := field.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		property := jsonSchemaOfType(field.Type, seen)
+		if applyValidateTag(property, field.Tag.Get("validate")) {
+			*required = append(*required, name)
+		}
+		properties[name] = property
+	}
+}
+
+// applyValidateTag translates the validator rules of a field into JSON Schema keywords,
+// it returns true if the field is required.
+func applyValidateTag(property JSONSchema, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	required := false
+	kind, _ := property["type"].(string)
+	if types, ok := property["type"].([]any); ok {
+		kind, _ = types[0].(string)
+	}
+	bound := func(keyword string) string {
+		switch kind {
+		case "string":
+			return map[string]string{"min": "minLength", "max": "maxLength"}[keyword]
+		case "array":
+			return map[string]string{"min": "minItems", "max": "maxItems"}[keyword]
+		case "object":
+			return map[string]string{"min": "minProperties", "max": "maxProperties"}[keyword]
+		}
+		return map[string]string{"min": "minimum", "max": "maximum"}[keyword]
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+			if kind == "string" {
+				if _, ok := property["minLength"]; !ok {
+					property["minLength"] = 1
+				}
+			}
+		case "min", "gte":
+			property[bound("min")] = jsonNumber(param)
+		case "max", "lte":
+			property[bound("max")] = jsonNumber(param)
+		case "len":
+			property[bound("min")] = jsonNumber(param)
+			property[bound("max")] = jsonNumber(param)
+		case "gt":
+			if kind == "integer" || kind == "number" {
+				property["exclusiveMinimum"] = jsonNumber(param)
+			}
+		case "lt":
+			if kind == "integer" || kind == "number" {
+				property["exclusiveMaximum"] = jsonNumber(param)
+			}
+		case "email":
+			property["format"] = "email"
+		case "url", "uri":
+			property["format"] = "uri"
+		case "uuid", "uuid4":
+			property["format"] = "uuid"
+		case "oneof":
+			var enum []any
+			for _, v := range strings.Fields(param) {
+				if kind == "integer" || kind == "number" {
+					enum = append(enum, jsonNumber(v))
+				} else {
+					enum = append(enum, strings.Trim(v, "'"))
+				}
+			}
+			property["enum"] = enum
+		}
+	}
+	return required
+}
+
+func jsonNumber(s string) any {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// JSONSchema returns the JSON Schema of a collection.
+// The schema is generated from the Go type registered through CollectionFrom, falling back to a schema stored with ImportJSONSchema.
+func (d *Driver) JSONSchema(name string) (JSONSchema, error) {
+	if typ, ok := d.collectionType(name); ok {
+		schema := JSONSchemaOf(typ)
+		schema["title"] = name
+		return schema, nil
+	}
+	return d.StoredJSONSchema(name)
+}
+
+// ImportJSONSchema stores a JSON Schema for a collection in the metadata,
+// it is used to validate documents of collections that have no Go type.
+func (d *Driver) ImportJSONSchema(name string, data []byte) error {
+	var schema JSONSchema
+	if err := Unmarshaller.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("invalid json schema: %w", err)
+	}
+	return d.WriteMetadata(JSONSCHEMA_COLLECTION_NAME+name, schema)
+}
+
+// StoredJSONSchema returns the JSON Schema stored for a collection with ImportJSONSchema.
+func (d *Driver) StoredJSONSchema(name string) (JSONSchema, error) {
+	r, err := d.ReadMetadata(JSONSCHEMA_COLLECTION_NAME + name)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := r.(map[string]any); ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown json schema structure: %v", r)
+}
+
+// ValidateJSON validates a raw JSON document against the schema.
+func (s JSONSchema) ValidateJSON(data []byte) error {
+	var v any
+	if err := Unmarshaller.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.Validate(v)
+}
+
+// Validate validates a decoded JSON value against the schema.
+// Only the keywords produced by JSONSchemaOf are supported, unknown keywords are ignored.
+func (s JSONSchema) Validate(v any) error {
+	var problems []string
+	validateJSONSchema(s, v, "$", &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrSchemaViolation, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func validateJSONSchema(schema map[string]any, v any, path string, problems *[]string) {
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := schema["type"]; ok && !matchesJSONType(t, v) {
+		fail("expected %v, got %s", t, jsonTypeOf(v))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", enum)
+		}
+	}
+
+	switch value := v.(type) {
+	case string:
+		if n, ok := schemaFloat(schema["minLength"]); ok && float64(len([]rune(value))) < n {
+			fail("must be at least %v characters", n)
+		}
+		if n, ok := schemaFloat(schema["maxLength"]); ok && float64(len([]rune(value))) > n {
+			fail("must be at most %v characters", n)
+		}
+		switch schema["format"] {
+		case "email":
+			if _, err := mail.ParseAddress(value); err != nil {
+				fail("must be an email address")
+			}
+		case "uri":
+			if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+				fail("must be an uri")
+			}
+		case "uuid":
+			if !uuidPattern.MatchString(value) {
+				fail("must be an uuid")
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+				fail("must be a date-time")
+			}
+		}
+	case float64:
+		if n, ok := schemaFloat(schema["minimum"]); ok && value < n {
+			fail("must be >= %v", n)
+		}
+		if n, ok := schemaFloat(schema["maximum"]); ok && value > n {
+			fail("must be <= %v", n)
+		}
+		if n, ok := schemaFloat(schema["exclusiveMinimum"]); ok && value <= n {
+			fail("must be > %v", n)
+		}
+		if n, ok := schemaFloat(schema["exclusiveMaximum"]); ok && value >= n {
+			fail("must be < %v", n)
+		}
+	case []any:
+		if n, ok := schemaFloat(schema["minItems"]); ok && float64(len(value)) < n {
+			fail("must have at least %v items", n)
+		}
+		if n, ok := schemaFloat(schema["maxItems"]); ok && float64(len(value)) > n {
+			fail("must have at most %v items", n)
+		}
+		if items, ok := schemaMap(schema["items"]); ok {
+			for i, item := range value {
+				validateJSONSchema(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case map[string]any:
+		if n, ok := schemaFloat(schema["minProperties"]); ok && float64(len(value)) < n {
+			fail("must have at least %v properties", n)
+		}
+		if n, ok := schemaFloat(schema["maxProperties"]); ok && float64(len(value)) > n {
+			fail("must have at most %v properties", n)
+		}
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				if _, ok := value[fmt.Sprint(r)]; !ok {
+					fail("missing required property %v", r)
+				}
+			}
+		}
+		properties, _ := schemaMap(schema["properties"])
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if property, ok := schemaMap(properties[k]); ok {
+				validateJSONSchema(property, value[k], path+"."+k, problems)
+				continue
+			}
+			if additional, ok := schemaMap(schema["additionalProperties"]); ok {
+				validateJSONSchema(additional, value[k], path+"."+k, problems)
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				fail("unexpected property %s", k)
+			}
+		}
+	}
+}
+
+func schemaMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case JSONSchema:
+		return m, true
+	case map[string]any:
+		return m, true
+	}
+	return nil, false
+}
+
+func schemaFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func matchesJSONType(t any, v any) bool {
+	switch t := t.(type) {
+	case string:
+		actual := jsonTypeOf(v)
+		return actual == t || (t == "number" && actual == "integer")
+	case []any:
+		for _, i := range t {
+			if matchesJSONType(i, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func jsonTypeOf(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) && !math.IsInf(value, 0) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,77 @@
+package bingo_test
+
+import (
+	"github.com/nokusukun/bingo"
+	"os"
+	"testing"
+)
+
+type SchemaUser struct {
+	bingo.Document
+	Username string   `json:"username" validate:"required,min=3,max=64"`
+	Email    string   `json:"email" validate:"required,email"`
+	Age      int      `json:"age" validate:"gte=0,lte=130"`
+	Role     string   `json:"role" validate:"oneof=admin user"`
+	Tags     []string `json:"tags" validate:"max=3"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testjsonschema.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+
+	defer func() {
+		driver.Close()
+		os.Remove("testjsonschema.db")
+	}()
+
+	bingo.CollectionFrom[SchemaUser](driver, "users")
+
+	schema, err := driver.JSONSchema("users")
+	if err != nil {
+		t.Fatalf("Failed to generate schema: %v", err)
+	}
+
+	properties := schema["properties"].(bingo.JSONSchema)
+	if _, ok := properties["_id"]; !ok {
+		t.Fatalf("Expected embedded document fields to be flattened: %v", properties)
+	}
+	if properties["Email"].(bingo.JSONSchema)["format"] != "email" {
+		t.Fatalf("Expected email format: %v", properties["Email"])
+	}
+
+	valid := []byte(`{"_id":"1","Username":"john","Email":"john@example.com","Age":30,"Role":"admin","Tags":["a"]}`)
+	if err := schema.ValidateJSON(valid); err != nil {
+		t.Fatalf("Expected document to be valid: %v", err)
+	}
+
+	invalid := []byte(`{"Username":"jo","Email":"nope","Age":-1,"Role":"root","Tags":["a","b","c","d"]}`)
+	if err := schema.ValidateJSON(invalid); err == nil || !bingo.IsErrSchemaViolation(err) {
+		t.Fatalf("Expected a schema violation, got: %v", err)
+	}
+
+	t.Run("should round trip through the metadata", func(t *testing.T) {
+		data, err := bingo.Marshaller.Marshal(schema)
+		if err != nil {
+			t.Fatalf("Failed to marshal schema: %v", err)
+		}
+		if err := driver.ImportJSONSchema("imported", data); err != nil {
+			t.Fatalf("Failed to import schema: %v", err)
+		}
+		stored, err := driver.JSONSchema("imported")
+		if err != nil {
+			t.Fatalf("Failed to read stored schema: %v", err)
+		}
+		if err := stored.ValidateJSON(valid); err != nil {
+			t.Fatalf("Expected document to be valid: %v", err)
+		}
+		if err := stored.ValidateJSON(invalid); err == nil {
+			t.Fatalf("Expected a schema violation")
+		}
+	})
+}
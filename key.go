@@ -0,0 +1,283 @@
+package bingo
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Type tags of the encoded key parts, parts of different types sort by their tag.
+const (
+	keyNil    byte = 0x00
+	keyBytes  byte = 0x01
+	keyString byte = 0x02
+	keyInt    byte = 0x03
+	keyUint   byte = 0x04
+	keyFloat  byte = 0x05
+	keyTime   byte = 0x06
+	keyUUID   byte = 0x07
+	keyFalse  byte = 0x08
+	keyTrue   byte = 0x09
+)
+
+// UUID is a UUID key part, any [16]byte type such as github.com/google/uuid.UUID is encoded as one.
+type UUID [16]byte
+
+// ParseUUID parses a UUID in its canonical form, such as "f47ac10b-58cc-4372-a567-0e02b2c3d479".
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != 16 || len(s) != 36 {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+func (u UUID) String() string {
+	return string(formatUUID(u[:]))
+}
+
+// Key encodes parts into a key whose byte order is the order of the parts, compared one after the other.
+// Parts may be strings, []byte, signed and unsigned integers, floats, bools, time.Time, UUIDs (any [16]byte type)
+// and nil. Integers of every size are encoded as int64 or uint64, so Key(1) and Key(int64(1)) are the same key.
+// Key panics on other types, use EncodeKey to get an error instead.
+//
+// A key of the leading parts of another key is a prefix of it, see Query.Prefix:
+//
+//	bingo.Key("acme", 2024) is a prefix of bingo.Key("acme", 2024, "order-1") but not of bingo.Key("acme", 20240)
+//
+// Encoded keys are binary, documents keyed by them should compute them in their Key method rather than store them in
+// a string ID, which is not kept byte for byte by JSON.
+func Key(parts ...any) []byte {
+	key, err := EncodeKey(parts...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// EncodeKey encodes parts like Key, returning an error for unsupported types.
+func EncodeKey(parts ...any) ([]byte, error) {
+	var key []byte
+	for i, part := range parts {
+		var err error
+		if key, err = appendKeyPart(key, part); err != nil {
+			return nil, fmt.Errorf("key part %d: %w", i, err)
+		}
+	}
+	return key, nil
+}
+
+func appendKeyPart(key []byte, part any) ([]byte, error) {
+	switch v := part.(type) {
+	case nil:
+		return append(key, keyNil), nil
+	case string:
+		return appendEscaped(append(key, keyString), []byte(v)), nil
+	case []byte:
+		return appendEscaped(append(key, keyBytes), v), nil
+	case bool:
+		if v {
+			return append(key, keyTrue), nil
+		}
+		return append(key, keyFalse), nil
+	case time.Time:
+		// seconds and nanoseconds rather than UnixNano, which overflows outside of the years 1678 to 2262
+		var nanos [4]byte
+		binary.BigEndian.PutUint32(nanos[:], uint32(v.Nanosecond()))
+		return append(appendUint(append(key, keyTime), uint64(v.Unix())^1<<63), nanos[:]...), nil
+	case UUID:
+		return append(append(key, keyUUID), v[:]...), nil
+	case float32:
+		return appendFloat(key, float64(v)), nil
+	case float64:
+		return appendFloat(key, v), nil
+	}
+	value := reflect.ValueOf(part)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendUint(append(key, keyInt), uint64(value.Int())^1<<63), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(append(key, keyUint), value.Uint()), nil
+	case reflect.String:
+		return appendEscaped(append(key, keyString), []byte(value.String())), nil
+	case reflect.Array:
+		if value.Len() == 16 && value.Type().Elem().Kind() == reflect.Uint8 {
+			var u UUID
+			reflect.Copy(reflect.ValueOf(u[:]), value)
+			return append(append(key, keyUUID), u[:]...), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported key part type %T", part)
+}
+
+// appendEscaped appends bytes terminated by a zero byte, zero bytes of b are escaped as 0x00 0xFF so they sort before
+// the end of a longer value.
+func appendEscaped(key, b []byte) []byte {
+	for _, c := range b {
+		key = append(key, c)
+		if c == 0 {
+			key = append(key, 0xFF)
+		}
+	}
+	return append(key, 0)
+}
+
+func appendUint(key []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(key, b[:]...)
+}
+
+// appendFloat flips the sign bit of positive floats and every bit of negative ones, so their bits sort numerically.
+func appendFloat(key []byte, f float64) []byte {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return appendUint(append(key, keyFloat), bits)
+}
+
+// DecodeKey decodes a key encoded by Key. Parts are returned as nil, string, []byte, bool, int64, uint64, float64,
+// time.Time in UTC or UUID.
+func DecodeKey(key []byte) ([]any, error) {
+	var parts []any
+	for len(key) > 0 {
+		tag := key[0]
+		key = key[1:]
+		var part any
+		switch tag {
+		case keyNil:
+		case keyFalse, keyTrue:
+			part = tag == keyTrue
+		case keyString, keyBytes:
+			b, rest, err := readEscaped(key)
+			if err != nil {
+				return nil, err
+			}
+			key = rest
+			part = b
+			if tag == keyString {
+				part = string(b)
+			}
+		case keyTime:
+			if len(key) < 12 {
+				return nil, fmt.Errorf("invalid key: truncated time")
+			}
+			part = time.Unix(int64(binary.BigEndian.Uint64(key)^1<<63), int64(binary.BigEndian.Uint32(key[8:]))).UTC()
+			key = key[12:]
+		case keyInt, keyUint, keyFloat:
+			if len(key) < 8 {
+				return nil, fmt.Errorf("invalid key: truncated number")
+			}
+			v := binary.BigEndian.Uint64(key)
+			key = key[8:]
+			switch tag {
+			case keyInt:
+				part = int64(v ^ 1<<63)
+			case keyUint:
+				part = v
+			case keyFloat:
+				if v&(1<<63) != 0 {
+					v &^= 1 << 63
+				} else {
+					v = ^v
+				}
+				part = math.Float64frombits(v)
+			}
+		case keyUUID:
+			if len(key) < 16 {
+				return nil, fmt.Errorf("invalid key: truncated UUID")
+			}
+			var u UUID
+			copy(u[:], key)
+			key = key[16:]
+			part = u
+		default:
+			return nil, fmt.Errorf("invalid key: unknown part type %#x", tag)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func readEscaped(key []byte) ([]byte, []byte, error) {
+	var b []byte
+	for i := 0; i < len(key); i++ {
+		if key[i] != 0 {
+			b = append(b, key[i])
+			continue
+		}
+		if i+1 < len(key) && key[i+1] == 0xFF {
+			b = append(b, 0)
+			i++
+			continue
+		}
+		if b == nil {
+			b = []byte{}
+		}
+		return b, key[i+1:], nil
+	}
+	return nil, nil, fmt.Errorf("invalid key: unterminated string")
+}
+
+// ScanKey decodes a key encoded by Key into dest, pointers to values of the types of its parts.
+// Integer parts can be scanned into any integer type large enough, and any part into a *any.
+//
+//	var tenant string
+//	var created time.Time
+//	err := bingo.ScanKey(key, &tenant, &created)
+func ScanKey(key []byte, dest ...any) error {
+	parts, err := DecodeKey(key)
+	if err != nil {
+		return err
+	}
+	if len(parts) != len(dest) {
+		return fmt.Errorf("key has %d parts, not %d", len(parts), len(dest))
+	}
+	for i, part := range parts {
+		target := reflect.ValueOf(dest[i])
+		if target.Kind() != reflect.Pointer || target.IsNil() {
+			return fmt.Errorf("key part %d: destination must be a non-nil pointer", i)
+		}
+		target = target.Elem()
+		if part == nil {
+			target.Set(reflect.Zero(target.Type()))
+			continue
+		}
+		value := reflect.ValueOf(part)
+		switch {
+		case value.Type().AssignableTo(target.Type()):
+			target.Set(value)
+		case value.Kind() == reflect.Int64 && target.CanInt() && !target.OverflowInt(value.Int()):
+			target.SetInt(value.Int())
+		case value.Kind() == reflect.Uint64 && target.CanUint() && !target.OverflowUint(value.Uint()):
+			target.SetUint(value.Uint())
+		case value.Type().ConvertibleTo(target.Type()) && value.Kind() == target.Kind():
+			target.Set(value.Convert(target.Type()))
+		default:
+			return fmt.Errorf("key part %d: cannot scan %T into %v", i, part, target.Type())
+		}
+	}
+	return nil
+}
+
+// PrefixEnd returns the first key after every key starting with prefix, nil if there is none.
+// Keys starting with prefix are the keys k with prefix <= k < PrefixEnd(prefix).
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xFF {
+			end[i] += 1
+			return end[:i+1]
+		}
+	}
+	return nil
+}
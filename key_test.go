@@ -0,0 +1,142 @@
+package bingo_test
+
+import (
+	"bytes"
+	"github.com/nokusukun/bingo"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+type Event struct {
+	bingo.Document
+	Tenant string `json:"tenant"`
+	Seq    int64  `json:"seq"`
+}
+
+func (e Event) Key() []byte {
+	return bingo.Key(e.Tenant, e.Seq)
+}
+
+func TestKeyEncoding(t *testing.T) {
+	now := time.Now()
+	id, err := bingo.ParseUUID("f47ac10b-58cc-4372-a567-0e02b2c3d479")
+	if err != nil || id.String() != "f47ac10b-58cc-4372-a567-0e02b2c3d479" {
+		t.Fatalf("Failed to parse UUID: %v %v", id, err)
+	}
+
+	// every key sorts before the next one
+	ordered := [][]any{
+		{nil},
+		{[]byte("a\x00")},
+		{""},
+		{"a"},
+		{"a", int64(-1)},
+		{"a", 0},
+		{"a", 9},
+		{"a", 10},
+		{"a\x00"},
+		{"a\x00b"},
+		{"ab"},
+		{math.MinInt64},
+		{-10},
+		{uint(0)},
+		{uint64(math.MaxUint64)},
+		{math.Inf(-1)},
+		{-1.5},
+		{0.0},
+		{0.25},
+		{2.0},
+		{time.Time{}},
+		{time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{now.Add(-time.Hour)},
+		{now},
+		{time.Date(3000, 1, 1, 0, 0, 0, 1, time.UTC)},
+		{id},
+		{false},
+		{true},
+	}
+	var previous []byte
+	for _, parts := range ordered {
+		key, err := bingo.EncodeKey(parts...)
+		if err != nil {
+			t.Fatalf("Failed to encode %v: %v", parts, err)
+		}
+		if previous != nil && bytes.Compare(previous, key) >= 0 {
+			t.Fatalf("Expected %v to sort after the previous key", parts)
+		}
+		previous = key
+	}
+
+	key := bingo.Key("acme", int64(-42), 1.5, now, id, true, []byte{0, 1}, nil)
+	parts, err := bingo.DecodeKey(key)
+	if err != nil || len(parts) != 8 {
+		t.Fatalf("Failed to decode key: %v %v", parts, err)
+	}
+	if parts[0] != "acme" || parts[1] != int64(-42) || parts[2] != 1.5 || !parts[3].(time.Time).Equal(now) || parts[4] != id || parts[5] != true || !bytes.Equal(parts[6].([]byte), []byte{0, 1}) || parts[7] != nil {
+		t.Fatalf("Expected the decoded parts to match, got %v", parts)
+	}
+
+	var tenant string
+	var seq int32
+	var at time.Time
+	if err := bingo.ScanKey(bingo.Key("acme", 7, now), &tenant, &seq, &at); err != nil || tenant != "acme" || seq != 7 || !at.Equal(now) {
+		t.Fatalf("Failed to scan key: %v %v %v %v", tenant, seq, at, err)
+	}
+	if err := bingo.ScanKey(bingo.Key("acme"), &seq); err == nil {
+		t.Fatalf("Expected scanning a string into an int to fail")
+	}
+	if err := bingo.ScanKey(bingo.Key(time.Time{}), &at); err != nil || !at.IsZero() {
+		t.Fatalf("Expected the zero time to round trip, got %v %v", at, err)
+	}
+	if _, err := bingo.EncodeKey(struct{}{}); err == nil {
+		t.Fatalf("Expected an unsupported part to fail")
+	}
+	if !bytes.HasPrefix(bingo.Key("acme", 2024, "x"), bingo.Key("acme", 2024)) || bytes.HasPrefix(bingo.Key("acmeco"), bingo.Key("acme")) {
+		t.Fatalf("Expected keys of leading parts to be prefixes")
+	}
+}
+
+func TestKeyQueries(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testkeys.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testkeys.db")
+	}()
+
+	events := bingo.CollectionFrom[Event](driver, "events")
+	for _, e := range []Event{{Tenant: "acme", Seq: 9}, {Tenant: "acme", Seq: 10}, {Tenant: "acme", Seq: -1}, {Tenant: "acmeco", Seq: 1}, {Tenant: "zeta", Seq: 1}} {
+		if _, err := events.Insert(e); err != nil {
+			t.Fatalf("Failed to insert event: %v", err)
+		}
+	}
+
+	found, err := events.FindByPrefix(bingo.Key("acme"))
+	if err != nil || len(found) != 3 || found[0].Seq != 10 || found[1].Seq != 9 || found[2].Seq != -1 {
+		t.Fatalf("Expected the acme events in reverse numeric order, got %v %v", found, err)
+	}
+	if found, err := events.FindByPrefix(bingo.Key("none")); err != nil || len(found) != 0 {
+		t.Fatalf("Expected no events, got %v %v", found, err)
+	}
+
+	result := events.Query(bingo.Query[Event]{
+		Prefix: bingo.Key("acme"),
+		Start:  bingo.Key("acme", 0),
+		Filter: func(e Event) bool { return e.Seq != 10 },
+	})
+	if result.Error != nil || result.Count() != 1 || result.First().Seq != 9 {
+		t.Fatalf("Expected the range query to return event 9, got %v", result.Items)
+	}
+	result = events.Query(bingo.Query[Event]{Start: bingo.Key("acmeco"), End: bingo.Key("zeta", 1)})
+	if result.Error != nil || result.Count() != 1 || result.First().Tenant != "acmeco" {
+		t.Fatalf("Expected the range to exclude its end, got %v", result.Items)
+	}
+}
@@ -0,0 +1,108 @@
+package bingo
+
+import (
+	"errors"
+)
+
+// Phase is a step of the lifecycle every write of a collection goes through. The phases run in order:
+//
+//   - PhaseValidate fills the updatedAt fields of the document, and its default and createdAt fields when it is inserted,
+//     then calls its Compute method, see Computed. It then validates the document with the validator of the collection and runs the Validate hooks.
+//     Deletes have nothing to validate and skip it.
+//   - PhaseBefore runs the Before hooks, the changes they make to the document are written.
+//   - PhaseWrite generates the key of a new document, encodes it and stores it. No hook runs in it.
+//   - PhaseAfterTx runs the AfterTx hooks inside the write transaction once the document is stored,
+//     their error rolls the whole write back. They must not start transactions of their own, bbolt would deadlock.
+//   - PhaseAfterCommit runs the After hooks once the transaction is committed, their error is returned but the write stays.
+//
+// Methods given their documents, such as Insert, UpdateOne, DeleteOne and the methods of QueryResult, run PhaseValidate
+// and PhaseBefore before the transaction starts. UpdateIter and DeleteIter read their documents inside the transaction
+// and run them there. An error before PhaseAfterCommit leaves the collection untouched. IgnoreErrors only skips the
+// documents of an insert failing before PhaseAfterTx, an AfterTx error still rolls the whole insert back.
+//
+// Dynamic collections go through the same phases with nothing to fill, validating documents with their JSON Schema.
+// Their inserts generate the key first and run PhaseValidate and PhaseBefore inside the transaction, see
+// DynamicCollection.InsertMany.
+type Phase int
+
+const (
+	PhaseValidate Phase = iota
+	PhaseBefore
+	PhaseWrite
+	PhaseAfterTx
+	PhaseAfterCommit
+)
+
+var phaseNames = map[Phase]string{
+	PhaseValidate:    "Validate",
+	PhaseBefore:      "Before",
+	PhaseWrite:       "Write",
+	PhaseAfterTx:     "AfterTx",
+	PhaseAfterCommit: "AfterCommit",
+}
+
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+// lifecycle holds the hook events of the phases of a kind of write.
+type lifecycle struct {
+	stores   bool
+	creates  bool
+	validate HookEvent
+	before   HookEvent
+	afterTx  HookEvent
+	after    HookEvent
+}
+
+var (
+	insertLifecycle = lifecycle{stores: true, creates: true, validate: HookValidateInsert, before: HookBeforeInsert, afterTx: HookAfterInsertTx, after: HookAfterInsert}
+	updateLifecycle = lifecycle{stores: true, validate: HookValidateUpdate, before: HookBeforeUpdate, afterTx: HookAfterUpdateTx, after: HookAfterUpdate}
+	deleteLifecycle = lifecycle{before: HookBeforeDelete, afterTx: HookAfterDeleteTx, after: HookAfterDelete}
+)
+
+// Phase returns the phase of the write lifecycle an event runs in, AfterFind and Around run outside of it and return -1.
+func (e HookEvent) Phase() Phase {
+	switch e {
+	case HookValidateInsert, HookValidateUpdate:
+		return PhaseValidate
+	case HookBeforeInsert, HookBeforeUpdate, HookBeforeDelete:
+		return PhaseBefore
+	case HookAfterInsertTx, HookAfterUpdateTx, HookAfterDeleteTx:
+		return PhaseAfterTx
+	case HookAfterInsert, HookAfterUpdate, HookAfterDelete:
+		return PhaseAfterCommit
+	}
+	return -1
+}
+
+// prepare runs the validate and before phases of a write on a document.
+func (c *Collection[T]) prepare(l lifecycle, doc *T) error {
+	if l.stores {
+		c.fillFields(doc, l.creates)
+		if err := c.validate(*doc); err != nil {
+			return err
+		}
+		if err := c.runHooks(l.validate, doc); err != nil {
+			return err
+		}
+	}
+	return c.runHooks(l.before, doc)
+}
+
+// written runs the after-transaction phase of a write on a document it stored.
+func (c *Collection[T]) written(l lifecycle, doc *T) error {
+	return c.runHooks(l.afterTx, doc)
+}
+
+// committed runs the after-commit phase of a write on the documents it stored.
+// The documents are already written, so every document runs its hooks and the errors are joined.
+func (c *Collection[T]) committed(l lifecycle, docs ...*T) error {
+	var errs []error
+	for _, doc := range docs {
+		if err := c.runHooks(l.after, doc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,375 @@
+package bingo
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"go.etcd.io/bbolt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OPTIONS_COLLECTION_NAME = "__options:"
+	DEFAULT_CODEC           = "json"
+	DEFAULT_KEY_GENERATOR   = "snowflake"
+)
+
+// Codec encodes the documents of a collection, see RegisterCodec.
+type Codec interface {
+	HasMarshal
+	HasUnmarshal
+}
+
+// KeyGenerator returns the key of a new document, bucket is the collection bucket inside the inserting transaction.
+type KeyGenerator func(bucket *bbolt.Bucket) ([]byte, error)
+
+// jsonCodec encodes documents through Marshaller and Unmarshaller, so replacing them keeps working.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return Marshaller.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return Unmarshaller.Unmarshal(data, v)
+}
+
+var registry = struct {
+	sync.RWMutex
+	codecs     map[string]Codec
+	generators map[string]KeyGenerator
+}{
+	codecs:     map[string]Codec{DEFAULT_CODEC: jsonCodec{}},
+	generators: builtinKeyGenerators(),
+}
+
+// RegisterCodec makes a codec available to WithCodec under a name.
+// The name is persisted with the collection, so the codec must be registered before the collection is opened again.
+func RegisterCodec(name string, codec Codec) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.codecs[name] = codec
+}
+
+// RegisterKeyGenerator makes a key generator available to WithKeyGenerator under a name.
+// The built-in generators are "snowflake", "autoincrement", "uuid" (version 4), "uuidv7", "ulid" and "ksuid".
+func RegisterKeyGenerator(name string, generator KeyGenerator) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.generators[name] = generator
+}
+
+func codecNamed(name string) (Codec, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	codec, ok := registry.codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown codec %q", ErrInvalidOptions, name)
+	}
+	return codec, nil
+}
+
+func keyGeneratorNamed(name string) (KeyGenerator, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	generator, ok := registry.generators[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key generator %q", ErrInvalidOptions, name)
+	}
+	return generator, nil
+}
+
+// CollectionOptions configures a collection opened with OpenCollection.
+// Codec is the name of the registered codec documents are stored with, defaults to "json".
+// Indexes lists the stored field paths indexed for Collection.FindByIndex.
+// TTL expires documents once the duration passed since they were last written, expired documents are left out of reads
+// and removed by Collection.PurgeExpired.
+// SoftDelete moves deleted documents to the __deleted:<collection> bucket, see Collection.Deleted and Collection.Undelete.
+// KeyGenerator is the name of the registered generator of the keys of new documents, defaults to "snowflake",
+// see RegisterKeyGenerator for the built-in generators.
+// References lists the stored field paths holding keys of other collections and what deleting these documents does,
+// see WithReference. The Ref fields of the document type are added from their bingo tags.
+// Validator replaces the driver validator for the collection, SkipValidation disables validation altogether.
+// ReadOnly makes every write of the collection fail with ErrReadOnly.
+//
+// Codec, Indexes, TTL, SoftDelete, KeyGenerator and References are persisted in __metadata and apply whenever the collection is
+// opened again, options passed to OpenCollection are applied on top of them.
+// Validator, SkipValidation and ReadOnly only apply to the returned collection.
+type CollectionOptions struct {
+	Codec          string
+	Indexes        []string
+	TTL            time.Duration
+	SoftDelete     bool
+	KeyGenerator   string
+	References     []Reference
+	Validator      *validator.Validate `bingo_json:"-"`
+	SkipValidation bool                `bingo_json:"-"`
+	ReadOnly       bool                `bingo_json:"-"`
+}
+
+// WithCodec stores the documents of the collection with a codec registered with RegisterCodec.
+// The codec of a collection holding documents cannot be changed.
+func WithCodec(name string) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		opts.Codec = name
+	}
+}
+
+// WithIndex indexes stored field paths, such as "email" or "Address.City", for Collection.FindByIndex.
+// Existing documents are indexed when the collection is opened.
+func WithIndex(fields ...string) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		for _, field := range fields {
+			if !contains(opts.Indexes, field) {
+				opts.Indexes = append(opts.Indexes, field)
+			}
+		}
+	}
+}
+
+// WithoutIndex drops the indexes of stored field paths.
+func WithoutIndex(fields ...string) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		var indexes []string
+		for _, index := range opts.Indexes {
+			if !contains(fields, index) {
+				indexes = append(indexes, index)
+			}
+		}
+		opts.Indexes = indexes
+	}
+}
+
+// WithTTL expires documents once ttl passed since they were last written, zero disables expiry.
+func WithTTL(ttl time.Duration) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		opts.TTL = ttl
+	}
+}
+
+// SoftDelete keeps deleted documents in the __deleted:<collection> bucket.
+func SoftDelete(opts *CollectionOptions) {
+	opts.SoftDelete = true
+}
+
+// WithKeyGenerator generates the keys of new documents with a generator registered with RegisterKeyGenerator.
+func WithKeyGenerator(name string) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		opts.KeyGenerator = name
+	}
+}
+
+// WithValidator validates the documents of the collection with v instead of the driver validator.
+func WithValidator(v *validator.Validate) func(opts *CollectionOptions) {
+	return func(opts *CollectionOptions) {
+		opts.Validator = v
+	}
+}
+
+// SkipValidation disables validation of the documents of the collection.
+func SkipValidation(opts *CollectionOptions) {
+	opts.SkipValidation = true
+}
+
+// ReadOnlyCollection makes every write of the collection fail with ErrReadOnly.
+func ReadOnlyCollection(opts *CollectionOptions) {
+	opts.ReadOnly = true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// openedCollection is what a driver remembers of a collection it opened, so opening it again is cheap.
+type openedCollection struct {
+	typ     reflect.Type
+	options []byte
+	codec   Codec
+}
+
+// openCollection loads the persisted options of a collection, applies opts on top of them and records the collection
+// in __metadata. Nothing is written when the collection was already opened with the same type and options.
+func (d *Driver) openCollection(name string, typ reflect.Type, opts []func(options *CollectionOptions)) (*CollectionOptions, Codec, KeyGenerator, error) {
+	d.mu.RLock()
+	opened, ok := d.opened[name]
+	d.mu.RUnlock()
+
+	options := &CollectionOptions{}
+	var stored *CollectionOptions
+	var err error
+	if ok {
+		stored = &CollectionOptions{}
+		err = Unmarshaller.Unmarshal(opened.options, stored)
+	} else {
+		stored, err = d.storedOptions(name)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if stored != nil {
+		*options = *stored
+	}
+	for _, o := range opts {
+		o(options)
+	}
+	if options.Codec == "" {
+		options.Codec = DEFAULT_CODEC
+	}
+	if options.KeyGenerator == "" {
+		options.KeyGenerator = DEFAULT_KEY_GENERATOR
+	}
+	if options.References, err = declaredReferences(options.References, typ); err != nil {
+		return nil, nil, nil, err
+	}
+	codec, err := codecNamed(options.Codec)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	generator, err := keyGeneratorNamed(options.KeyGenerator)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	persisted, err := Marshaller.Marshal(options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if ok && opened.typ == typ && bytes.Equal(opened.options, persisted) {
+		return options, codec, generator, nil
+	}
+
+	previous := &CollectionOptions{Codec: DEFAULT_CODEC}
+	if stored != nil {
+		previous = stored
+	}
+	if previous.Codec != options.Codec {
+		empty := true
+		_ = d.view(func(tx *bbolt.Tx) error {
+			if bucket := tx.Bucket([]byte(name)); bucket != nil {
+				empty = bucket.Stats().KeyN == 0
+			}
+			return nil
+		})
+		if !empty {
+			return nil, nil, nil, fmt.Errorf("%w: %s is stored with the %q codec, not %q", ErrInvalidOptions, name, previous.Codec, options.Codec)
+		}
+	}
+
+	if err := d.applySchemaPolicy(name, typ); err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to open collection %s: %w", name, err)
+	}
+
+	//read-only drivers only check the schema and leave the metadata untouched
+	if !d.config.ReadOnly {
+		var typeFields []string
+		for i := 0; i < typ.NumField(); i++ {
+			var names []string
+			names = append(names, typ.Field(i).Name)
+			if jtag := typ.Field(i).Tag.Get("json"); jtag != "" {
+				names = append(names, strings.Split(jtag, ",")[0])
+			}
+			typeFields = append(typeFields, strings.Join(names, FIELD_ALIAS_SEPARATOR))
+		}
+		entries := map[string]any{
+			FIELDS_COLLECTION_NAME + name:  typeFields,
+			"collection:" + name:           true,
+			OPTIONS_COLLECTION_NAME + name: options,
+		}
+		if err := d.writeMetadataChanges(entries); err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to write collection metadata: %w", err)
+		}
+		if err := d.updateIndexes(name, codec, typ, previous.Indexes, options.Indexes); err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to index collection %s: %w", name, err)
+		}
+		if err := d.updateReferences(name, codec, previous.References, options.References); err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to index the references of collection %s: %w", name, err)
+		}
+	}
+
+	d.mu.Lock()
+	d.opened[name] = openedCollection{typ: typ, options: persisted, codec: codec}
+	d.mu.Unlock()
+	return options, codec, generator, nil
+}
+
+// storedOptions returns the persisted options of a collection, nil if none were persisted.
+func (d *Driver) storedOptions(name string) (*CollectionOptions, error) {
+	var stored *CollectionOptions
+	err := d.view(func(tx *bbolt.Tx) (err error) {
+		stored, err = storedOptionsTx(tx, name)
+		return err
+	})
+	return stored, err
+}
+
+// storedOptionsTx returns the persisted options of a collection read in tx, nil if none were persisted.
+func storedOptionsTx(tx *bbolt.Tx, name string) (*CollectionOptions, error) {
+	meta := tx.Bucket([]byte(METADATA_COLLECTION_NAME))
+	if meta == nil {
+		return nil, nil
+	}
+	data := meta.Get([]byte(OPTIONS_COLLECTION_NAME + name))
+	if data == nil {
+		return nil, nil
+	}
+	var entry struct {
+		V CollectionOptions
+	}
+	if err := Unmarshaller.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("unable to read the options of %s: %w", name, err)
+	}
+	return &entry.V, nil
+}
+
+// writeMetadataChanges writes the metadata entries that differ from the stored ones, in a single transaction.
+// No transaction is written if every entry is up to date.
+func (d *Driver) writeMetadataChanges(entries map[string]any) error {
+	changed := map[string][]byte{}
+	err := d.view(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket([]byte(METADATA_COLLECTION_NAME))
+		for k, v := range entries {
+			data, err := Marshaller.Marshal(Metadata{K: k, V: v})
+			if err != nil {
+				return err
+			}
+			if meta == nil || !bytes.Equal(meta.Get([]byte(k)), data) {
+				changed[k] = data
+			}
+		}
+		return nil
+	})
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+	defer d.forgetReferrers()
+	return d.update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists([]byte(METADATA_COLLECTION_NAME))
+		if err != nil {
+			return err
+		}
+		for k, data := range changed {
+			if err := meta.Put([]byte(k), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// codecOf returns the codec a collection was opened with, the JSON codec if it was not opened.
+func (d *Driver) codecOf(name string) Codec {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if opened, ok := d.opened[name]; ok && opened.codec != nil {
+		return opened.codec
+	}
+	return jsonCodec{}
+}
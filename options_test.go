@@ -0,0 +1,264 @@
+package bingo_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"go.etcd.io/bbolt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDriverOptions(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testoptions.db",
+		DeleteNoVerify: true,
+		FileMode:       0640,
+		Durability:     bingo.DurabilityEphemeral,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer os.Remove("testoptions.db")
+
+	if info, err := os.Stat("testoptions.db"); err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("Expected file mode 0640, got %v %v", info.Mode().Perm(), err)
+	}
+	coll := bingo.CollectionFrom[TestDocument](driver, "test")
+	if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: "one"}, Name: "one"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	if _, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: "testoptions.db", Timeout: 50 * time.Millisecond}); err != bbolt.ErrTimeout {
+		t.Fatalf("Expected a lock timeout while the file is open, got %v", err)
+	}
+	driver.Close()
+
+	readOnly, err := bingo.NewDriver(bingo.DriverConfiguration{Filename: "testoptions.db", ReadOnly: true, DeleteNoVerify: true})
+	if err != nil {
+		t.Fatalf("Failed to open read-only driver: %v", err)
+	}
+	defer readOnly.Close()
+
+	ro := bingo.CollectionFrom[TestDocument](readOnly, "test")
+	doc, err := ro.FindByKey("one")
+	if err != nil {
+		t.Fatalf("Failed to read from read-only driver: %v", err)
+	}
+	doc.Name = "changed"
+
+	checks := map[string]error{}
+	_, checks["Insert"] = ro.Insert(TestDocument{Name: "two"})
+	_, checks["InsertMany"] = ro.InsertMany([]TestDocument{{Name: "two"}})
+	checks["UpdateOne"] = ro.UpdateOne(doc)
+	checks["DeleteOne"] = ro.DeleteOne(doc)
+	checks["UpdateIter"] = ro.UpdateIter(func(doc *TestDocument) *TestDocument { return doc })
+	checks["DeleteIter"] = ro.DeleteIter(func(doc *TestDocument) bool { return true })
+	checks["Query.Delete"] = ro.Query(bingo.Query[TestDocument]{Filter: func(doc TestDocument) bool { return true }}).Delete()
+	checks["Drop"] = ro.Drop()
+	checks["WriteMetadata"] = readOnly.WriteMetadata("k", "v")
+	_, checks["Dynamic.InsertRaw"] = readOnly.Dynamic("test").InsertRaw([]byte(`{"Name": "two"}`))
+	_, checks["Begin"] = readOnly.Begin(true)
+	for name, err := range checks {
+		if err == nil || !bingo.IsErrReadOnly(err) {
+			t.Fatalf("Expected %s to fail with ErrReadOnly, got %v", name, err)
+		}
+	}
+}
+
+type User struct {
+	bingo.Document
+	Email string `validate:"required"`
+	Role  string
+}
+
+// prefixCodec stores JSON behind a marker, so documents written with it are recognisable.
+type prefixCodec struct{}
+
+func (prefixCodec) Marshal(v any) ([]byte, error) {
+	data, err := bingo.Marshaller.Marshal(v)
+	return append([]byte("prefixed:"), data...), err
+}
+
+func (prefixCodec) Unmarshal(data []byte, v any) error {
+	return bingo.Unmarshaller.Unmarshal(bytes.TrimPrefix(data, []byte("prefixed:")), v)
+}
+
+func TestCollectionOptions(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testcollectionoptions.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testcollectionoptions.db")
+	}()
+
+	bingo.RegisterCodec("prefixed", prefixCodec{})
+	sequence := 0
+	bingo.RegisterKeyGenerator("sequence", func(bucket *bbolt.Bucket) ([]byte, error) {
+		sequence += 1
+		return []byte(fmt.Sprintf("user-%d", sequence)), nil
+	})
+
+	users, err := bingo.OpenCollection[User](driver, "users",
+		bingo.WithCodec("prefixed"),
+		bingo.WithIndex("Role"),
+		bingo.WithKeyGenerator("sequence"),
+		bingo.SoftDelete,
+	)
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	if _, err := users.InsertMany([]User{{Email: "a@example.com", Role: "admin"}, {Email: "b@example.com", Role: "user"}, {Email: "c@example.com", Role: "admin"}}); err != nil {
+		t.Fatalf("Failed to insert users: %v", err)
+	}
+	err = driver.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket([]byte("users")).Get([]byte("user-1")); !bytes.HasPrefix(v, []byte("prefixed:")) {
+			t.Fatalf("Expected user-1 to be stored with the codec, got %s", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read the bucket: %v", err)
+	}
+
+	admins, err := users.FindByIndex("Role", "admin")
+	if err != nil || len(admins) != 2 {
+		t.Fatalf("Expected 2 admins, got %v %v", admins, err)
+	}
+	admin := admins[0]
+	admin.Role = "user"
+	if err := users.UpdateOne(admin); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	if admins, _ := users.FindByIndex("Role", "admin"); len(admins) != 1 {
+		t.Fatalf("Expected the index to follow the update, got %v", admins)
+	}
+	if _, err := users.FindByIndex("Email", "a@example.com"); !errors.Is(err, bingo.ErrNotIndexed) {
+		t.Fatalf("Expected ErrNotIndexed, got %v", err)
+	}
+
+	if err := users.DeleteOne(admin); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if found, _ := users.FindByIndex("Role", "user"); len(found) != 1 {
+		t.Fatalf("Expected the deleted user to leave the index, got %v", found)
+	}
+	if deleted, err := users.Deleted(); err != nil || len(deleted) != 1 || deleted[0].ID != admin.ID {
+		t.Fatalf("Expected the deleted user to be kept, got %v %v", deleted, err)
+	}
+	// restoring goes through the insert hooks, a failing hook keeps the document deleted
+	users.BeforeInsert(func(doc *User) error {
+		return fmt.Errorf("restoring %s is not allowed", doc.ID)
+	})
+	if err := users.Undelete(admin.ID); err == nil {
+		t.Fatalf("Expected the BeforeInsert hook to fail the undelete")
+	}
+	if _, err := users.FindByKey(admin.ID); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected the user to stay deleted, got %v", err)
+	}
+	restored := 0
+	users.BeforeInsert(nil).AfterInsert(func(doc *User) error {
+		restored += 1
+		return nil
+	})
+	if err := users.Undelete(admin.ID); err != nil {
+		t.Fatalf("Failed to undelete user: %v", err)
+	}
+	if restored != 1 {
+		t.Fatalf("Expected the AfterInsert hook to run once, ran %d times", restored)
+	}
+	if _, err := users.FindByKey(admin.ID); err != nil {
+		t.Fatalf("Expected the user to be restored: %v", err)
+	}
+
+	// opening again without options uses the persisted ones and writes nothing
+	var before, after int
+	_ = driver.View(func(tx *bbolt.Tx) error { before = int(tx.ID()); return nil })
+	reopened, err := bingo.OpenCollection[User](driver, "users")
+	if err != nil {
+		t.Fatalf("Failed to reopen collection: %v", err)
+	}
+	_ = driver.View(func(tx *bbolt.Tx) error { after = int(tx.ID()); return nil })
+	if before != after {
+		t.Fatalf("Expected reopening to write nothing, the transaction id moved from %d to %d", before, after)
+	}
+	if found, err := reopened.FindByIndex("Role", "user"); err != nil || len(found) != 2 {
+		t.Fatalf("Expected the persisted index to be used, got %v %v", found, err)
+	}
+	if _, err := bingo.OpenCollection[User](driver, "users", bingo.WithCodec(bingo.DEFAULT_CODEC)); !errors.Is(err, bingo.ErrInvalidOptions) {
+		t.Fatalf("Expected changing the codec of a populated collection to fail, got %v", err)
+	}
+	if _, err := bingo.OpenCollection[User](driver, "users", bingo.WithKeyGenerator("missing")); !errors.Is(err, bingo.ErrInvalidOptions) {
+		t.Fatalf("Expected an unknown key generator to fail, got %v", err)
+	}
+
+	// indexes added later are built from the stored documents
+	byEmail, err := bingo.OpenCollection[User](driver, "users", bingo.WithIndex("Email"))
+	if err != nil {
+		t.Fatalf("Failed to add an index: %v", err)
+	}
+	if found, err := byEmail.FindByIndex("Email", "b@example.com"); err != nil || len(found) != 1 {
+		t.Fatalf("Expected the new index to be built, got %v %v", found, err)
+	}
+
+	readOnly, err := bingo.OpenCollection[User](driver, "users", bingo.ReadOnlyCollection, bingo.SkipValidation)
+	if err != nil {
+		t.Fatalf("Failed to open read-only collection: %v", err)
+	}
+	if _, err := readOnly.Insert(User{}); !errors.Is(err, bingo.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+	if _, err := readOnly.FindByKey("user-2"); err != nil {
+		t.Fatalf("Expected reads to work on a read-only collection: %v", err)
+	}
+
+	sessions, err := bingo.OpenCollection[TestDocument](driver, "sessions", bingo.WithTTL(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	if _, err := sessions.Insert(TestDocument{Document: bingo.Document{ID: "s"}, Name: "session"}); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	if _, err := sessions.FindByKey("s"); err != nil {
+		t.Fatalf("Expected the session to be alive: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := sessions.FindByKey("s"); !bingo.IsErrDocumentNotFound(err) {
+		t.Fatalf("Expected the session to expire, got %v", err)
+	}
+
+	// purging goes through the reference policies and the delete hooks
+	logins, err := bingo.OpenCollection[Reply](driver, "logins", bingo.WithReference("ThreadID", "sessions", bingo.RefRestrict))
+	if err != nil {
+		t.Fatalf("Failed to open logins: %v", err)
+	}
+	if _, err := logins.Insert(Reply{Document: bingo.Document{ID: "l"}, ThreadID: "s"}); err != nil {
+		t.Fatalf("Failed to insert login: %v", err)
+	}
+	if _, err := sessions.PurgeExpired(); !errors.Is(err, bingo.ErrReferenced) {
+		t.Fatalf("Expected the login to restrict the purge, got %v", err)
+	}
+	if err := logins.DeleteByKey("l"); err != nil {
+		t.Fatalf("Failed to delete login: %v", err)
+	}
+	var purgedKeys []string
+	sessions.AfterDelete(func(doc *TestDocument) error {
+		purgedKeys = append(purgedKeys, doc.ID)
+		return nil
+	})
+	if purged, err := sessions.PurgeExpired(); err != nil || purged != 1 {
+		t.Fatalf("Expected 1 purged session, got %d %v", purged, err)
+	}
+	if len(purgedKeys) != 1 || purgedKeys[0] != "s" {
+		t.Fatalf("Expected the AfterDelete hook to run for the purged session, got %v", purgedKeys)
+	}
+}
@@ -1,7 +1,7 @@
 package bingo
 
 import (
-	"fmt"
+	"bytes"
 	"go.etcd.io/bbolt"
 )
 
@@ -16,10 +16,34 @@ type Query[T DocumentSpec] struct {
 	// Count specifies the maximum number of documents to be returned by the query. If set to a non-positive value, all matching documents are returned.
 	Count int
 
-	// Keys is a slice of document keys that can be used to directly retrieve specific documents from the collection. When provided, it takes precedence over the Filter function.
+	// Keys is a slice of document keys that can be used to directly retrieve specific documents from the collection. It cannot be combined with the Filter function, the query fails with an error.
 	Keys [][]byte
 	// KeysStr is a slice of document keys that can be used to directly retrieve specific documents from the collection. When provided, it takes precedence over the Filter function.
 	KeysStr []string
+	// Prefix restricts the query to the keys starting with it, such as the leading parts of keys built with Key.
+	// Start and End restrict it to the keys k with Start <= k < End, a nil bound is open.
+	// Documents are visited in reverse key order like without them, a nil Filter matches every document in the range.
+	Prefix []byte
+	Start  []byte
+	End    []byte
+
+	// Populate lists the Ref fields of the documents to populate, such as "Author". The referenced documents are read
+	// in the transaction of the query, see Ref.
+	Populate []string
+}
+
+// bounds returns the range of keys the query visits, nil bounds are open.
+func (q Query[T]) bounds() ([]byte, []byte) {
+	start, end := q.Start, q.End
+	if q.Prefix != nil {
+		if bytes.Compare(q.Prefix, start) > 0 {
+			start = q.Prefix
+		}
+		if prefixEnd := PrefixEnd(q.Prefix); prefixEnd != nil && (end == nil || bytes.Compare(prefixEnd, end) < 0) {
+			end = prefixEnd
+		}
+	}
+	return start, end
 }
 
 // QueryResult represents the result of a query operation in a collection. It contains the retrieved items, as well as metadata about the query.
@@ -38,6 +62,10 @@ type QueryResult[T DocumentSpec] struct {
 
 	// Error is an error object that may contain any errors encountered during the query operation. It represents the overall query result status.
 	Error error
+
+	// Warnings lists the corrupt documents left out of the result, when the collection uses the CorruptReport policy
+	// or when they could not be quarantined.
+	Warnings []CorruptDocument
 }
 
 // JSONResponse returns a map that can be used to generate a JSON response for the query result.
@@ -121,34 +149,42 @@ func (qr *QueryResult[T]) Delete() error {
 	if qr.Error != nil {
 		return qr.Error
 	}
-	return qr.Collection.Driver.db.Update(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(qr.Collection.nameBytes)
+	return qr.Collection.around("QueryResult.Delete", qr.delete)
+}
+
+func (qr *QueryResult[T]) delete() error {
+	c := qr.Collection
+	if c.options.ReadOnly {
+		return ErrReadOnly
+	}
+	for _, document := range qr.Items {
+		if err := c.prepare(deleteLifecycle, document); err != nil {
+			return err
+		}
+	}
+
+	err := c.update(false, func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(c.nameBytes)
 		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", qr.Collection.Name)
+			return collectionNotFound(c.Name)
 		}
 
 		for _, document := range qr.Items {
-			if qr.Collection.beforeDelete != nil {
-				err := qr.Collection.beforeDelete(document)
-				if err != nil {
-					return err
-				}
-			}
-
-			err := bucket.Delete((*document).Key())
+			err := c.remove(tx, bucket, (*document).Key(), c.options.SoftDelete)
 			if err != nil {
 				return err
 			}
 
-			if qr.Collection.afterDelete != nil {
-				err := qr.Collection.afterDelete(document)
-				if err != nil {
-					return err
-				}
+			if err := c.written(deleteLifecycle, document); err != nil {
+				return err
 			}
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	return c.committed(deleteLifecycle, qr.Items...)
 }
 
 // Update updates the items in the query result in the collection.
@@ -156,37 +192,45 @@ func (qr *QueryResult[T]) Update() error {
 	if qr.Error != nil {
 		return qr.Error
 	}
-	return qr.Collection.Driver.db.Update(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(qr.Collection.nameBytes)
+	return qr.Collection.around("QueryResult.Update", qr.update)
+}
+
+func (qr *QueryResult[T]) update() error {
+	c := qr.Collection
+	if c.options.ReadOnly {
+		return ErrReadOnly
+	}
+	for _, document := range qr.Items {
+		if err := c.prepare(updateLifecycle, document); err != nil {
+			return err
+		}
+	}
+
+	err := c.update(false, func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(c.nameBytes)
 		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", qr.Collection.Name)
+			return collectionNotFound(c.Name)
 		}
 
 		for _, document := range qr.Items {
-			if qr.Collection.beforeUpdate != nil {
-				err := qr.Collection.beforeUpdate(document)
-				if err != nil {
-					return err
-				}
-			}
-
-			data, err := Marshaller.Marshal(document)
+			data, err := c.encode(*document)
 			if err != nil {
 				return err
 			}
 
-			err = bucket.Put((*document).Key(), data)
+			err = c.put(tx, bucket, (*document).Key(), data, document)
 			if err != nil {
 				return err
 			}
 
-			if qr.Collection.afterUpdate != nil {
-				err := qr.Collection.afterUpdate(document)
-				if err != nil {
-					return err
-				}
+			if err := c.written(updateLifecycle, document); err != nil {
+				return err
 			}
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	return c.committed(updateLifecycle, qr.Items...)
 }
@@ -26,10 +26,9 @@ func TestQueryExecution(t *testing.T) {
 	// Test setup
 	collection := &Collection[MockDocument]{}
 
-	t.Run("Query panic on both Key and Filter set", func(t *testing.T) {
-		assert.Panics(t, func() {
-			collection.Query(Query[MockDocument]{Keys: [][]byte{[]byte("1")}, Filter: func(doc MockDocument) bool { return true }})
-		})
+	t.Run("Query returns error on both Key and Filter set", func(t *testing.T) {
+		result := collection.Query(Query[MockDocument]{Keys: [][]byte{[]byte("1")}, Filter: func(doc MockDocument) bool { return true }})
+		assert.EqualError(t, result.Error, "cannot use both key and filter")
 	})
 
 	t.Run("Query returns error on no Key or Filter", func(t *testing.T) {
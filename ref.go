@@ -0,0 +1,291 @@
+package bingo
+
+import (
+	"fmt"
+	"go.etcd.io/bbolt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Ref is a reference to a document of another collection. Only the key is stored, as a JSON string.
+// Doc holds the referenced document once populated, see Query.Populate. It is not stored and not part of the JSON of
+// a Ref, which is only its key, use Populated to serialize a reference along with its document.
+// Ref fields name the collection they reference in their bingo tag, and optionally what deleting the referenced
+// document does to theirs, see RefPolicy:
+//
+//	type Post struct {
+//		bingo.Document
+//		Author bingo.Ref[User] `json:"author" bingo:"ref=users,onDelete=cascade"`
+//	}
+type Ref[T DocumentSpec] struct {
+	Key string
+	Doc *T
+}
+
+// RefTo returns a populated reference to a document.
+func RefTo[T DocumentSpec](doc T) Ref[T] {
+	return Ref[T]{Key: string(doc.Key()), Doc: &doc}
+}
+
+// Get returns the referenced document, false if the reference was not populated or its document is missing.
+func (r Ref[T]) Get() (T, bool) {
+	if r.Doc == nil {
+		var empty T
+		return empty, false
+	}
+	return *r.Doc, true
+}
+
+// Populated returns a view of the reference serialized as {"key": ..., "doc": ...}, doc being null if it was not populated.
+func (r Ref[T]) Populated() PopulatedRef[T] {
+	return PopulatedRef[T]{Key: r.Key, Doc: r.Doc}
+}
+
+// PopulatedRef is a reference serialized along with its document, for responses that include populated documents.
+type PopulatedRef[T DocumentSpec] struct {
+	Key string `json:"key"`
+	Doc *T     `json:"doc"`
+}
+
+// IsZero reports whether the reference is unset.
+func (r Ref[T]) IsZero() bool {
+	return r.Key == ""
+}
+
+func (r Ref[T]) MarshalJSON() ([]byte, error) {
+	if r.Key == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(r.Key)
+}
+
+func (r *Ref[T]) UnmarshalJSON(data []byte) error {
+	r.Doc = nil
+	if string(data) == "null" {
+		r.Key = ""
+		return nil
+	}
+	return json.Unmarshal(data, &r.Key)
+}
+
+// reference is implemented by *Ref of every document type, so references are resolved without knowing their type.
+type reference interface {
+	refKey() []byte
+	// read reads the documents of a collection stored under the keys by key, see readerOf.
+	read(tx *bbolt.Tx, d *Driver, collection string, keys [][]byte, after *populated) (map[string]any, error)
+	set(doc any)
+}
+
+func (r *Ref[T]) refKey() []byte {
+	return []byte(r.Key)
+}
+
+func (r *Ref[T]) read(tx *bbolt.Tx, d *Driver, collection string, keys [][]byte, after *populated) (map[string]any, error) {
+	coll := readerOf[T](d, collection)
+	var corrupt []CorruptDocument
+	docs, found, err := coll.readKeys(tx, keys, &corrupt)
+	if len(corrupt) > 0 {
+		*after = append(*after, func() []CorruptDocument { return coll.handleCorrupt(corrupt) })
+	}
+	byKey := make(map[string]any, len(docs))
+	for i := range docs {
+		byKey[string(found[i])] = docs[i]
+	}
+	return byKey, err
+}
+
+func (r *Ref[T]) set(doc any) {
+	d := doc.(T)
+	r.Doc = &d
+}
+
+// populated holds what populating references leaves to do once the transaction is closed, handling the corrupt
+// documents found by the collections they were read through.
+type populated []func() []CorruptDocument
+
+// handleCorrupt handles the corrupt documents read while populating and returns the ones to list as warnings.
+func (p populated) handleCorrupt() []CorruptDocument {
+	var warnings []CorruptDocument
+	for _, handle := range p {
+		warnings = append(warnings, handle()...)
+	}
+	return warnings
+}
+
+// readerOf returns the collection references to a collection are read through: the collection last opened under
+// that name with the document type, so its AfterFind hooks and corrupt policy apply, or a bare collection if there is none.
+func readerOf[T DocumentSpec](d *Driver, name string) *Collection[T] {
+	d.mu.RLock()
+	reader, ok := d.readers[name].(*Collection[T])
+	d.mu.RUnlock()
+	if ok {
+		return reader
+	}
+	return &Collection[T]{Driver: d, Name: name, nameBytes: []byte(name), hooks: &hookChain[T]{}, codec: d.codecOf(name)}
+}
+
+var referenceType = reflect.TypeOf((*reference)(nil)).Elem()
+
+// refField is a Ref or []Ref field of a document type.
+type refField struct {
+	name       string
+	path       string
+	index      []int
+	slice      bool
+	collection string
+	onDelete   RefPolicy
+}
+
+var refFields sync.Map
+
+// refFieldsOf returns the reference fields of a document type by field name, failing on references without a collection.
+func refFieldsOf(typ reflect.Type) (map[string]refField, error) {
+	if fields, ok := refFields.Load(typ); ok {
+		return fields.(map[string]refField), nil
+	}
+	fields := map[string]refField{}
+	if err := collectRefFields(typ, nil, fields); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidDocumentType, typ, err)
+	}
+	refFields.Store(typ, fields)
+	return fields, nil
+}
+
+func collectRefFields(typ reflect.Type, parent []int, fields map[string]refField) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+		ref := refField{name: field.Name, path: storedName(field), index: index, onDelete: RefRestrict}
+		switch {
+		case reflect.PtrTo(field.Type).Implements(referenceType):
+		case field.Type.Kind() == reflect.Slice && reflect.PtrTo(field.Type.Elem()).Implements(referenceType):
+			ref.slice = true
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			if err := collectRefFields(field.Type, index, fields); err != nil {
+				return err
+			}
+			continue
+		default:
+			continue
+		}
+		for _, property := range strings.Split(field.Tag.Get("bingo"), ",") {
+			switch {
+			case strings.HasPrefix(property, "ref="):
+				ref.collection = strings.TrimPrefix(property, "ref=")
+			case strings.HasPrefix(property, "onDelete="):
+				ref.onDelete = RefPolicy(strings.TrimPrefix(property, "onDelete="))
+				if !ref.onDelete.valid() {
+					return fmt.Errorf("reference %s has an unknown onDelete policy %q", field.Name, ref.onDelete)
+				}
+			}
+		}
+		if ref.collection == "" {
+			return fmt.Errorf("reference %s does not name its collection with a bingo:\"ref=<collection>\" tag", field.Name)
+		}
+		fields[field.Name] = ref
+	}
+	return nil
+}
+
+// references returns the references a field holds in a document.
+func (f refField) references(doc reflect.Value) []reference {
+	value := doc.FieldByIndex(f.index)
+	if !f.slice {
+		return []reference{value.Addr().Interface().(reference)}
+	}
+	refs := make([]reference, value.Len())
+	for i := range refs {
+		refs[i] = value.Index(i).Addr().Interface().(reference)
+	}
+	return refs
+}
+
+// populate resolves the reference fields of documents, reading the referenced documents in tx through their
+// collection like Join, see readerOf. References to missing, expired or skipped corrupt documents are left unpopulated.
+// The corrupt documents found are handled by after once tx is closed.
+func (c *Collection[T]) populate(tx *bbolt.Tx, docs []T, fields []string, after *populated) error {
+	if len(fields) == 0 || len(docs) == 0 {
+		return nil
+	}
+	refs, err := refFieldsOf(reflect.TypeOf(docs[0]))
+	if err != nil {
+		return err
+	}
+	for _, name := range fields {
+		field, ok := refs[name]
+		if !ok {
+			return fmt.Errorf("%v has no reference field %s", reflect.TypeOf(docs[0]), name)
+		}
+		// every key is read once, however many documents reference it
+		var refs []reference
+		var keys [][]byte
+		seen := map[string]bool{}
+		for i := range docs {
+			for _, ref := range field.references(reflect.ValueOf(&docs[i]).Elem()) {
+				key := ref.refKey()
+				if len(key) == 0 {
+					continue
+				}
+				refs = append(refs, ref)
+				if !seen[string(key)] {
+					seen[string(key)] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		byKey, err := refs[0].read(tx, c.Driver, field.collection, keys, after)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			if doc, ok := byKey[string(ref.refKey())]; ok {
+				ref.set(doc)
+			}
+		}
+	}
+	return nil
+}
+
+// Join returns the documents of coll referenced by the items of a query result, in the order of the items.
+// Unlike Query.Populate, which reads through the collection last opened under the referenced name, it reads through coll.
+// key returns the key an item references, items referencing a missing document or no key get nil.
+// The referenced documents are read in a single transaction and run the AfterFind hooks of coll.
+func Join[T, U DocumentSpec](qr *QueryResult[T], coll *Collection[U], key func(doc *T) []byte) ([]*U, error) {
+	if qr.Error != nil {
+		return nil, qr.Error
+	}
+	joined := make([]*U, len(qr.Items))
+	var corrupt []CorruptDocument
+	var failed error
+	err := coll.Driver.view(func(tx *bbolt.Tx) error {
+		keys := make([][]byte, len(qr.Items))
+		var unique [][]byte
+		seen := map[string]bool{}
+		for i, item := range qr.Items {
+			keys[i] = key(item)
+			if len(keys[i]) > 0 && !seen[string(keys[i])] {
+				seen[string(keys[i])] = true
+				unique = append(unique, keys[i])
+			}
+		}
+		docs, found, err := coll.readKeys(tx, unique, &corrupt)
+		failed = err
+		byKey := map[string]*U{}
+		for i := range docs {
+			byKey[string(found[i])] = &docs[i]
+		}
+		for i, k := range keys {
+			joined[i] = byKey[string(k)]
+		}
+		return nil
+	})
+	coll.handleCorrupt(corrupt)
+	if err != nil {
+		return nil, err
+	}
+	return joined, failed
+}
@@ -0,0 +1,130 @@
+package bingo_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/nokusukun/bingo"
+	"os"
+	"strings"
+	"testing"
+)
+
+type Author struct {
+	bingo.Document
+	Name string `json:"name"`
+}
+
+type Post struct {
+	bingo.Document
+	Title    string              `json:"title"`
+	Author   bingo.Ref[Author]   `json:"author" bingo:"ref=authors"`
+	Reviewer bingo.Ref[Author]   `json:"reviewer" bingo:"ref=authors"`
+	Editors  []bingo.Ref[Author] `json:"editors" bingo:"ref=authors"`
+}
+
+type Untagged struct {
+	bingo.Document
+	Author bingo.Ref[Author] `json:"author"`
+}
+
+func TestReferences(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testrefs.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testrefs.db")
+	}()
+
+	if _, err := bingo.OpenCollection[Untagged](driver, "untagged"); !errors.Is(err, bingo.ErrInvalidDocumentType) {
+		t.Fatalf("Expected a reference without a collection to be rejected, got %v", err)
+	}
+
+	authors := bingo.CollectionFrom[Author](driver, "authors")
+	posts := bingo.CollectionFrom[Post](driver, "posts")
+	ann := Author{Document: bingo.Document{ID: "ann"}, Name: "Ann"}
+	bob := Author{Document: bingo.Document{ID: "bob"}, Name: "Bob"}
+	if _, err := authors.InsertMany([]Author{ann, bob}); err != nil {
+		t.Fatalf("Failed to insert authors: %v", err)
+	}
+	_, err = posts.InsertMany([]Post{
+		{Document: bingo.Document{ID: "1"}, Title: "First", Author: bingo.RefTo(ann), Editors: []bingo.Ref[Author]{bingo.RefTo(bob), {Key: "gone"}}},
+		{Document: bingo.Document{ID: "2"}, Title: "Second", Author: bingo.Ref[Author]{Key: "bob"}, Reviewer: bingo.RefTo(ann)},
+		{Document: bingo.Document{ID: "3"}, Title: "Orphan", Author: bingo.Ref[Author]{Key: "gone"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert posts: %v", err)
+	}
+
+	// only the key is stored
+	post, err := posts.FindByKey("1")
+	if err != nil || post.Author.Key != "ann" || post.Author.Doc != nil || len(post.Editors) != 2 {
+		t.Fatalf("Expected an unpopulated reference, got %+v %v", post, err)
+	}
+
+	result := posts.Query(bingo.Query[Post]{
+		Filter:   func(p Post) bool { return true },
+		Populate: []string{"Author", "Editors"},
+	})
+	if result.Error != nil || result.Count() != 3 {
+		t.Fatalf("Failed to query posts: %v", result.Error)
+	}
+	byID := map[string]*Post{}
+	for _, p := range result.Items {
+		byID[p.ID] = p
+	}
+	if author, ok := byID["1"].Author.Get(); !ok || author.Name != "Ann" {
+		t.Fatalf("Expected the author of post 1 to be populated, got %+v", byID["1"].Author)
+	}
+	if editor, ok := byID["1"].Editors[0].Get(); !ok || editor.Name != "Bob" || byID["1"].Editors[1].Doc != nil {
+		t.Fatalf("Expected the existing editors to be populated, got %+v", byID["1"].Editors)
+	}
+	if byID["2"].Reviewer.Doc != nil {
+		t.Fatalf("Expected fields not listed to stay unpopulated")
+	}
+	if _, ok := byID["3"].Author.Get(); ok {
+		t.Fatalf("Expected a dangling reference to stay unpopulated")
+	}
+
+	byKeys := posts.Query(bingo.Query[Post]{KeysStr: []string{"2"}, Populate: []string{"Reviewer"}})
+	if reviewer, ok := byKeys.First().Reviewer.Get(); byKeys.Error != nil || !ok || reviewer.Name != "Ann" {
+		t.Fatalf("Expected key queries to populate, got %+v %v", byKeys.First(), byKeys.Error)
+	}
+	if result := posts.Query(bingo.Query[Post]{KeysStr: []string{"2"}, Populate: []string{"Title"}}); result.Error == nil {
+		t.Fatalf("Expected populating a field that is not a reference to fail")
+	}
+
+	reads := 0
+	authors.AfterFind(func(doc *Author) error {
+		reads += 1
+		return nil
+	})
+	joined, err := bingo.Join(result, authors, func(p *Post) []byte {
+		return []byte(p.Author.Key)
+	})
+	if err != nil || len(joined) != 3 {
+		t.Fatalf("Failed to join authors: %v", err)
+	}
+	for i, p := range result.Items {
+		if p.ID == "3" && joined[i] != nil || p.ID != "3" && (joined[i] == nil || joined[i].ID != p.Author.Key) {
+			t.Fatalf("Expected the author of post %s to be joined, got %v", p.ID, joined[i])
+		}
+	}
+	if reads != 2 {
+		t.Fatalf("Expected every author to be read once, got %d reads", reads)
+	}
+
+	populated := posts.Query(bingo.Query[Post]{KeysStr: []string{"1"}, Populate: []string{"Author"}})
+	if populated.Error != nil || reads != 3 {
+		t.Fatalf("Expected populating to run the AfterFind hooks of authors, got %d reads %v", reads, populated.Error)
+	}
+	data, err := json.Marshal(populated.First().Author.Populated())
+	if err != nil || !strings.Contains(string(data), `"key":"ann"`) || !strings.Contains(string(data), `"name":"Ann"`) {
+		t.Fatalf("Expected the populated reference to include its document, got %s %v", data, err)
+	}
+}
@@ -0,0 +1,268 @@
+package bingo
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const SCHEMA_COLLECTION_NAME = "__schema:"
+
+var ErrSchemaDrift = fmt.Errorf("schema drift detected")
+
+// IsErrSchemaDrift returns true if the error is an ErrSchemaDrift error.
+func IsErrSchemaDrift(err error) bool {
+	return errors.Is(err, ErrSchemaDrift)
+}
+
+// SchemaPolicy decides what happens when the stored schema of a collection differs from its current Go type.
+type SchemaPolicy int
+
+const (
+	// SchemaRecord silently records the current schema, replacing the stored one. This is the default.
+	SchemaRecord SchemaPolicy = iota
+	// SchemaWarn reports the drift through DriverConfiguration.OnSchemaDrift (or the standard logger) and keeps the stored schema.
+	SchemaWarn
+	// SchemaError refuses to open the collection when the schema has drifted.
+	SchemaError
+)
+
+// SchemaField describes a single field of a document type.
+// Path is the dotted Go field path, nested structs are flattened into their own entries.
+type SchemaField struct {
+	Path    string   `json:"path" bingo_json:"path"`
+	Aliases []string `json:"aliases,omitempty" bingo_json:"aliases,omitempty"`
+	Kind    string   `json:"kind" bingo_json:"kind"`
+}
+
+// SchemaChange describes a field whose kind differs between the stored and the current schema.
+type SchemaChange struct {
+	Path string
+	Old  string
+	New  string
+}
+
+// SchemaDiff is the result of comparing a stored schema against the current Go type of a collection.
+type SchemaDiff struct {
+	Collection string
+	Added      []SchemaField
+	Removed    []SchemaField
+	Retyped    []SchemaChange
+}
+
+// Empty returns true if the stored and current schema are equivalent.
+func (d *SchemaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Retyped) == 0
+}
+
+func (d *SchemaDiff) String() string {
+	var parts []string
+	for _, f := range d.Added {
+		parts = append(parts, fmt.Sprintf("+%s (%s)", f.Path, f.Kind))
+	}
+	for _, f := range d.Removed {
+		parts = append(parts, fmt.Sprintf("-%s (%s)", f.Path, f.Kind))
+	}
+	for _, c := range d.Retyped {
+		parts = append(parts, fmt.Sprintf("~%s (%s -> %s)", c.Path, c.Old, c.New))
+	}
+	return fmt.Sprintf("%s: %s", d.Collection, strings.Join(parts, ", "))
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// SchemaOf returns the flattened field list of a document type.
+func SchemaOf(typ reflect.Type) []SchemaField {
+	var fields []SchemaField
+	schemaFields(typ, "", &fields, map[reflect.Type]bool{})
+	return fields
+}
+
+func schemaFields(typ reflect.Type, prefix string, fields *[]SchemaField, seen map[reflect.Type]bool) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] {
+		return
+	}
+	seen[typ] = true
+	defer delete(seen, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && isNestedStruct(field.Type) {
+			schemaFields(field.Type, prefix, fields, seen)
+			continue
+		}
+		path := prefix + field.Name
+		var aliases []string
+		for _, key := range []string{"json", "bingo_json"} {
+			if tag := strings.Split(field.Tag.Get(key), ",")[0]; tag != "" && tag != "-" && tag != field.Name && !slices.Contains(aliases, tag) {
+				aliases = append(aliases, tag)
+			}
+		}
+		*fields = append(*fields, SchemaField{Path: path, Aliases: aliases, Kind: kindOf(field.Type)})
+		if isNestedStruct(field.Type) {
+			schemaFields(field.Type, path+".", fields, seen)
+		}
+	}
+}
+
+// isNestedStruct returns true for struct types whose fields are stored individually,
+// types that marshal themselves (time.Time and friends) are treated as scalars.
+func isNestedStruct(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	return !typ.Implements(textMarshalerType) && !reflect.PointerTo(typ).Implements(textMarshalerType)
+}
+
+func kindOf(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return "ptr<" + kindOf(typ.Elem()) + ">"
+	case reflect.Slice:
+		return "slice<" + kindOf(typ.Elem()) + ">"
+	case reflect.Array:
+		return fmt.Sprintf("array<%d,%s>", typ.Len(), kindOf(typ.Elem()))
+	case reflect.Map:
+		return "map<" + kindOf(typ.Key()) + "," + kindOf(typ.Elem()) + ">"
+	case reflect.Struct:
+		if !isNestedStruct(typ) {
+			return typ.String()
+		}
+	}
+	return typ.Kind().String()
+}
+
+// DiffSchema compares a stored schema against the current one.
+// Fields are matched by path, or by any of their aliases so renaming a Go field that keeps its json tag is not reported.
+func DiffSchema(collection string, stored, current []SchemaField) *SchemaDiff {
+	diff := &SchemaDiff{Collection: collection}
+	matched := map[int]bool{}
+	for _, cur := range current {
+		idx := findSchemaField(stored, cur)
+		if idx < 0 {
+			diff.Added = append(diff.Added, cur)
+			continue
+		}
+		matched[idx] = true
+		if stored[idx].Kind != cur.Kind {
+			diff.Retyped = append(diff.Retyped, SchemaChange{Path: cur.Path, Old: stored[idx].Kind, New: cur.Kind})
+		}
+	}
+	for i, old := range stored {
+		if !matched[i] {
+			diff.Removed = append(diff.Removed, old)
+		}
+	}
+	sort.Slice(diff.Retyped, func(i, j int) bool {
+		return diff.Retyped[i].Path < diff.Retyped[j].Path
+	})
+	return diff
+}
+
+func findSchemaField(fields []SchemaField, f SchemaField) int {
+	for i, s := range fields {
+		if s.Path == f.Path {
+			return i
+		}
+	}
+	for i, s := range fields {
+		if strings.Count(s.Path, ".") != strings.Count(f.Path, ".") {
+			continue
+		}
+		for _, alias := range f.Aliases {
+			if slices.Contains(s.Aliases, alias) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (d *Driver) readSchema(name string) ([]SchemaField, error) {
+	var raw []byte
+	err := d.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(METADATA_COLLECTION_NAME))
+		if bucket == nil {
+			return nil
+		}
+		if v := bucket.Get([]byte(SCHEMA_COLLECTION_NAME + name)); v != nil {
+			raw = append(raw, v...)
+		}
+		return nil
+	})
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	var metadata struct {
+		V []SchemaField
+	}
+	if err := Unmarshaller.Unmarshal(raw, &metadata); err != nil {
+		return nil, fmt.Errorf("unable to read schema of %s: %w", name, err)
+	}
+	return metadata.V, nil
+}
+
+// CheckSchema compares the schema recorded for the collection against typ.
+// A nil diff is returned if no schema has been recorded yet.
+func (d *Driver) CheckSchema(name string, typ reflect.Type) (*SchemaDiff, error) {
+	stored, err := d.readSchema(name)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, nil
+	}
+	return DiffSchema(name, stored, SchemaOf(typ)), nil
+}
+
+// CheckSchema compares the schema recorded for the collection against its document type.
+func (c *Collection[T]) CheckSchema() (*SchemaDiff, error) {
+	var o T
+	return c.Driver.CheckSchema(c.Name, reflect.TypeOf(o))
+}
+
+// RecordSchema stores the schema of typ as the reference schema of the collection.
+func (d *Driver) RecordSchema(name string, typ reflect.Type) error {
+	return d.WriteMetadata(SCHEMA_COLLECTION_NAME+name, SchemaOf(typ))
+}
+
+// applySchemaPolicy checks for drift and records or reports it according to DriverConfiguration.SchemaPolicy.
+func (d *Driver) applySchemaPolicy(name string, typ reflect.Type) error {
+	diff, err := d.CheckSchema(name, typ)
+	if err != nil {
+		return err
+	}
+	if diff != nil && !diff.Empty() {
+		if d.config.OnSchemaDrift != nil {
+			d.config.OnSchemaDrift(diff)
+		}
+		switch d.config.SchemaPolicy {
+		case SchemaError:
+			return fmt.Errorf("%w: %v", ErrSchemaDrift, diff)
+		case SchemaWarn:
+			if d.config.OnSchemaDrift == nil {
+				log.Printf("bingo: %v: %v", ErrSchemaDrift, diff)
+			}
+			return nil
+		}
+	}
+	if (diff != nil && diff.Empty()) || d.config.ReadOnly {
+		return nil
+	}
+	return d.RecordSchema(name, typ)
+}
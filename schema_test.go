@@ -0,0 +1,92 @@
+package bingo_test
+
+import (
+	"github.com/nokusukun/bingo"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type SchemaV1 struct {
+	bingo.Document
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Address struct {
+		City string `json:"city"`
+	} `json:"address"`
+}
+
+type SchemaV2 struct {
+	bingo.Document
+	FullName string `json:"name"`
+	Age      string `json:"age"`
+	Email    string `json:"email"`
+	Address  struct {
+		Zip string `json:"zip"`
+	} `json:"address"`
+}
+
+func TestSchemaDrift(t *testing.T) {
+	var diffs []*bingo.SchemaDiff
+	config := bingo.DriverConfiguration{
+		Filename:       "testschema.db",
+		DeleteNoVerify: true,
+		SchemaPolicy:   bingo.SchemaError,
+		OnSchemaDrift: func(diff *bingo.SchemaDiff) {
+			diffs = append(diffs, diff)
+		},
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+
+	defer func() {
+		driver.Close()
+		os.Remove("testschema.db")
+	}()
+
+	bingo.CollectionFrom[SchemaV1](driver, "people")
+	bingo.CollectionFrom[SchemaV1](driver, "people")
+	if len(diffs) != 0 {
+		t.Fatalf("Unexpected drift for unchanged type: %v", diffs)
+	}
+
+	coll := bingo.CollectionFrom[SchemaV1](driver, "people")
+	diff, err := driver.CheckSchema("people", reflectTypeOf[SchemaV2]())
+	if err != nil {
+		t.Fatalf("Failed to check schema: %v", err)
+	}
+
+	if len(diff.Added) != 2 || diff.Added[0].Path != "Email" || diff.Added[1].Path != "Address.Zip" {
+		t.Fatalf("Unexpected added fields: %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Path != "Address.City" {
+		t.Fatalf("Unexpected removed fields: %v", diff.Removed)
+	}
+	if len(diff.Retyped) != 1 || diff.Retyped[0].Path != "Age" || diff.Retyped[0].Old != "int" || diff.Retyped[0].New != "string" {
+		t.Fatalf("Unexpected retyped fields: %v", diff.Retyped)
+	}
+
+	current, err := coll.CheckSchema()
+	if err != nil || !current.Empty() {
+		t.Fatalf("Expected no drift for the recorded type, got %v (%v)", current, err)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatalf("Expected CollectionFrom to refuse a drifted schema")
+			}
+		}()
+		bingo.CollectionFrom[SchemaV2](driver, "people")
+	}()
+	if len(diffs) != 1 {
+		t.Fatalf("Expected drift to be reported once, got %d", len(diffs))
+	}
+}
+
+func reflectTypeOf[T any]() reflect.Type {
+	var o T
+	return reflect.TypeOf(o)
+}
@@ -0,0 +1,153 @@
+package bingo
+
+import (
+	"fmt"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CollectionStats describes the space used by a collection.
+// Bytes is the space used by keys and documents, Allocated the space of the pages holding them.
+type CollectionStats struct {
+	Name          string
+	Keys          int
+	Bytes         int
+	Allocated     int
+	LeafPages     int
+	BranchPages   int
+	OverflowPages int
+	Depth         int
+}
+
+// DatabaseStats describes the space used by the database file.
+// FreePages are reusable pages, PendingPages are freed pages still referenced by open read transactions.
+type DatabaseStats struct {
+	Size         int64
+	PageSize     int
+	FreePages    int
+	PendingPages int
+	FreeBytes    int
+	Collections  []CollectionStats
+}
+
+// Stats returns the space usage of the database and of every collection, including internal ones.
+func (d *Driver) Stats() (*DatabaseStats, error) {
+	stats := &DatabaseStats{}
+	err := d.view(func(tx *bbolt.Tx) error {
+		dbStats := tx.DB().Stats()
+		stats.PageSize = tx.DB().Info().PageSize
+		stats.FreePages = dbStats.FreePageN
+		stats.PendingPages = dbStats.PendingPageN
+		stats.FreeBytes = dbStats.FreeAlloc
+		stats.Size = tx.Size()
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			s := b.Stats()
+			stats.Collections = append(stats.Collections, CollectionStats{
+				Name:          string(name),
+				Keys:          s.KeyN,
+				Bytes:         s.LeafInuse + s.BranchInuse + s.InlineBucketInuse,
+				Allocated:     s.LeafAlloc + s.BranchAlloc,
+				LeafPages:     s.LeafPageN,
+				BranchPages:   s.BranchPageN,
+				OverflowPages: s.LeafOverflowN + s.BranchOverflowN,
+				Depth:         s.Depth,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stats.Collections, func(i, j int) bool {
+		return stats.Collections[i].Name < stats.Collections[j].Name
+	})
+	return stats, nil
+}
+
+// CollectionStats returns the space usage of a single collection.
+func (d *Driver) CollectionStats(name string) (*CollectionStats, error) {
+	stats, err := d.Stats()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stats.Collections {
+		if s.Name == name {
+			return &s, nil
+		}
+	}
+	return nil, collectionNotFound(name)
+}
+
+// Compact rewrites the database into a new file at dst, dropping the free pages left by deletes.
+// The driver keeps serving reads and writes, writes made while compacting are not part of the copy.
+func (d *Driver) Compact(dst string) error {
+	d.swap.RLock()
+	defer d.swap.RUnlock()
+	if d.Closed {
+		return ErrDriverClosed
+	}
+	return d.compact(dst)
+}
+
+// compact rewrites the database into a new file at dst, the caller holds the swap lock.
+func (d *Driver) compact(dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	mode := d.config.FileMode
+	if mode == 0 {
+		mode = 0600
+	}
+	out, err := bbolt.Open(dst, mode, &bbolt.Options{NoSync: true})
+	if err != nil {
+		return err
+	}
+	if err := bbolt.Compact(out, d.db, 65536); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// CompactInPlace compacts the database file and swaps the compacted copy in.
+// The database is closed while the files are swapped and reopened with the same configuration. Transactions of other
+// goroutines wait until CompactInPlace returns, so no write is lost, transactions started with Begin must be finished first.
+func (d *Driver) CompactInPlace() error {
+	if d.config.ReadOnly {
+		return ErrReadOnly
+	}
+	d.swap.Lock()
+	defer d.swap.Unlock()
+	if d.Closed {
+		return ErrDriverClosed
+	}
+	tmp := filepath.Join(filepath.Dir(d.config.Filename), "."+filepath.Base(d.config.Filename)+".compact")
+	os.Remove(tmp)
+	if err := d.compact(tmp); err != nil {
+		return err
+	}
+
+	if err := d.db.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	renameErr := os.Rename(tmp, d.config.Filename)
+	db, err := d.config.open()
+	if err != nil {
+		d.Closed = true
+		return fmt.Errorf("unable to reopen %s after compacting: %w", d.config.Filename, err)
+	}
+	d.db = db
+	if renameErr != nil {
+		os.Remove(tmp)
+	}
+	return renameErr
+}
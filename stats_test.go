@@ -0,0 +1,119 @@
+package bingo_test
+
+import (
+	"fmt"
+	"github.com/nokusukun/bingo"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStatsAndCompact(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "teststats.db",
+		DeleteNoVerify: true,
+		Durability:     bingo.DurabilityEphemeral,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("teststats.db")
+		os.Remove("teststats-compact.db")
+	}()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "stats")
+	var docs []TestDocument
+	for i := 0; i < 2000; i++ {
+		docs = append(docs, TestDocument{Document: bingo.Document{ID: fmt.Sprintf("doc-%05d", i)}, Name: strings.Repeat("x", 500)})
+	}
+	if _, err := coll.InsertMany(docs); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+
+	stats, err := driver.CollectionStats("stats")
+	if err != nil || stats.Keys != 2000 || stats.Bytes < 2000*500 || stats.LeafPages == 0 || stats.Depth < 2 {
+		t.Fatalf("Unexpected collection stats: %+v %v", stats, err)
+	}
+	if _, err := driver.CollectionStats("missing"); err == nil {
+		t.Fatalf("Expected an error for a missing collection")
+	}
+
+	if err := coll.DeleteIter(func(doc *TestDocument) bool { return doc.ID >= "doc-00100" }); err != nil {
+		t.Fatalf("Failed to delete documents: %v", err)
+	}
+	dbStats, err := driver.Stats()
+	if err != nil || dbStats.FreePages+dbStats.PendingPages == 0 || dbStats.PageSize == 0 {
+		t.Fatalf("Expected free pages after deleting, got %+v %v", dbStats, err)
+	}
+	before, _ := os.Stat("teststats.db")
+
+	if err := driver.Compact("teststats-compact.db"); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if err := driver.Compact("teststats-compact.db"); err == nil {
+		t.Fatalf("Expected compacting over an existing file to fail")
+	}
+	compacted, _ := os.Stat("teststats-compact.db")
+	if compacted.Size() >= before.Size() {
+		t.Fatalf("Expected the compacted file to be smaller, %d >= %d", compacted.Size(), before.Size())
+	}
+
+	if err := driver.CompactInPlace(); err != nil {
+		t.Fatalf("Failed to compact in place: %v", err)
+	}
+	after, _ := os.Stat("teststats.db")
+	if after.Size() >= before.Size() {
+		t.Fatalf("Expected the database file to shrink, %d >= %d", after.Size(), before.Size())
+	}
+	if doc, err := coll.FindByKey("doc-00042"); err != nil || doc.Name == "" {
+		t.Fatalf("Expected documents to survive compaction, got %v", err)
+	}
+	if _, err := coll.Insert(TestDocument{Name: "after compaction"}); err != nil {
+		t.Fatalf("Expected the driver to be writable after compaction: %v", err)
+	}
+	if stats, _ := driver.CollectionStats("stats"); stats.Keys != 101 {
+		t.Fatalf("Expected 101 documents, got %d", stats.Keys)
+	}
+}
+
+func TestCompactInPlaceWithWriters(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testcompactwriters.db",
+		DeleteNoVerify: true,
+		Durability:     bingo.DurabilityEphemeral,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testcompactwriters.db")
+	}()
+
+	coll := bingo.CollectionFrom[TestDocument](driver, "writers")
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 300; i++ {
+			if _, err := coll.Insert(TestDocument{Document: bingo.Document{ID: fmt.Sprintf("doc-%05d", i)}, Name: "written"}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for i := 0; i < 5; i++ {
+		if err := driver.CompactInPlace(); err != nil {
+			t.Fatalf("Failed to compact in place: %v", err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to insert while compacting: %v", err)
+	}
+	if stats, err := driver.CollectionStats("writers"); err != nil || stats.Keys != 300 {
+		t.Fatalf("Expected every write to survive compaction, got %+v %v", stats, err)
+	}
+}
@@ -0,0 +1,127 @@
+package bingo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+)
+
+// Validatable is implemented by documents validating themselves beyond their validate tags.
+// Validate is called once the tags of the document are valid, a FieldError it returns is reported with the fields of
+// the ValidationError.
+type Validatable interface {
+	Validate() error
+}
+
+// RegisterValidation makes a validation function available to the validate tags of the documents under a tag.
+// It must be called before documents are written, collections using their own validator, see WithValidator, are not
+// affected.
+//
+//	driver.RegisterValidation("even", func(fl validator.FieldLevel) bool {
+//		return fl.Field().Int()%2 == 0
+//	})
+func (d *Driver) RegisterValidation(tag string, fn validator.Func) error {
+	return d.val.RegisterValidation(tag, fn)
+}
+
+// RegisterStructValidation registers a struct level validation function for the types of the given values, it is
+// called for every document of those types. Like RegisterValidation it must be called before documents are written.
+func (d *Driver) RegisterStructValidation(fn validator.StructLevelFunc, types ...any) {
+	d.val.RegisterStructValidation(fn, types...)
+}
+
+// validateDocument validates a document with a validator, then with its Validate method if it has one.
+func validateDocument(v *validator.Validate, doc any) error {
+	if err := v.Struct(doc); err != nil {
+		return err
+	}
+	if validatable, ok := doc.(Validatable); ok {
+		return validatable.Validate()
+	}
+	if ptr := reflect.New(reflect.TypeOf(doc)); ptr.Type().Implements(reflect.TypeOf((*Validatable)(nil)).Elem()) {
+		ptr.Elem().Set(reflect.ValueOf(doc))
+		return ptr.Interface().(Validatable).Validate()
+	}
+	return nil
+}
+
+// fieldErrors converts the errors of the validator or of a Validate method into field errors.
+func fieldErrors(err error) []FieldError {
+	var fields []FieldError
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		for _, f := range ve {
+			fields = append(fields, FieldError{
+				Field:     f.Field(),
+				Namespace: f.Namespace(),
+				Tag:       f.Tag(),
+				Param:     f.Param(),
+				Value:     f.Value(),
+				Message:   fieldMessage(f),
+			})
+		}
+		return fields
+	}
+	var fe FieldError
+	if errors.As(err, &fe) {
+		if fe.Namespace == "" {
+			fe.Namespace = fe.Field
+		}
+		fields = append(fields, fe)
+	}
+	return fields
+}
+
+// fieldMessage describes a failed validation in plain words, such as "Name is required".
+func fieldMessage(f validator.FieldError) string {
+	param := f.Param()
+	unit := ""
+	switch f.Kind() {
+	case reflect.String:
+		unit = " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+	var message string
+	switch tag := f.Tag(); {
+	case strings.HasPrefix(tag, "required"):
+		message = "is required"
+	case tag == "min" || tag == "gte":
+		message = "must be at least " + param + unit
+	case tag == "max" || tag == "lte":
+		message = "must be at most " + param + unit
+	case tag == "len":
+		message = "must be exactly " + param + unit
+	case tag == "gt":
+		message = "must be more than " + param + unit
+	case tag == "lt":
+		message = "must be less than " + param + unit
+	case tag == "eq":
+		message = "must be equal to " + param
+	case tag == "ne":
+		message = "must not be equal to " + param
+	case tag == "oneof":
+		message = "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case tag == "email":
+		message = "must be a valid email address"
+	case tag == "url" || tag == "uri":
+		message = "must be a valid URL"
+	case strings.HasPrefix(tag, "uuid"):
+		message = "must be a valid UUID"
+	case tag == "ip" || tag == "ipv4" || tag == "ipv6":
+		message = "must be a valid IP address"
+	case tag == "alpha":
+		message = "must only contain letters"
+	case tag == "alphanum":
+		message = "must only contain letters and numbers"
+	case tag == "numeric" || tag == "number":
+		message = "must be a number"
+	case param != "":
+		message = fmt.Sprintf("failed the %s=%s validation", tag, param)
+	default:
+		message = fmt.Sprintf("failed the %s validation", tag)
+	}
+	return f.Field() + " " + message
+}
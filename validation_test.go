@@ -0,0 +1,119 @@
+package bingo_test
+
+import (
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/nokusukun/bingo"
+	"os"
+	"strings"
+	"testing"
+)
+
+type Account struct {
+	bingo.Document
+	Name  string `json:"name" validate:"required,min=3"`
+	Seats int    `json:"seats" validate:"even"`
+	Plan  string `json:"plan"`
+	Owner string `json:"owner"`
+}
+
+func (a *Account) Validate() error {
+	if a.Plan == "free" && a.Seats > 2 {
+		return bingo.FieldError{Field: "Seats", Tag: "plan", Message: "Seats must be at most 2 on the free plan"}
+	}
+	return nil
+}
+
+func TestValidation(t *testing.T) {
+	config := bingo.DriverConfiguration{
+		Filename:       "testvalidation.db",
+		DeleteNoVerify: true,
+	}
+	driver, err := bingo.NewDriver(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize driver: %v", err)
+	}
+	defer func() {
+		driver.Close()
+		os.Remove("testvalidation.db")
+	}()
+
+	err = driver.RegisterValidation("even", func(fl validator.FieldLevel) bool {
+		return fl.Field().Int()%2 == 0
+	})
+	if err != nil {
+		t.Fatalf("Failed to register validation: %v", err)
+	}
+	driver.RegisterStructValidation(func(sl validator.StructLevel) {
+		account := sl.Current().Interface().(Account)
+		if account.Owner == account.Name {
+			sl.ReportError(account.Owner, "Owner", "Owner", "nefield", "Name")
+		}
+	}, Account{})
+
+	accounts := bingo.CollectionFrom[Account](driver, "accounts")
+	fields := func(err error) string {
+		var verr *bingo.ValidationError
+		if !errors.As(err, &verr) {
+			return "not a validation error: " + err.Error()
+		}
+		var messages []string
+		for _, f := range verr.Fields {
+			messages = append(messages, f.Error())
+		}
+		return strings.Join(messages, "; ")
+	}
+
+	_, err = accounts.Insert(Account{Document: bingo.Document{ID: "a"}, Name: "ab", Seats: 3})
+	if got := fields(err); got != "Name must be at least 3 characters long; Seats failed the even validation" {
+		t.Fatalf("Expected readable field messages, got %s", got)
+	}
+	_, err = accounts.Insert(Account{Document: bingo.Document{ID: "a"}, Name: "acme", Owner: "acme"})
+	if got := fields(err); got != "Owner failed the nefield=Name validation" {
+		t.Fatalf("Expected the struct validation to fail, got %s", got)
+	}
+	_, err = accounts.Insert(Account{Document: bingo.Document{ID: "a"}, Name: "acme", Seats: 4, Plan: "free"})
+	if got := fields(err); got != "Seats must be at most 2 on the free plan" {
+		t.Fatalf("Expected the Validate method to fail, got %s", got)
+	}
+
+	if _, err := accounts.Insert(Account{Document: bingo.Document{ID: "a"}, Name: "acme", Seats: 2}); err != nil {
+		t.Fatalf("Failed to insert account: %v", err)
+	}
+
+	// every write path validates
+	writes := map[string]func() error{
+		"UpdateOne": func() error {
+			return accounts.UpdateOne(Account{Document: bingo.Document{ID: "a"}, Name: "acme", Seats: 1})
+		},
+		"UpdateIter": func() error {
+			return accounts.UpdateIter(func(a *Account) *Account {
+				a.Seats = 1
+				return a
+			})
+		},
+		"QueryResult.Update": func() error {
+			return accounts.Query(bingo.Query[Account]{KeysStr: []string{"a"}}).Iter(func(a *Account) error {
+				a.Seats = 1
+				return nil
+			}).Update()
+		},
+		"Upsert": func() error {
+			_, err := accounts.Insert(Account{Document: bingo.Document{ID: "a"}, Name: "acme", Seats: 1}, bingo.Upsert)
+			return err
+		},
+	}
+	for method, write := range writes {
+		if err := write(); !bingo.IsErrValidation(err) {
+			t.Fatalf("Expected %s to fail validation, got %v", method, err)
+		}
+	}
+	if account, _ := accounts.FindByKey("a"); account.Seats != 2 {
+		t.Fatalf("Expected no invalid write to be stored, got %v", account)
+	}
+
+	unchecked := bingo.CollectionFrom[Account](driver, "accounts", bingo.SkipValidation)
+	if err := unchecked.UpdateOne(Account{Document: bingo.Document{ID: "a"}, Name: "x", Seats: 1}); err != nil {
+		t.Fatalf("Expected SkipValidation to allow the update, got %v", err)
+	}
+}